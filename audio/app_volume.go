@@ -0,0 +1,291 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	dbus "pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/pulse"
+)
+
+// 记住每个应用的音量、静音和选择的输出设备，应用重新播放声音时恢复。
+
+// appVolumeRule 是一个应用的设置，保存在 audio.json 的 Apps 中
+type appVolumeRule struct {
+	// application.name
+	Name string
+	// application.process.binary
+	Binary string
+	Volume float64
+	Mute   bool
+	// 用户选择的 sink 的名称，为空表示使用默认的 sink
+	Sink string `json:",omitempty"`
+}
+
+// appVolumeRuleInfo 是 ListAppVolumeRules 返回的内容
+type appVolumeRuleInfo struct {
+	Key string
+	appVolumeRule
+}
+
+type appVolumeRules struct {
+	mu    sync.Mutex
+	rules map[string]*appVolumeRule
+}
+
+func newAppVolumeRules() *appVolumeRules {
+	return &appVolumeRules{
+		rules: make(map[string]*appVolumeRule),
+	}
+}
+
+// getAppVolumeKey 返回应用的标识，优先使用 application.name，没有时使用程序名
+func getAppVolumeKey(propList map[string]string) string {
+	if name := propList[PropAppName]; name != "" {
+		return name
+	}
+	return propList[PropAppProcessBinary]
+}
+
+func (r *appVolumeRules) load(rules map[string]*appVolumeRule) {
+	r.mu.Lock()
+	r.rules = make(map[string]*appVolumeRule, len(rules))
+	for key, rule := range rules {
+		if key != "" && rule != nil {
+			r.rules[key] = rule
+		}
+	}
+	r.mu.Unlock()
+}
+
+// dump 返回所有设置的副本，用于保存配置
+func (r *appVolumeRules) dump() map[string]*appVolumeRule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.rules) == 0 {
+		return nil
+	}
+	result := make(map[string]*appVolumeRule, len(r.rules))
+	for key, rule := range r.rules {
+		ruleCopy := *rule
+		result[key] = &ruleCopy
+	}
+	return result
+}
+
+func (r *appVolumeRules) get(key string) *appVolumeRule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rule, ok := r.rules[key]
+	if !ok {
+		return nil
+	}
+	ruleCopy := *rule
+	return &ruleCopy
+}
+
+// update 记录应用的音量和静音，返回是否有变化
+func (r *appVolumeRules) update(key, name, binary string, volume float64, mute bool) bool {
+	if key == "" {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rule, ok := r.rules[key]
+	if !ok {
+		rule = &appVolumeRule{}
+		r.rules[key] = rule
+	} else if rule.Name == name && rule.Binary == binary &&
+		rule.Volume == volume && rule.Mute == mute {
+		return false
+	}
+	rule.Name = name
+	rule.Binary = binary
+	rule.Volume = volume
+	rule.Mute = mute
+	return true
+}
+
+// setSink 记录用户为应用选择的 sink，需要先用 update 添加应用的设置
+func (r *appVolumeRules) setSink(key, sink string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rule, ok := r.rules[key]
+	if !ok || rule.Sink == sink {
+		return false
+	}
+	rule.Sink = sink
+	return true
+}
+
+// forget 删除应用的设置，key 为空时删除所有设置
+func (r *appVolumeRules) forget(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key == "" {
+		changed := len(r.rules) > 0
+		r.rules = make(map[string]*appVolumeRule)
+		return changed
+	}
+	if _, ok := r.rules[key]; !ok {
+		return false
+	}
+	delete(r.rules, key)
+	return true
+}
+
+func (r *appVolumeRules) list() []appVolumeRuleInfo {
+	r.mu.Lock()
+	result := make([]appVolumeRuleInfo, 0, len(r.rules))
+	for key, rule := range r.rules {
+		result = append(result, appVolumeRuleInfo{Key: key, appVolumeRule: *rule})
+	}
+	r.mu.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+func appVolumeRulesEqual(a, b map[string]*appVolumeRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, rule := range a {
+		rule1, ok := b[key]
+		if !ok || rule == nil || rule1 == nil || *rule != *rule1 {
+			return false
+		}
+	}
+	return true
+}
+
+// applyAppVolumeRule 在应用开始播放声音时恢复记住的设置
+func (a *Audio) applyAppVolumeRule(sinkInputInfo *pulse.SinkInput) {
+	key := getAppVolumeKey(sinkInputInfo.PropList)
+	rule := a.appVolumeRules.get(key)
+	if rule == nil {
+		return
+	}
+	logger.Debugf("apply volume rule of app %q to sink-input #%d: %+v", key, sinkInputInfo.Index, rule)
+
+	ctx := a.context()
+	if ctx == nil {
+		return
+	}
+	if isVolumeValid(rule.Volume) && sinkInputInfo.Volume.Avg() != rule.Volume {
+		ctx.SetSinkInputVolume(sinkInputInfo.Index, sinkInputInfo.Volume.SetAvg(rule.Volume))
+	}
+	if sinkInputInfo.Mute != rule.Mute {
+		ctx.SetSinkInputMute(sinkInputInfo.Index, rule.Mute)
+	}
+	if rule.Sink != "" {
+		sinkInfo := a.getSinkInfoByName(rule.Sink)
+		if sinkInfo == nil {
+			logger.Debugf("sink %s of app %q not found", rule.Sink, key)
+			return
+		}
+		if sinkInfo.Index != sinkInputInfo.Sink {
+			ctx.MoveSinkInputsByIndex([]uint32{sinkInputInfo.Index}, sinkInfo.Index)
+		}
+	}
+}
+
+// rememberAppVolume 在应用的音量或者静音变化后记录下来
+func (a *Audio) rememberAppVolume(sinkInputInfo *pulse.SinkInput) {
+	key := getAppVolumeKey(sinkInputInfo.PropList)
+	changed := a.appVolumeRules.update(key, sinkInputInfo.PropList[PropAppName],
+		sinkInputInfo.PropList[PropAppProcessBinary], sinkInputInfo.Volume.Avg(), sinkInputInfo.Mute)
+	if changed {
+		a.saveConfig()
+	}
+}
+
+// hasAppSink 判断 sink-input 所属的应用是否选择了现在存在的 sink，这时不随默认 sink 移动，
+// sinkNames 为现在存在的 sink 的名称
+func (a *Audio) hasAppSink(sinkInput *SinkInput, sinkNames map[string]bool) bool {
+	sinkInput.PropsMu.RLock()
+	key := sinkInput.appKey
+	sinkInput.PropsMu.RUnlock()
+
+	rule := a.appVolumeRules.get(key)
+	if rule == nil || rule.Sink == "" {
+		return false
+	}
+	return sinkNames[rule.Sink]
+}
+
+func (a *Audio) getSinkNames() map[string]bool {
+	sinkNames := make(map[string]bool)
+	for _, sinkInfo := range a.ctx.GetSinkList() {
+		sinkNames[sinkInfo.Name] = true
+	}
+	return sinkNames
+}
+
+func (a *Audio) getSinkByPath(path dbus.ObjectPath) *Sink {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, sink := range a.sinks {
+		if sink.getPath() == path {
+			return sink
+		}
+	}
+	return nil
+}
+
+// MoveToSink 把应用的声音移动到 sink 播放，之后应用再播放声音时也使用这个 sink，
+// sink 为默认 sink 时恢复为跟随默认 sink。
+func (s *SinkInput) MoveToSink(sink dbus.ObjectPath) *dbus.Error {
+	logger.Debugf("SinkInput #%d MoveToSink %s", s.index, sink)
+	target := s.audio.getSinkByPath(sink)
+	if target == nil {
+		return dbusutil.ToError(errors.New("invalid sink " + string(sink)))
+	}
+
+	if s.getPropSinkIndex() != target.index {
+		s.audio.context().MoveSinkInputsByIndex([]uint32{s.index}, target.index)
+	}
+
+	s.PropsMu.RLock()
+	key := s.appKey
+	changed := s.audio.appVolumeRules.update(key, s.Name, s.appBinary, s.Volume, s.Mute)
+	s.PropsMu.RUnlock()
+
+	target.PropsMu.RLock()
+	sinkName := target.Name
+	target.PropsMu.RUnlock()
+	if sinkName == s.audio.getDefaultSinkName() {
+		sinkName = ""
+	}
+	if s.audio.appVolumeRules.setSink(key, sinkName) || changed {
+		s.audio.saveConfig()
+	}
+	return nil
+}
+
+// ListAppVolumeRules 返回记住的应用设置，JSON 格式的数组，
+// 每项包括 Key、Name、Binary、Volume、Mute 和 Sink
+func (a *Audio) ListAppVolumeRules() (string, *dbus.Error) {
+	data, err := json.Marshal(a.appVolumeRules.list())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// ForgetAppVolumeRule 删除应用的设置，app 是 ListAppVolumeRules 返回的 Key，为空时删除所有设置
+func (a *Audio) ForgetAppVolumeRule(app string) *dbus.Error {
+	logger.Debugf("ForgetAppVolumeRule %q", app)
+	if !a.appVolumeRules.forget(app) {
+		if app != "" {
+			return dbusutil.ToError(errors.New("not found volume rule of app " + app))
+		}
+		return nil
+	}
+	a.saveConfig()
+	return nil
+}
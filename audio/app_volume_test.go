@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_appVolumeRules(t *testing.T) {
+	Convey("getAppVolumeKey", t, func(c C) {
+		c.So(getAppVolumeKey(map[string]string{
+			PropAppName:          "Chromium",
+			PropAppProcessBinary: "chromium",
+		}), ShouldEqual, "Chromium")
+		c.So(getAppVolumeKey(map[string]string{
+			PropAppProcessBinary: "mpv",
+		}), ShouldEqual, "mpv")
+		c.So(getAppVolumeKey(nil), ShouldEqual, "")
+	})
+
+	Convey("appVolumeRules", t, func(c C) {
+		r := newAppVolumeRules()
+		c.So(r.update("", "", "", 0.5, false), ShouldBeFalse)
+		c.So(r.setSink("mpv", "sink1"), ShouldBeFalse)
+
+		c.So(r.update("mpv", "", "mpv", 0.5, false), ShouldBeTrue)
+		c.So(r.update("mpv", "", "mpv", 0.5, false), ShouldBeFalse)
+		c.So(r.update("mpv", "", "mpv", 0.3, true), ShouldBeTrue)
+		c.So(r.setSink("mpv", "sink1"), ShouldBeTrue)
+		c.So(r.get("mpv"), ShouldResemble, &appVolumeRule{
+			Binary: "mpv",
+			Volume: 0.3,
+			Mute:   true,
+			Sink:   "sink1",
+		})
+
+		// 修改 get 返回的副本不影响设置
+		rule := r.get("mpv")
+		rule.Volume = 1
+		c.So(r.get("mpv").Volume, ShouldEqual, 0.3)
+
+		r.update("Chromium", "Chromium", "chromium", 0.8, false)
+		list := r.list()
+		c.So(list, ShouldHaveLength, 2)
+		c.So(list[0].Key, ShouldEqual, "Chromium")
+		c.So(list[1].Key, ShouldEqual, "mpv")
+		data, err := json.Marshal(list[1])
+		c.So(err, ShouldBeNil)
+		c.So(string(data), ShouldEqual,
+			`{"Key":"mpv","Name":"","Binary":"mpv","Volume":0.3,"Mute":true,"Sink":"sink1"}`)
+
+		dump := r.dump()
+		c.So(appVolumeRulesEqual(dump, r.dump()), ShouldBeTrue)
+		r2 := newAppVolumeRules()
+		r2.load(dump)
+		c.So(appVolumeRulesEqual(dump, r2.dump()), ShouldBeTrue)
+
+		c.So(r.forget("vlc"), ShouldBeFalse)
+		c.So(r.forget("mpv"), ShouldBeTrue)
+		c.So(r.get("mpv"), ShouldBeNil)
+		c.So(appVolumeRulesEqual(dump, r.dump()), ShouldBeFalse)
+		c.So(r.forget(""), ShouldBeTrue)
+		c.So(r.dump(), ShouldBeNil)
+	})
+}
@@ -135,16 +135,60 @@ type Audio struct {
 
 	noRestartPulseAudio bool
 
+	// 记住的每个应用的设置
+	appVolumeRules *appVolumeRules
+
+	// 输出和输入设备的优先级列表
+	devicePriorities   *devicePriorities
+	devicePrioritiesMu sync.Mutex
+	// 每个声卡的端口的可用性，只在可用性变化时才按照优先级切换设备，以免覆盖用户的选择
+	portAvailability map[uint32]map[string]int
+	// 新添加的还没有添加过 sink 的声卡
+	newCards map[uint32]bool
+
+	// 降噪和回声消除的设置和加载的模块
+	sourceFilter   sourceFilter
+	filterModule   *filterModule
+	sourceFilterMu sync.Mutex
+
+	// 均衡器的设置和加载的模块，equalizerTarget 是最近一次使用的 sink 和端口
+	equalizer       *equalizer
+	equalizerModule *equalizerModule
+	equalizerTarget string
+	equalizerMu     sync.Mutex
+
 	methods *struct {
 		SetPort func() `in:"cardId,portName,direction"`
+
+		ListAppVolumeRules  func() `out:"rules"`
+		ForgetAppVolumeRule func() `in:"app"`
+
+		GetDevicePriorities func() `out:"priorities"`
+		SetDevicePriorities func() `in:"priorities"`
+
+		GetEqualizerPresets   func() `out:"presets"`
+		SaveEqualizerPreset   func() `in:"name,gains"`
+		DeleteEqualizerPreset func() `in:"name"`
+	}
+
+	signals *struct {
+		// 按照设备优先级自动切换了设备，reason 说明切换的原因
+		DeviceSwitched struct {
+			direction int32
+			cardId    uint32
+			port      string
+			reason    string
+		}
 	}
 }
 
 func newAudio(service *dbusutil.Service) *Audio {
 	a := &Audio{
-		service:     service,
-		meters:      make(map[string]*Meter),
-		MaxUIVolume: pulse.VolumeUIMax,
+		service:        service,
+		meters:         make(map[string]*Meter),
+		MaxUIVolume:    pulse.VolumeUIMax,
+		appVolumeRules: newAppVolumeRules(),
+		equalizer:      newEqualizer(),
 	}
 
 	a.settings = gio.NewSettings(gsSchemaAudio)
@@ -213,7 +257,6 @@ func getCtx() (ctx *pulse.Context, err error) {
 		err = errors.New("failed to get pulse context")
 		return
 	}
-	ctx.LoadModule("module-switch-on-connect", "")
 	return
 }
 
@@ -309,6 +352,9 @@ func (a *Audio) init() error {
 
 	a.mu.Lock()
 	a.cards = newCardList(a.ctx.GetCardList())
+	for _, card := range a.cards {
+		a.updatePortAvailability(card.Id, card.Ports)
+	}
 
 	a.PropsMu.Lock()
 	a.setPropCards(a.cards.string())
@@ -340,6 +386,9 @@ func (a *Audio) init() error {
 	} else {
 		a.applyConfig()
 	}
+	a.updateSwitchOnConnect()
+	a.applyDevicePriorities("audio service started")
+	a.initAudioFilters()
 
 	a.fixActivePortNotAvailable()
 	a.moveSinkInputsToDefaultSink()
@@ -597,6 +646,7 @@ func (a *Audio) resetSourceVolume() {
 	}
 }
 
+// Reset 重置音量和音效设置，降噪和回声消除的设置不重置
 func (a *Audio) Reset() *dbus.Error {
 	a.resetSinksVolume()
 	a.resetSourceVolume()
@@ -608,19 +658,33 @@ func (a *Audio) Reset() *dbus.Error {
 
 func (a *Audio) moveSinkInputsToSink(sinkId uint32) {
 	a.mu.Lock()
-	if len(a.sinkInputs) == 0 {
-		a.mu.Unlock()
+	sinkInputs := make([]*SinkInput, 0, len(a.sinkInputs))
+	for _, sinkInput := range a.sinkInputs {
+		sinkInputs = append(sinkInputs, sinkInput)
+	}
+	a.mu.Unlock()
+	if len(sinkInputs) == 0 {
 		return
 	}
+
+	// 不持有 a.mu 时再查询 pulseaudio
+	sinkNames := a.getSinkNames()
 	var list []uint32
-	for _, sinkInput := range a.sinkInputs {
+	for _, sinkInput := range sinkInputs {
 		if sinkInput.getPropSinkIndex() == sinkId {
 			continue
 		}
+		if a.hasAppSink(sinkInput, sinkNames) {
+			// 应用选择了其他的 sink
+			continue
+		}
+		if sinkInput.filter {
+			// 回声消除等模块自己的声音
+			continue
+		}
 
 		list = append(list, sinkInput.index)
 	}
-	a.mu.Unlock()
 	if len(list) == 0 {
 		return
 	}
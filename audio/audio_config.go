@@ -37,6 +37,15 @@ func (a *Audio) applyConfig() {
 		}
 	}
 	logger.Debugf("load config: %+v", cfg)
+	if cfg != nil {
+		a.appVolumeRules.load(cfg.Apps)
+		a.setDevicePriorities(cfg.DevicePriorities)
+		a.setSourceFilter(sourceFilter{
+			NoiseReduction:   cfg.NoiseReduction,
+			EchoCancellation: cfg.EchoCancellation,
+		})
+		a.equalizer.load(cfg.Equalizer)
+	}
 
 	if !a.isConfigValid(cfg) {
 		logger.Warning("Invalid config:", cfg.string())
@@ -169,7 +178,14 @@ func (a *Audio) saveConfig() {
 func (a *Audio) doSaveConfig() {
 	var info = config{
 		Profiles: make(map[string]string),
+		Apps:     a.appVolumeRules.dump(),
+
+		DevicePriorities: a.getDevicePriorities(),
+		Equalizer:        a.equalizer.dump(),
 	}
+	filter := a.getSourceFilter()
+	info.NoiseReduction = filter.NoiseReduction
+	info.EchoCancellation = filter.EchoCancellation
 
 	ctx := a.context()
 	if ctx == nil {
@@ -182,7 +198,7 @@ func (a *Audio) doSaveConfig() {
 	}
 
 	for _, sinkInfo := range ctx.GetSinkList() {
-		if a.getDefaultSinkName() != sinkInfo.Name {
+		if a.getPhysicalSinkName() != sinkInfo.Name {
 			continue
 		}
 
@@ -193,7 +209,7 @@ func (a *Audio) doSaveConfig() {
 	}
 
 	for _, sourceInfo := range ctx.GetSourceList() {
-		if a.getDefaultSourceName() != sourceInfo.Name {
+		if a.getPhysicalSourceName() != sourceInfo.Name {
 			continue
 		}
 
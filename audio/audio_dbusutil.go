@@ -435,6 +435,32 @@ func (v *Source) emitPropChangedCard(value uint32) error {
 	return v.service.EmitPropertyChanged(v, "Card", value)
 }
 
+func (v *Source) setPropNoiseReduction(value bool) (changed bool) {
+	if v.NoiseReduction != value {
+		v.NoiseReduction = value
+		v.emitPropChangedNoiseReduction(value)
+		return true
+	}
+	return false
+}
+
+func (v *Source) emitPropChangedNoiseReduction(value bool) error {
+	return v.service.EmitPropertyChanged(v, "NoiseReduction", value)
+}
+
+func (v *Source) setPropEchoCancellation(value bool) (changed bool) {
+	if v.EchoCancellation != value {
+		v.EchoCancellation = value
+		v.emitPropChangedEchoCancellation(value)
+		return true
+	}
+	return false
+}
+
+func (v *Source) emitPropChangedEchoCancellation(value bool) error {
+	return v.service.EmitPropertyChanged(v, "EchoCancellation", value)
+}
+
 func (v *Meter) setPropVolume(value float64) (changed bool) {
 	if v.Volume != value {
 		v.Volume = value
@@ -21,6 +21,7 @@ package audio
 
 import "pkg.deepin.io/lib/pulse"
 import (
+	"fmt"
 	"sort"
 	"strconv"
 	"time"
@@ -93,6 +94,8 @@ func (a *Audio) handleCardEvent(eventType int, idx uint32) {
 			logger.Warning("get card info failed: ", err)
 			return
 		}
+		a.updatePortAvailability(idx, cardInfo.Ports)
+		a.markNewCard(idx)
 		cards, added := a.cards.add(newCard(cardInfo))
 		if added {
 			a.PropsMu.Lock()
@@ -104,15 +107,18 @@ func (a *Audio) handleCardEvent(eventType int, idx uint32) {
 		time.AfterFunc(time.Millisecond*500, func() {
 			selectNewCardProfile(cardInfo)
 			logger.Debug("After select profile:", cardInfo.ActiveProfile.Name)
+			a.applyDevicePriorities(fmt.Sprintf("card %s added", getCardName(cardInfo)))
 		})
 	case pulse.EventTypeRemove:
 		logger.Debugf("[Event] card #%d removed", idx)
+		a.removePortAvailability(idx)
 		cards, deleted := a.cards.delete(idx)
 		if deleted {
 			a.PropsMu.Lock()
 			a.setPropCards(cards.string())
 			a.PropsMu.Unlock()
 			a.cards = cards
+			a.applyDevicePriorities(fmt.Sprintf("card #%d removed", idx))
 		}
 	case pulse.EventTypeChange:
 		logger.Debugf("[Event] card #%d changed", idx)
@@ -130,6 +136,10 @@ func (a *Audio) handleCardEvent(eventType int, idx uint32) {
 			a.PropsMu.Unlock()
 		}
 		a.mu.Unlock()
+		// 端口插拔时声卡会变化，用户切换端口或者配置时也会变化，这时不切换
+		if card != nil && a.updatePortAvailability(idx, cardInfo.Ports) {
+			a.applyDevicePriorities(fmt.Sprintf("card %s ports availability changed", getCardName(cardInfo)))
+		}
 	}
 }
 
@@ -174,6 +184,10 @@ func (a *Audio) handleSinkEvent(eventType int, idx uint32) {
 			return
 		}
 		a.addSink(sinkInfo)
+		// 只在新的声卡的 sink 出现时切换，用户切换声卡配置时也会添加 sink
+		if a.takeNewCard(sinkInfo.Card) {
+			a.applyDevicePriorities(fmt.Sprintf("sink %s added", sinkInfo.Name))
+		}
 
 	case pulse.EventTypeRemove:
 		logger.Debugf("[Event] sink #%d removed", idx)
@@ -209,6 +223,7 @@ func (a *Audio) handleSinkEvent(eventType int, idx uint32) {
 			return
 		}
 		sink.update(sinkInfo)
+		a.checkEqualizerPort(sinkInfo)
 	}
 }
 
@@ -235,6 +250,9 @@ func (a *Audio) handleSinkInputEvent(eType int, idx uint32) {
 			return
 		}
 		sinkInput.update(sinkInputInfo)
+		if sinkInput.visible {
+			a.rememberAppVolume(sinkInputInfo)
+		}
 	}
 }
 
@@ -317,6 +335,9 @@ func (a *Audio) handleSinkInputAdded(idx uint32) {
 	}
 
 	a.addSinkInput(sinkInputInfo)
+	if getSinkInputVisible(sinkInputInfo) {
+		a.applyAppVolumeRule(sinkInputInfo)
+	}
 }
 
 func (a *Audio) handleSinkInputRemoved(idx uint32) {
@@ -380,6 +401,7 @@ func (a *Audio) handleSourceEvent(eventType int, idx uint32) {
 			return
 		}
 		a.addSource(sourceInfo)
+		a.refreshSourceFilterProps()
 
 	case pulse.EventTypeRemove:
 		logger.Debugf("[Event] source #%d removed", idx)
@@ -437,5 +459,6 @@ func (a *Audio) handleServerEvent(eventType int) {
 
 		a.updateDefaultSink(server.DefaultSinkName)
 		a.updateDefaultSource(server.DefaultSourceName)
+		a.updateOutputFilters()
 	}
 }
@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 
 	"pkg.deepin.io/lib/xdg/basedir"
@@ -44,6 +45,17 @@ type config struct {
 
 	SinkVolume   float64
 	SourceVolume float64
+
+	// Apps[应用] = 应用的音量、静音和选择的 sink
+	Apps map[string]*appVolumeRule `json:",omitempty"`
+
+	DevicePriorities *devicePriorities `json:",omitempty"`
+
+	// 输入降噪和回声消除
+	NoiseReduction   bool `json:",omitempty"`
+	EchoCancellation bool `json:",omitempty"`
+
+	Equalizer *equalizerConfig `json:",omitempty"`
 }
 
 func (c *config) string() string {
@@ -64,7 +76,12 @@ func (c *config) equal(b *config) bool {
 		c.SourcePort == b.SourcePort &&
 		c.SinkVolume == b.SinkVolume &&
 		c.SourceVolume == b.SourceVolume &&
-		mapStrStrEqual(c.Profiles, b.Profiles)
+		mapStrStrEqual(c.Profiles, b.Profiles) &&
+		appVolumeRulesEqual(c.Apps, b.Apps) &&
+		reflect.DeepEqual(c.DevicePriorities, b.DevicePriorities) &&
+		c.NoiseReduction == b.NoiseReduction &&
+		c.EchoCancellation == b.EchoCancellation &&
+		reflect.DeepEqual(c.Equalizer, b.Equalizer)
 }
 
 func readConfig() (*config, error) {
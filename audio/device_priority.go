@@ -0,0 +1,402 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	dbus "pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/pulse"
+)
+
+// 用户可以设置输出和输入设备的优先级，设备插拔时自动切换到优先级最高的可用设备，
+// 没有设置时仍然使用原来的方式选择设备。
+
+const (
+	maxDevicePriorities = 32
+
+	switchOnConnectModuleName = "module-switch-on-connect"
+)
+
+// devicePriority 是优先级列表中的一项，为空的字段匹配所有设备
+type devicePriority struct {
+	// 声卡名称，pulseaudio 中的名称或者 Cards 属性中的名称
+	Card string `json:",omitempty"`
+	// 端口名称
+	Port string `json:",omitempty"`
+	// 蓝牙设备的地址
+	BluetoothAddress string `json:",omitempty"`
+}
+
+type devicePriorities struct {
+	Output []*devicePriority
+	Input  []*devicePriority
+}
+
+// portCandidate 是一个可以切换到的端口
+type portCandidate struct {
+	cardId    uint32
+	cardName  string
+	cardAlias string
+	btAddress string
+	port      *pulse.CardPortInfo
+}
+
+func (p *devicePriority) String() string {
+	var fields []string
+	if p.Card != "" {
+		fields = append(fields, "card "+p.Card)
+	}
+	if p.Port != "" {
+		fields = append(fields, "port "+p.Port)
+	}
+	if p.BluetoothAddress != "" {
+		fields = append(fields, "bluetooth "+p.BluetoothAddress)
+	}
+	return strings.Join(fields, ", ")
+}
+
+func (p *devicePriority) match(c *portCandidate) bool {
+	if p.Card != "" && p.Card != c.cardName && p.Card != c.cardAlias {
+		return false
+	}
+	if p.Port != "" && p.Port != c.port.Name {
+		return false
+	}
+	if p.BluetoothAddress != "" && !strings.EqualFold(p.BluetoothAddress, c.btAddress) {
+		return false
+	}
+	return true
+}
+
+func (p *devicePriorities) get(direction int) []*devicePriority {
+	if p == nil {
+		return nil
+	}
+	if direction == pulse.DirectionSink {
+		return p.Output
+	}
+	return p.Input
+}
+
+func (p *devicePriorities) check() error {
+	for _, list := range [][]*devicePriority{p.Output, p.Input} {
+		if len(list) > maxDevicePriorities {
+			return fmt.Errorf("too many device priorities, max %d", maxDevicePriorities)
+		}
+		for _, item := range list {
+			if item == nil || (item.Card == "" && item.Port == "" && item.BluetoothAddress == "") {
+				return errors.New("device priority is empty")
+			}
+		}
+	}
+	return nil
+}
+
+// getPriorityIndex 返回 c 在优先级列表中的位置，不在列表中时返回 -1
+func getPriorityIndex(priorities []*devicePriority, c *portCandidate) int {
+	for i, p := range priorities {
+		if p.match(c) {
+			return i
+		}
+	}
+	return -1
+}
+
+// selectPortByPriorities 在 candidates 中选择优先级最高的可用端口，
+// 一项匹配多个端口时选择可用性和 pulseaudio 优先级更高的端口。
+func selectPortByPriorities(priorities []*devicePriority, candidates []*portCandidate) (*portCandidate, int) {
+	var best *portCandidate
+	bestIdx := -1
+	for _, c := range candidates {
+		if c.port.Available == pulse.AvailableTypeNo {
+			continue
+		}
+		idx := getPriorityIndex(priorities, c)
+		if idx == -1 {
+			continue
+		}
+		if best == nil || idx < bestIdx ||
+			(idx == bestIdx && (portAvailForCompare(c.port.Available) > portAvailForCompare(best.port.Available) ||
+				(c.port.Available == best.port.Available && c.port.Priority > best.port.Priority))) {
+			best = c
+			bestIdx = idx
+		}
+	}
+	return best, bestIdx
+}
+
+// getCardBluetoothAddress 返回蓝牙声卡的设备地址，不是蓝牙声卡时返回空字符串
+func getCardBluetoothAddress(card *pulse.Card) string {
+	if addr := card.PropList["api.bluez5.address"]; addr != "" {
+		return addr
+	}
+	if card.PropList["device.api"] == "bluez" && card.PropList["device.string"] != "" {
+		return card.PropList["device.string"]
+	}
+	// bluez.path 的格式为 /org/bluez/hci0/dev_XX_XX_XX_XX_XX_XX
+	bluezPath := card.PropList["bluez.path"]
+	if idx := strings.LastIndex(bluezPath, "/dev_"); idx != -1 {
+		return strings.Replace(bluezPath[idx+len("/dev_"):], "_", ":", -1)
+	}
+	return ""
+}
+
+func (cards CardList) getPortCandidates(direction int) []*portCandidate {
+	var result []*portCandidate
+	for _, card := range cards {
+		var cardName, btAddress string
+		if card.core != nil {
+			cardName = card.core.Name
+			btAddress = getCardBluetoothAddress(card.core)
+		}
+		for idx := range card.Ports {
+			port := &card.Ports[idx]
+			if port.Direction != direction {
+				continue
+			}
+			result = append(result, &portCandidate{
+				cardId:    card.Id,
+				cardName:  cardName,
+				cardAlias: card.Name,
+				btAddress: btAddress,
+				port:      port,
+			})
+		}
+	}
+	return result
+}
+
+func (a *Audio) getDevicePriorities() *devicePriorities {
+	a.devicePrioritiesMu.Lock()
+	defer a.devicePrioritiesMu.Unlock()
+	if a.devicePriorities == nil {
+		return nil
+	}
+	result := &devicePriorities{
+		Output: append([]*devicePriority(nil), a.devicePriorities.Output...),
+		Input:  append([]*devicePriority(nil), a.devicePriorities.Input...),
+	}
+	return result
+}
+
+func (a *Audio) setDevicePriorities(priorities *devicePriorities) {
+	a.devicePrioritiesMu.Lock()
+	if priorities != nil && len(priorities.Output) == 0 && len(priorities.Input) == 0 {
+		priorities = nil
+	}
+	a.devicePriorities = priorities
+	a.devicePrioritiesMu.Unlock()
+}
+
+// getActivePort 返回默认 sink 或者 source 所在的声卡和使用的端口，
+// 默认设备是降噪和回声消除模块创建的设备时使用它的主设备
+func (a *Audio) getActivePort(direction int) (cardId uint32, portName string, ok bool) {
+	if direction == pulse.DirectionSink {
+		sinkInfo := a.getSinkInfoByName(a.getPhysicalSinkName())
+		if sinkInfo == nil {
+			return
+		}
+		return sinkInfo.Card, sinkInfo.ActivePort.Name, true
+	}
+	sourceInfo := a.getSourceInfoByName(a.getPhysicalSourceName())
+	if sourceInfo == nil {
+		return
+	}
+	return sourceInfo.Card, sourceInfo.ActivePort.Name, true
+}
+
+// selectPreferredPort 按照优先级列表选择 direction 方向的端口，reason 说明选择的原因
+func (a *Audio) selectPreferredPort(direction int) (c *portCandidate, reason string) {
+	priorities := a.getDevicePriorities().get(direction)
+	if len(priorities) == 0 {
+		return nil, ""
+	}
+	a.mu.Lock()
+	candidates := a.cards.getPortCandidates(direction)
+	a.mu.Unlock()
+
+	c, idx := selectPortByPriorities(priorities, candidates)
+	if c == nil {
+		return nil, ""
+	}
+
+	// 当前的端口优先级相同时不切换
+	cardId, portName, ok := a.getActivePort(direction)
+	if ok {
+		for _, c0 := range candidates {
+			if c0.cardId != cardId || c0.port.Name != portName {
+				continue
+			}
+			if c0 == c || (c0.port.Available != pulse.AvailableTypeNo && getPriorityIndex(priorities, c0) == idx) {
+				return nil, ""
+			}
+		}
+	}
+	reason = fmt.Sprintf("matched device priority #%d (%s)", idx+1, priorities[idx])
+	return c, reason
+}
+
+// getPortAvailability 返回端口名称和可用性的对应关系
+func getPortAvailability(ports pulse.CardPortInfos) map[string]int {
+	result := make(map[string]int, len(ports))
+	for _, port := range ports {
+		result[port.Name] = port.Available
+	}
+	return result
+}
+
+func isPortAvailabilityChanged(old, new map[string]int) bool {
+	if len(old) != len(new) {
+		return true
+	}
+	for name, available := range new {
+		if v, ok := old[name]; !ok || v != available {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePortAvailability 记录声卡端口的可用性，返回和之前记录的相比是否有变化
+func (a *Audio) updatePortAvailability(cardId uint32, ports pulse.CardPortInfos) bool {
+	availability := getPortAvailability(ports)
+	a.devicePrioritiesMu.Lock()
+	defer a.devicePrioritiesMu.Unlock()
+	if a.portAvailability == nil {
+		a.portAvailability = make(map[uint32]map[string]int)
+	}
+	old, ok := a.portAvailability[cardId]
+	a.portAvailability[cardId] = availability
+	return !ok || isPortAvailabilityChanged(old, availability)
+}
+
+func (a *Audio) removePortAvailability(cardId uint32) {
+	a.devicePrioritiesMu.Lock()
+	delete(a.portAvailability, cardId)
+	delete(a.newCards, cardId)
+	a.devicePrioritiesMu.Unlock()
+}
+
+func (a *Audio) markNewCard(cardId uint32) {
+	a.devicePrioritiesMu.Lock()
+	if a.newCards == nil {
+		a.newCards = make(map[uint32]bool)
+	}
+	a.newCards[cardId] = true
+	a.devicePrioritiesMu.Unlock()
+}
+
+// takeNewCard 判断 cardId 是否为新添加的声卡，每个声卡只返回一次 true
+func (a *Audio) takeNewCard(cardId uint32) bool {
+	a.devicePrioritiesMu.Lock()
+	defer a.devicePrioritiesMu.Unlock()
+	if !a.newCards[cardId] {
+		return false
+	}
+	delete(a.newCards, cardId)
+	return true
+}
+
+// updateSwitchOnConnect 没有设置优先级列表时加载 module-switch-on-connect，
+// 设置了以后卸载它，以免和按照优先级切换设备冲突
+func (a *Audio) updateSwitchOnConnect() {
+	priorities := a.getDevicePriorities()
+	inUse := priorities != nil && (len(priorities.Output) > 0 || len(priorities.Input) > 0)
+
+	out, err := exec.Command(cmdPactl, "list", "short", "modules").Output()
+	if err != nil {
+		logger.Warning("failed to list modules:", err)
+		return
+	}
+	indexes := findModules(string(out), switchOnConnectModuleName, "")
+	if !inUse {
+		if len(indexes) == 0 {
+			logger.Debug("load module", switchOnConnectModuleName)
+			a.ctx.LoadModule(switchOnConnectModuleName, "")
+		}
+		return
+	}
+	for _, index := range indexes {
+		logger.Debugf("unload module #%d %s", index, switchOnConnectModuleName)
+		err = unloadModule(index)
+		if err != nil {
+			logger.Warningf("failed to unload module #%d: %v", index, err)
+		}
+	}
+}
+
+// applyDevicePriorities 在设备变化后按照优先级列表切换输出和输入设备，event 说明是什么变化。
+// 没有设置优先级或者没有匹配的设备时返回 false。
+func (a *Audio) applyDevicePriorities(event string) bool {
+	var applied bool
+	for _, direction := range []int{pulse.DirectionSink, pulse.DirectionSource} {
+		c, reason := a.selectPreferredPort(direction)
+		if c == nil {
+			continue
+		}
+		reason = event + ": " + reason
+		logger.Infof("auto switch to card #%d port %s, %s", c.cardId, c.port.Name, reason)
+		err := a.setPort(c.cardId, c.port.Name, direction)
+		if err != nil {
+			logger.Warningf("failed to switch to card #%d port %s: %v", c.cardId, c.port.Name, err)
+			continue
+		}
+		applied = true
+		err = a.service.Emit(a, "DeviceSwitched", int32(direction), c.cardId, c.port.Name, reason)
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+	if applied {
+		a.saveConfig()
+	}
+	return applied
+}
+
+// GetDevicePriorities 返回输出和输入设备的优先级列表，JSON 格式，
+// 如 {"Output": [{"Card": "...", "Port": "..."}, {"BluetoothAddress": "..."}], "Input": []}
+func (a *Audio) GetDevicePriorities() (string, *dbus.Error) {
+	priorities := a.getDevicePriorities()
+	if priorities == nil {
+		priorities = &devicePriorities{}
+	}
+	if priorities.Output == nil {
+		priorities.Output = []*devicePriority{}
+	}
+	if priorities.Input == nil {
+		priorities.Input = []*devicePriority{}
+	}
+	data, err := json.Marshal(priorities)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetDevicePriorities 设置输出和输入设备的优先级列表，格式同 GetDevicePriorities，
+// 排在前面的优先级高，为空字符串时清除设置。设置后立即切换到优先级最高的可用设备。
+func (a *Audio) SetDevicePriorities(priorities string) *dbus.Error {
+	logger.Debug("SetDevicePriorities", priorities)
+	var value *devicePriorities
+	if strings.TrimSpace(priorities) != "" {
+		value = &devicePriorities{}
+		err := json.Unmarshal([]byte(priorities), value)
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
+		err = value.check()
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
+	}
+
+	a.setDevicePriorities(value)
+	a.saveConfig()
+	a.updateSwitchOnConnect()
+	a.applyDevicePriorities("device priorities changed")
+	return nil
+}
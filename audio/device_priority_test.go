@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"pkg.deepin.io/lib/pulse"
+)
+
+func TestDevicePriorities(t *testing.T) {
+	Convey("devicePriority.match", t, func(c C) {
+		candidate := &portCandidate{
+			cardName:  "bluez_card.00_11_22_33_44_55",
+			cardAlias: "Headset",
+			btAddress: "00:11:22:33:44:55",
+			port:      &pulse.CardPortInfo{PortInfo: pulse.PortInfo{Name: "headset-output"}},
+		}
+		c.So((&devicePriority{Card: "Headset"}).match(candidate), ShouldBeTrue)
+		c.So((&devicePriority{Card: "bluez_card.00_11_22_33_44_55", Port: "headset-output"}).match(candidate), ShouldBeTrue)
+		c.So((&devicePriority{BluetoothAddress: "00:11:22:33:44:55"}).match(candidate), ShouldBeTrue)
+		c.So((&devicePriority{Card: "Headset", Port: "speaker"}).match(candidate), ShouldBeFalse)
+		c.So((&devicePriority{BluetoothAddress: "00:11:22:33:44:66"}).match(candidate), ShouldBeFalse)
+	})
+
+	Convey("devicePriorities.check", t, func(c C) {
+		c.So((&devicePriorities{Output: []*devicePriority{{Port: "speaker"}}}).check(), ShouldBeNil)
+		c.So((&devicePriorities{Input: []*devicePriority{{}}}).check(), ShouldNotBeNil)
+		c.So((&devicePriorities{Input: []*devicePriority{nil}}).check(), ShouldNotBeNil)
+		c.So((&devicePriorities{Output: make([]*devicePriority, maxDevicePriorities+1)}).check(), ShouldNotBeNil)
+	})
+
+	Convey("selectPortByPriorities", t, func(c C) {
+		newCandidate := func(cardId uint32, cardName, port string, available int, priority uint32) *portCandidate {
+			return &portCandidate{
+				cardId:   cardId,
+				cardName: cardName,
+				port: &pulse.CardPortInfo{PortInfo: pulse.PortInfo{
+					Name:      port,
+					Priority:  priority,
+					Available: available,
+				}},
+			}
+		}
+		speaker := newCandidate(0, "pci", "analog-output-speaker", pulse.AvailableTypeUnknow, 100)
+		headphones := newCandidate(0, "pci", "analog-output-headphones", pulse.AvailableTypeNo, 200)
+		usb := newCandidate(1, "usb", "analog-output", pulse.AvailableTypeYes, 100)
+		candidates := []*portCandidate{speaker, headphones, usb}
+
+		priorities := []*devicePriority{
+			{Card: "pci", Port: "analog-output-headphones"},
+			{Card: "usb"},
+			{Card: "pci"},
+		}
+		best, idx := selectPortByPriorities(priorities, candidates)
+		c.So(best, ShouldEqual, usb)
+		c.So(idx, ShouldEqual, 1)
+
+		headphones.port.Available = pulse.AvailableTypeYes
+		best, idx = selectPortByPriorities(priorities, candidates)
+		c.So(best, ShouldEqual, headphones)
+		c.So(idx, ShouldEqual, 0)
+
+		// 同一项匹配多个端口时选择 pulseaudio 优先级高的端口
+		best, _ = selectPortByPriorities([]*devicePriority{{Card: "pci"}}, candidates)
+		c.So(best, ShouldEqual, headphones)
+
+		best, idx = selectPortByPriorities([]*devicePriority{{Card: "hdmi"}}, candidates)
+		c.So(best, ShouldBeNil)
+		c.So(idx, ShouldEqual, -1)
+	})
+
+	Convey("getCardBluetoothAddress", t, func(c C) {
+		card := &pulse.Card{PropList: map[string]string{
+			"device.api":    "bluez",
+			"device.string": "00:11:22:33:44:55",
+		}}
+		c.So(getCardBluetoothAddress(card), ShouldEqual, "00:11:22:33:44:55")
+
+		card.PropList = map[string]string{"bluez.path": "/org/bluez/hci0/dev_00_11_22_33_44_66"}
+		c.So(getCardBluetoothAddress(card), ShouldEqual, "00:11:22:33:44:66")
+
+		card.PropList = map[string]string{"device.api": "alsa"}
+		c.So(getCardBluetoothAddress(card), ShouldEqual, "")
+	})
+
+	Convey("isPortAvailabilityChanged", t, func(c C) {
+		ports := pulse.CardPortInfos{
+			{PortInfo: pulse.PortInfo{Name: "analog-output-speaker", Available: pulse.AvailableTypeYes}},
+			{PortInfo: pulse.PortInfo{Name: "analog-output-headphones", Available: pulse.AvailableTypeNo}},
+		}
+		old := getPortAvailability(ports)
+		c.So(old, ShouldResemble, map[string]int{
+			"analog-output-speaker":    pulse.AvailableTypeYes,
+			"analog-output-headphones": pulse.AvailableTypeNo,
+		})
+		c.So(isPortAvailabilityChanged(old, getPortAvailability(ports)), ShouldBeFalse)
+
+		// 插入耳机
+		ports[1].Available = pulse.AvailableTypeYes
+		c.So(isPortAvailabilityChanged(old, getPortAvailability(ports)), ShouldBeTrue)
+
+		// 切换配置后端口变化
+		c.So(isPortAvailabilityChanged(old, getPortAvailability(ports[:1])), ShouldBeTrue)
+		c.So(isPortAvailabilityChanged(nil, nil), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,554 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+	dbus "pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/pulse"
+)
+
+// 输出设备的 10 段均衡器。使用 pulseaudio 的 module-ladspa-sink 加载 CAPS 的 Eq10X2 插件，
+// 在默认 sink 上创建一个虚拟的 sink，并把它设置为默认输出设备。
+// 每个 sink 的每个端口有自己的设置，耳机和扬声器可以使用不同的曲线。
+
+const (
+	equalizerModuleName = "module-ladspa-sink"
+	equalizerSinkName   = "deepin_equalizer_sink"
+	equalizerPlugin     = "caps"
+	equalizerLabel      = "Eq10X2"
+
+	equalizerBands   = 10
+	equalizerMinGain = -12.0
+	equalizerMaxGain = 12.0
+
+	maxEqualizerPresets = 32
+)
+
+// 各个频段的中心频率，单位 Hz
+var equalizerFrequencies = []int{31, 62, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// equalizerPreset 是一组命名的增益，单位 dB
+type equalizerPreset struct {
+	Name  string
+	Gains []float64
+}
+
+var builtInEqualizerPresets = []*equalizerPreset{
+	{Name: "Flat", Gains: []float64{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+	{Name: "Bass Boost", Gains: []float64{6, 5, 4, 2, 0, 0, 0, 0, 0, 0}},
+	{Name: "Treble Boost", Gains: []float64{0, 0, 0, 0, 0, 1, 2, 4, 5, 6}},
+	{Name: "Vocal", Gains: []float64{-2, -2, -1, 1, 3, 3, 2, 1, 0, -1}},
+	{Name: "Rock", Gains: []float64{4, 3, 2, 0, -1, -1, 0, 2, 3, 4}},
+	{Name: "Pop", Gains: []float64{-1, 0, 2, 3, 4, 3, 2, 0, -1, -1}},
+	{Name: "Jazz", Gains: []float64{3, 2, 1, 2, -1, -1, 0, 1, 2, 3}},
+	{Name: "Classical", Gains: []float64{4, 3, 2, 1, 0, 0, 0, 1, 2, 3}},
+}
+
+// equalizerProfile 是一个 sink 的一个端口的设置
+type equalizerProfile struct {
+	Enabled bool
+	// 使用的预设，为空表示自定义
+	Preset string `json:",omitempty"`
+	Gains  []float64
+}
+
+// equalizerConfig 保存在 audio.json 中，也通过 deepin sync 同步
+type equalizerConfig struct {
+	// 用户保存的预设
+	Presets []*equalizerPreset `json:",omitempty"`
+	// Profiles[sink 名称/端口名称] = 设置
+	Profiles map[string]*equalizerProfile `json:",omitempty"`
+}
+
+// equalizerPresetInfo 是 GetEqualizerPresets 返回的内容
+type equalizerPresetInfo struct {
+	Name    string
+	Gains   []float64
+	BuiltIn bool
+}
+
+// equalizerInfo 是 Sink.GetEqualizer 返回的内容
+type equalizerInfo struct {
+	Enabled     bool
+	Preset      string
+	Gains       []float64
+	Frequencies []int
+}
+
+type equalizer struct {
+	mu  sync.Mutex
+	cfg equalizerConfig
+}
+
+// equalizerModule 是已经加载的模块
+type equalizerModule struct {
+	index  int
+	master string
+	gains  []float64
+}
+
+func newEqualizer() *equalizer {
+	return &equalizer{}
+}
+
+func getEqualizerProfileKey(sink, port string) string {
+	return sink + "/" + port
+}
+
+func checkEqualizerGains(gains []float64) error {
+	if len(gains) != equalizerBands {
+		return fmt.Errorf("expect %d gains, got %d", equalizerBands, len(gains))
+	}
+	for _, gain := range gains {
+		if math.IsNaN(gain) || gain < equalizerMinGain || gain > equalizerMaxGain {
+			return fmt.Errorf("invalid gain %v, should be in [%v, %v]", gain, equalizerMinGain, equalizerMaxGain)
+		}
+	}
+	return nil
+}
+
+func isEqualizerFlat(gains []float64) bool {
+	for _, gain := range gains {
+		if gain != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func gainsEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyGains(gains []float64) []float64 {
+	return append([]float64(nil), gains...)
+}
+
+func getBuiltInEqualizerPreset(name string) *equalizerPreset {
+	for _, preset := range builtInEqualizerPresets {
+		if preset.Name == name {
+			return preset
+		}
+	}
+	return nil
+}
+
+func copyEqualizerConfig(cfg *equalizerConfig) equalizerConfig {
+	var result equalizerConfig
+	for _, preset := range cfg.Presets {
+		if preset == nil || checkEqualizerGains(preset.Gains) != nil {
+			continue
+		}
+		result.Presets = append(result.Presets, &equalizerPreset{
+			Name:  preset.Name,
+			Gains: copyGains(preset.Gains),
+		})
+	}
+	if len(cfg.Profiles) > 0 {
+		result.Profiles = make(map[string]*equalizerProfile, len(cfg.Profiles))
+		for key, profile := range cfg.Profiles {
+			if profile == nil || checkEqualizerGains(profile.Gains) != nil {
+				continue
+			}
+			result.Profiles[key] = &equalizerProfile{
+				Enabled: profile.Enabled,
+				Preset:  profile.Preset,
+				Gains:   copyGains(profile.Gains),
+			}
+		}
+	}
+	return result
+}
+
+func (e *equalizer) load(cfg *equalizerConfig) {
+	e.mu.Lock()
+	if cfg == nil {
+		e.cfg = equalizerConfig{}
+	} else {
+		e.cfg = copyEqualizerConfig(cfg)
+	}
+	e.mu.Unlock()
+}
+
+// dump 返回设置的副本，没有设置时返回 nil
+func (e *equalizer) dump() *equalizerConfig {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.cfg.Presets) == 0 && len(e.cfg.Profiles) == 0 {
+		return nil
+	}
+	result := copyEqualizerConfig(&e.cfg)
+	return &result
+}
+
+// getPreset 返回名称为 name 的预设，内置的预设优先
+func (e *equalizer) getPreset(name string) *equalizerPreset {
+	if preset := getBuiltInEqualizerPreset(name); preset != nil {
+		return preset
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, preset := range e.cfg.Presets {
+		if preset.Name == name {
+			return &equalizerPreset{Name: preset.Name, Gains: copyGains(preset.Gains)}
+		}
+	}
+	return nil
+}
+
+func (e *equalizer) listPresets() []equalizerPresetInfo {
+	result := make([]equalizerPresetInfo, 0, len(builtInEqualizerPresets))
+	for _, preset := range builtInEqualizerPresets {
+		result = append(result, equalizerPresetInfo{Name: preset.Name, Gains: preset.Gains, BuiltIn: true})
+	}
+	e.mu.Lock()
+	for _, preset := range e.cfg.Presets {
+		result = append(result, equalizerPresetInfo{Name: preset.Name, Gains: copyGains(preset.Gains)})
+	}
+	e.mu.Unlock()
+	return result
+}
+
+// savePreset 保存用户的预设，已经存在时覆盖，使用这个预设的设置也会更新
+func (e *equalizer) savePreset(name string, gains []float64) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("preset name is empty")
+	}
+	if getBuiltInEqualizerPreset(name) != nil {
+		return fmt.Errorf("can not overwrite built-in preset %q", name)
+	}
+	err := checkEqualizerGains(gains)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var found bool
+	for _, preset := range e.cfg.Presets {
+		if preset.Name == name {
+			preset.Gains = copyGains(gains)
+			found = true
+			break
+		}
+	}
+	if !found {
+		if len(e.cfg.Presets) >= maxEqualizerPresets {
+			return fmt.Errorf("too many presets, max %d", maxEqualizerPresets)
+		}
+		e.cfg.Presets = append(e.cfg.Presets, &equalizerPreset{Name: name, Gains: copyGains(gains)})
+	}
+	for _, profile := range e.cfg.Profiles {
+		if profile.Preset == name {
+			profile.Gains = copyGains(gains)
+		}
+	}
+	return nil
+}
+
+// deletePreset 删除用户的预设，使用这个预设的设置变为自定义
+func (e *equalizer) deletePreset(name string) error {
+	if getBuiltInEqualizerPreset(name) != nil {
+		return fmt.Errorf("can not delete built-in preset %q", name)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, preset := range e.cfg.Presets {
+		if preset.Name != name {
+			continue
+		}
+		e.cfg.Presets = append(e.cfg.Presets[:i], e.cfg.Presets[i+1:]...)
+		for _, profile := range e.cfg.Profiles {
+			if profile.Preset == name {
+				profile.Preset = ""
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("not found preset %q", name)
+}
+
+func (e *equalizer) getProfile(sink, port string) *equalizerProfile {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	profile, ok := e.cfg.Profiles[getEqualizerProfileKey(sink, port)]
+	if !ok {
+		return nil
+	}
+	return &equalizerProfile{
+		Enabled: profile.Enabled,
+		Preset:  profile.Preset,
+		Gains:   copyGains(profile.Gains),
+	}
+}
+
+func (e *equalizer) setProfile(sink, port string, profile *equalizerProfile) {
+	e.mu.Lock()
+	if e.cfg.Profiles == nil {
+		e.cfg.Profiles = make(map[string]*equalizerProfile)
+	}
+	e.cfg.Profiles[getEqualizerProfileKey(sink, port)] = profile
+	e.mu.Unlock()
+}
+
+func getEqualizerModuleArgs(master string, gains []float64) []string {
+	controls := make([]string, len(gains))
+	for i, gain := range gains {
+		controls[i] = strconv.FormatFloat(gain, 'f', -1, 64)
+	}
+	return []string{
+		"sink_name=" + equalizerSinkName,
+		"sink_master=" + master,
+		"plugin=" + equalizerPlugin,
+		"label=" + equalizerLabel,
+		"control=" + strings.Join(controls, ","),
+		"sink_input_properties=media.role=filter",
+	}
+}
+
+// getSinkMasterName 返回模块创建的 sink 的主设备，不是模块创建的 sink 时返回 name
+func (a *Audio) getSinkMasterName(name string) string {
+	if name == filterSinkName {
+		a.sourceFilterMu.Lock()
+		if a.filterModule != nil {
+			name = a.filterModule.sinkMaster
+		}
+		a.sourceFilterMu.Unlock()
+	}
+	if name == equalizerSinkName {
+		a.equalizerMu.Lock()
+		if a.equalizerModule != nil {
+			name = a.equalizerModule.master
+		}
+		a.equalizerMu.Unlock()
+	}
+	return name
+}
+
+// getOutputSinkName 返回播放声音时应该使用的 sink，开启均衡器时为均衡器的 sink，不包括回声消除模块的 sink
+func (a *Audio) getOutputSinkName() string {
+	physical := a.getPhysicalSinkName()
+	a.equalizerMu.Lock()
+	defer a.equalizerMu.Unlock()
+	if a.equalizerModule != nil && a.equalizerModule.master == physical {
+		return equalizerSinkName
+	}
+	return physical
+}
+
+// updateEqualizer 按照默认 sink 和它的端口的设置加载、重新加载或者卸载模块，返回模块是否有变化
+func (a *Audio) updateEqualizer() (changed bool, err error) {
+	ctx := a.context()
+	if ctx == nil {
+		return false, errors.New("pulse context is nil")
+	}
+	master := a.getPhysicalSinkName()
+	var port string
+	if sinkInfo := a.getSinkInfoByName(master); sinkInfo != nil {
+		port = sinkInfo.ActivePort.Name
+	}
+	profile := a.equalizer.getProfile(master, port)
+
+	a.equalizerMu.Lock()
+	defer a.equalizerMu.Unlock()
+	a.equalizerTarget = getEqualizerProfileKey(master, port)
+	module := a.equalizerModule
+	if module != nil && a.getSinkInfoByName(equalizerSinkName) == nil {
+		// 主设备被移除时模块也会被卸载
+		module = nil
+		a.equalizerModule = nil
+		changed = true
+	}
+
+	if profile == nil || !profile.Enabled || isEqualizerFlat(profile.Gains) || master == "" {
+		if module == nil {
+			return changed, nil
+		}
+		logger.Debugf("unload module #%d", module.index)
+		err = unloadModule(module.index)
+		if err != nil {
+			return changed, xerrors.Errorf("failed to unload module #%d: %w", module.index, err)
+		}
+		a.equalizerModule = nil
+		if a.getDefaultSinkName() == equalizerSinkName {
+			ctx.SetDefaultSink(module.master)
+		}
+		return true, nil
+	}
+
+	if module == nil || module.master != master || !gainsEqual(module.gains, profile.Gains) {
+		if module != nil {
+			err = unloadModule(module.index)
+			if err != nil {
+				logger.Warningf("failed to unload module #%d: %v", module.index, err)
+			}
+			a.equalizerModule = nil
+		}
+		changed = true
+		args := getEqualizerModuleArgs(master, profile.Gains)
+		logger.Debug("load module", equalizerModuleName, args)
+		index, err := loadModule(equalizerModuleName, args)
+		if err != nil {
+			return changed, xerrors.Errorf("failed to load %s: %w", equalizerModuleName, err)
+		}
+		a.equalizerModule = &equalizerModule{
+			index:  index,
+			master: master,
+			gains:  copyGains(profile.Gains),
+		}
+	}
+
+	// 开启回声消除时默认 sink 为回声消除模块的 sink，它的主设备是均衡器的 sink
+	defaultSink := a.getDefaultSinkName()
+	if defaultSink != equalizerSinkName && defaultSink != filterSinkName {
+		ctx.SetDefaultSink(equalizerSinkName)
+	}
+	return changed, nil
+}
+
+// updateOutputFilters 更新均衡器，然后让回声消除模块使用新的 sink
+func (a *Audio) updateOutputFilters() {
+	changed, err := a.updateEqualizer()
+	if err != nil {
+		logger.Warning(err)
+	}
+	if changed {
+		// 回声消除模块的主设备可能是原来的均衡器的 sink，需要重新加载
+		a.resetSourceFilterModule()
+	}
+	a.checkSourceFilter()
+}
+
+// checkEqualizerPort 在默认 sink 的端口变化后使用端口的均衡器设置
+func (a *Audio) checkEqualizerPort(sinkInfo *pulse.Sink) {
+	if sinkInfo.Name != a.getPhysicalSinkName() {
+		return
+	}
+	a.equalizerMu.Lock()
+	target := a.equalizerTarget
+	a.equalizerMu.Unlock()
+	if target == getEqualizerProfileKey(sinkInfo.Name, sinkInfo.ActivePort.Name) {
+		return
+	}
+	a.updateOutputFilters()
+}
+
+// GetEqualizerPresets 返回所有的预设，JSON 格式的数组，每项包括 Name、Gains 和 BuiltIn
+func (a *Audio) GetEqualizerPresets() (string, *dbus.Error) {
+	data, err := json.Marshal(a.equalizer.listPresets())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SaveEqualizerPreset 保存用户的预设，gains 是 10 个频段的增益，单位 dB
+func (a *Audio) SaveEqualizerPreset(name string, gains []float64) *dbus.Error {
+	logger.Debugf("SaveEqualizerPreset %q %v", name, gains)
+	err := a.equalizer.savePreset(name, gains)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	a.saveConfig()
+	a.updateOutputFilters()
+	return nil
+}
+
+// DeleteEqualizerPreset 删除用户的预设
+func (a *Audio) DeleteEqualizerPreset(name string) *dbus.Error {
+	logger.Debugf("DeleteEqualizerPreset %q", name)
+	err := a.equalizer.deletePreset(name)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	a.saveConfig()
+	return nil
+}
+
+// getEqualizerSinkPort 返回 sink 的主设备和主设备使用的端口
+func (s *Sink) getEqualizerSinkPort() (string, string, error) {
+	s.PropsMu.RLock()
+	name := s.Name
+	s.PropsMu.RUnlock()
+	master := s.audio.getSinkMasterName(name)
+	sinkInfo := s.audio.getSinkInfoByName(master)
+	if sinkInfo == nil {
+		return "", "", fmt.Errorf("not found sink %s", master)
+	}
+	return master, sinkInfo.ActivePort.Name, nil
+}
+
+// GetEqualizer 返回 sink 当前端口的均衡器设置，JSON 格式，包括 Enabled、Preset、Gains 和 Frequencies
+func (s *Sink) GetEqualizer() (string, *dbus.Error) {
+	master, port, err := s.getEqualizerSinkPort()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	info := equalizerInfo{
+		Gains:       make([]float64, equalizerBands),
+		Frequencies: equalizerFrequencies,
+	}
+	if profile := s.audio.equalizer.getProfile(master, port); profile != nil {
+		info.Enabled = profile.Enabled
+		info.Preset = profile.Preset
+		info.Gains = profile.Gains
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetEqualizer 设置 sink 当前端口的均衡器，preset 不为空时使用预设的增益，
+// 否则使用 gains；关闭时 gains 可以为空，保留原来的增益。
+func (s *Sink) SetEqualizer(enabled bool, preset string, gains []float64) *dbus.Error {
+	logger.Debugf("Sink #%d SetEqualizer %v %q %v", s.index, enabled, preset, gains)
+	master, port, err := s.getEqualizerSinkPort()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	profile := s.audio.equalizer.getProfile(master, port)
+	if profile == nil {
+		profile = &equalizerProfile{Gains: make([]float64, equalizerBands)}
+	}
+	profile.Enabled = enabled
+	if preset != "" {
+		p := s.audio.equalizer.getPreset(preset)
+		if p == nil {
+			return dbusutil.ToError(fmt.Errorf("not found preset %q", preset))
+		}
+		profile.Preset = p.Name
+		profile.Gains = copyGains(p.Gains)
+	} else if len(gains) > 0 {
+		err = checkEqualizerGains(gains)
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
+		profile.Preset = ""
+		profile.Gains = copyGains(gains)
+	}
+
+	s.audio.equalizer.setProfile(master, port, profile)
+	s.audio.saveConfig()
+	s.audio.updateOutputFilters()
+	return nil
+}
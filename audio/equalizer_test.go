@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEqualizer(t *testing.T) {
+	Convey("checkEqualizerGains", t, func(c C) {
+		c.So(checkEqualizerGains([]float64{0, 1, 2, 3, 4, 5, 6, -12, 12, 0}), ShouldBeNil)
+		c.So(checkEqualizerGains([]float64{0, 1}), ShouldNotBeNil)
+		c.So(checkEqualizerGains([]float64{0, 0, 0, 0, 0, 0, 0, 0, 0, 13}), ShouldNotBeNil)
+		for _, preset := range builtInEqualizerPresets {
+			c.So(checkEqualizerGains(preset.Gains), ShouldBeNil)
+		}
+	})
+
+	Convey("equalizer presets", t, func(c C) {
+		e := newEqualizer()
+		c.So(e.dump(), ShouldBeNil)
+		gains := []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+
+		c.So(e.savePreset("Rock", gains), ShouldNotBeNil)
+		c.So(e.savePreset(" ", gains), ShouldNotBeNil)
+		c.So(e.savePreset("Mine", gains[:5]), ShouldNotBeNil)
+		c.So(e.savePreset("Mine", gains), ShouldBeNil)
+		c.So(e.getPreset("Mine").Gains, ShouldResemble, gains)
+		c.So(e.getPreset("Rock"), ShouldNotBeNil)
+		c.So(e.listPresets(), ShouldHaveLength, len(builtInEqualizerPresets)+1)
+
+		e.setProfile("alsa_output.pci", "analog-output-headphones",
+			&equalizerProfile{Enabled: true, Preset: "Mine", Gains: copyGains(gains)})
+		gains2 := []float64{2, 2, 2, 2, 2, 2, 2, 2, 2, 2}
+		c.So(e.savePreset("Mine", gains2), ShouldBeNil)
+		profile := e.getProfile("alsa_output.pci", "analog-output-headphones")
+		c.So(profile.Gains, ShouldResemble, gains2)
+		c.So(e.getProfile("alsa_output.pci", "analog-output-speaker"), ShouldBeNil)
+
+		c.So(e.deletePreset("Flat"), ShouldNotBeNil)
+		c.So(e.deletePreset("Mine"), ShouldBeNil)
+		c.So(e.deletePreset("Mine"), ShouldNotBeNil)
+		profile = e.getProfile("alsa_output.pci", "analog-output-headphones")
+		c.So(profile.Preset, ShouldEqual, "")
+		c.So(profile.Gains, ShouldResemble, gains2)
+
+		cfg := e.dump()
+		e1 := newEqualizer()
+		e1.load(cfg)
+		c.So(e1.dump(), ShouldResemble, cfg)
+	})
+
+	Convey("getEqualizerModuleArgs", t, func(c C) {
+		args := getEqualizerModuleArgs("alsa_output.pci", []float64{1.5, 0, 0, 0, 0, 0, 0, 0, 0, -3})
+		c.So(args, ShouldContain, "sink_master=alsa_output.pci")
+		c.So(args, ShouldContain, "control=1.5,0,0,0,0,0,0,0,0,-3")
+		c.So(args, ShouldContain, "label="+equalizerLabel)
+	})
+}
@@ -75,6 +75,9 @@ type Sink struct {
 		SetMute    func() `in:"value"`
 		SetPort    func() `in:"name"`
 		GetMeter   func() `out:"meter"`
+
+		GetEqualizer func() `out:"equalizer"`
+		SetEqualizer func() `in:"enabled,preset,gains"`
 	}
 }
 
@@ -46,6 +46,7 @@ type SinkInput struct {
 	correctIconCalled bool
 	correctedIcon     string
 	visible           bool
+	filter            bool // 是否为回声消除等模块自己的声音
 	cVolume           pulse.CVolume
 	channelMap        pulse.ChannelMap
 	// Name process name
@@ -58,12 +59,16 @@ type SinkInput struct {
 	Fade           float64
 	SupportFade    bool
 	SinkIndex      uint32
+	// 记住应用设置时使用的标识
+	appKey    string
+	appBinary string
 
 	methods *struct {
 		SetVolume  func() `in:"value,isPlay"`
 		SetBalance func() `in:"value,isPlay"`
 		SetFade    func() `in:"value"`
 		SetMute    func() `in:"value"`
+		MoveToSink func() `in:"sink"`
 	}
 }
 
@@ -76,6 +81,7 @@ func newSinkInput(sinkInputInfo *pulse.SinkInput, audio *Audio) *SinkInput {
 		service: audio.service,
 		index:   sinkInputInfo.Index,
 		visible: getSinkInputVisible(sinkInputInfo),
+		filter:  sinkInputInfo.PropList[pulse.PA_PROP_MEDIA_ROLE] == "filter",
 	}
 	sinkInput.update(sinkInputInfo)
 	return sinkInput
@@ -101,7 +107,7 @@ func getSinkInputVisible(sinkInputInfo *pulse.SinkInput) bool {
 	case "animation", "production", "phone":
 		//TODO: what's the meaning of this type? Should we filter this SinkInput?
 		return true
-	case "event", "a11y", "test":
+	case "event", "a11y", "test", "filter":
 		return false
 	default:
 		return true
@@ -305,6 +311,8 @@ func (s *SinkInput) update(sinkInputInfo *pulse.SinkInput) {
 	s.setPropSinkIndex(sinkInputInfo.Sink)
 	name := sinkInputInfo.PropList[PropAppName]
 	s.setPropName(name)
+	s.appKey = getAppVolumeKey(sinkInputInfo.PropList)
+	s.appBinary = sinkInputInfo.PropList[PropAppProcessBinary]
 	icon := sinkInputInfo.PropList[PropAppIconName]
 	correctedIcon, err := s.correctIcon(sinkInputInfo)
 	if err != nil {
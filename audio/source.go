@@ -51,6 +51,9 @@ type Source struct {
 	ActivePort Port
 	// 声卡的索引
 	Card uint32
+	// 是否开启了降噪和回声消除
+	NoiseReduction   bool
+	EchoCancellation bool
 
 	methods *struct {
 		SetVolume  func() `in:"value,isPlay"`
@@ -59,6 +62,9 @@ type Source struct {
 		SetMute    func() `in:"value"`
 		SetPort    func() `in:"name"`
 		GetMeter   func() `out:"meter"`
+
+		SetNoiseReduction   func() `in:"enabled"`
+		SetEchoCancellation func() `in:"enabled"`
 	}
 }
 
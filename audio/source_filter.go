@@ -0,0 +1,321 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+	dbus "pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// 输入降噪和回声消除。使用 pulseaudio 的 module-echo-cancel 在输入设备上创建一个虚拟的 source，
+// 并把它设置为默认输入设备。开启回声消除时还把模块创建的 sink 设置为默认输出设备，
+// 播放的声音经过这个 sink 才能作为回声消除的参考。
+
+const (
+	filterModuleName = "module-echo-cancel"
+	filterSourceName = "deepin_filter_source"
+	filterSinkName   = "deepin_filter_sink"
+
+	cmdPactl = "pactl"
+)
+
+// sourceFilter 是用户的设置，保存在 audio.json 中，Reset 时不重置
+type sourceFilter struct {
+	NoiseReduction   bool
+	EchoCancellation bool
+}
+
+func (f sourceFilter) enabled() bool {
+	return f.NoiseReduction || f.EchoCancellation
+}
+
+// filterModule 是已经加载的模块
+type filterModule struct {
+	index        int
+	sourceMaster string
+	sinkMaster   string
+	filter       sourceFilter
+}
+
+func getFilterModuleArgs(filter sourceFilter, sourceMaster, sinkMaster string) []string {
+	noiseSuppression := 0
+	if filter.NoiseReduction {
+		noiseSuppression = 1
+	}
+	return []string{
+		"source_name=" + filterSourceName,
+		"sink_name=" + filterSinkName,
+		"source_master=" + sourceMaster,
+		"sink_master=" + sinkMaster,
+		"use_master_format=1",
+		"aec_method=webrtc",
+		fmt.Sprintf(`aec_args="noise_suppression=%d analog_gain_control=0 digital_gain_control=1"`,
+			noiseSuppression),
+	}
+}
+
+func loadModule(name string, args []string) (int, error) {
+	cmdArgs := append([]string{"load-module", name}, args...)
+	out, err := exec.Command(cmdPactl, cmdArgs...).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+func unloadModule(index int) error {
+	return exec.Command(cmdPactl, "unload-module", strconv.Itoa(index)).Run()
+}
+
+// findModules 从 `pactl list short modules` 的输出中找到参数包括 arg 的模块 name
+func findModules(out, name, arg string) []int {
+	var result []int
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 || fields[1] != name || !strings.Contains(fields[2], arg) {
+			continue
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		result = append(result, index)
+	}
+	return result
+}
+
+// unloadStaleModules 卸载之前加载的降噪和均衡器模块，dde-daemon 重启后模块仍然存在于 pulseaudio 中
+func unloadStaleModules() {
+	out, err := exec.Command(cmdPactl, "list", "short", "modules").Output()
+	if err != nil {
+		logger.Warning("failed to list modules:", err)
+		return
+	}
+	indexes := findModules(string(out), filterModuleName, "source_name="+filterSourceName)
+	indexes = append(indexes, findModules(string(out), equalizerModuleName, "sink_name="+equalizerSinkName)...)
+	for _, index := range indexes {
+		logger.Debugf("unload stale module #%d", index)
+		err = unloadModule(index)
+		if err != nil {
+			logger.Warningf("failed to unload module #%d: %v", index, err)
+		}
+	}
+}
+
+func (a *Audio) getSourceFilter() sourceFilter {
+	a.sourceFilterMu.Lock()
+	v := a.sourceFilter
+	a.sourceFilterMu.Unlock()
+	return v
+}
+
+func (a *Audio) setSourceFilter(filter sourceFilter) {
+	a.sourceFilterMu.Lock()
+	a.sourceFilter = filter
+	a.sourceFilterMu.Unlock()
+}
+
+// getPhysicalSinkName 返回默认 sink 的名称，默认 sink 是模块创建的 sink 时返回它的主设备
+func (a *Audio) getPhysicalSinkName() string {
+	return a.getSinkMasterName(a.getDefaultSinkName())
+}
+
+// getPhysicalSourceName 返回默认 source 的名称，默认 source 是模块创建的 source 时返回它的主设备
+func (a *Audio) getPhysicalSourceName() string {
+	name := a.getDefaultSourceName()
+	if name == filterSourceName {
+		a.sourceFilterMu.Lock()
+		if a.filterModule != nil {
+			name = a.filterModule.sourceMaster
+		}
+		a.sourceFilterMu.Unlock()
+	}
+	return name
+}
+
+// updateSourceFilter 按照设置加载、重新加载或者卸载模块，sourceMaster 为空时使用当前的默认输入设备
+func (a *Audio) updateSourceFilter(sourceMaster string) error {
+	ctx := a.context()
+	if ctx == nil {
+		return errors.New("pulse context is nil")
+	}
+	if sourceMaster == "" {
+		sourceMaster = a.getPhysicalSourceName()
+	}
+	sinkMaster := a.getOutputSinkName()
+
+	a.sourceFilterMu.Lock()
+	defer a.sourceFilterMu.Unlock()
+	filter := a.sourceFilter
+	module := a.filterModule
+	if module != nil && a.getSourceInfoByName(filterSourceName) == nil {
+		// 主设备被移除时模块也会被卸载
+		module = nil
+		a.filterModule = nil
+	}
+
+	if !filter.enabled() {
+		if module == nil {
+			return nil
+		}
+		logger.Debugf("unload module #%d", module.index)
+		err := unloadModule(module.index)
+		if err != nil {
+			return xerrors.Errorf("failed to unload module #%d: %w", module.index, err)
+		}
+		a.filterModule = nil
+		// 卸载后 pulseaudio 会自己选择设备，恢复为原来的设备
+		ctx.SetDefaultSource(module.sourceMaster)
+		if module.filter.EchoCancellation {
+			ctx.SetDefaultSink(sinkMaster)
+		}
+		return nil
+	}
+
+	if sourceMaster == "" || strings.HasSuffix(sourceMaster, ".monitor") || sinkMaster == "" {
+		return fmt.Errorf("invalid master device, source: %q, sink: %q", sourceMaster, sinkMaster)
+	}
+
+	if module == nil || module.sourceMaster != sourceMaster ||
+		module.sinkMaster != sinkMaster || module.filter != filter {
+		if module != nil {
+			err := unloadModule(module.index)
+			if err != nil {
+				logger.Warningf("failed to unload module #%d: %v", module.index, err)
+			}
+			a.filterModule = nil
+		}
+		args := getFilterModuleArgs(filter, sourceMaster, sinkMaster)
+		logger.Debug("load module", filterModuleName, args)
+		index, err := loadModule(filterModuleName, args)
+		if err != nil {
+			return xerrors.Errorf("failed to load %s: %w", filterModuleName, err)
+		}
+		a.filterModule = &filterModule{
+			index:        index,
+			sourceMaster: sourceMaster,
+			sinkMaster:   sinkMaster,
+			filter:       filter,
+		}
+	}
+
+	if a.getDefaultSourceName() != filterSourceName {
+		ctx.SetDefaultSource(filterSourceName)
+	}
+	if filter.EchoCancellation {
+		if a.getDefaultSinkName() != filterSinkName {
+			ctx.SetDefaultSink(filterSinkName)
+		}
+	} else if a.getDefaultSinkName() == filterSinkName {
+		ctx.SetDefaultSink(sinkMaster)
+	}
+	return nil
+}
+
+// checkSourceFilter 在默认设备变化后让模块跟随新的设备
+func (a *Audio) checkSourceFilter() {
+	if !a.getSourceFilter().enabled() {
+		return
+	}
+	err := a.updateSourceFilter("")
+	if err != nil {
+		logger.Warning(err)
+	}
+	a.refreshSourceFilterProps()
+}
+
+// resetSourceFilterModule 卸载已经加载的模块，之后 checkSourceFilter 会重新加载
+func (a *Audio) resetSourceFilterModule() {
+	a.sourceFilterMu.Lock()
+	module := a.filterModule
+	a.filterModule = nil
+	a.sourceFilterMu.Unlock()
+	if module == nil {
+		return
+	}
+	err := unloadModule(module.index)
+	if err != nil {
+		// 主设备被卸载时模块也已经被卸载了
+		logger.Debugf("failed to unload module #%d: %v", module.index, err)
+	}
+}
+
+// initAudioFilters 在连接 pulseaudio 后按照设置加载均衡器、降噪和回声消除模块
+func (a *Audio) initAudioFilters() {
+	a.sourceFilterMu.Lock()
+	a.filterModule = nil
+	a.sourceFilterMu.Unlock()
+	a.equalizerMu.Lock()
+	a.equalizerModule = nil
+	a.equalizerTarget = ""
+	a.equalizerMu.Unlock()
+
+	unloadStaleModules()
+	a.updateOutputFilters()
+}
+
+func (a *Audio) refreshSourceFilterProps() {
+	a.sourceFilterMu.Lock()
+	filter := a.sourceFilter
+	var master string
+	if a.filterModule != nil {
+		master = a.filterModule.sourceMaster
+	}
+	a.sourceFilterMu.Unlock()
+
+	a.mu.Lock()
+	for _, source := range a.sources {
+		source.PropsMu.Lock()
+		active := source.Name == filterSourceName || (master != "" && source.Name == master)
+		source.setPropNoiseReduction(active && filter.NoiseReduction)
+		source.setPropEchoCancellation(active && filter.EchoCancellation)
+		source.PropsMu.Unlock()
+	}
+	a.mu.Unlock()
+}
+
+func (a *Audio) setSourceFilterOption(source *Source, fn func(filter *sourceFilter)) *dbus.Error {
+	source.PropsMu.RLock()
+	sourceMaster := source.Name
+	source.PropsMu.RUnlock()
+	if sourceMaster == filterSourceName {
+		// 使用现在的主设备
+		sourceMaster = ""
+	}
+
+	old := a.getSourceFilter()
+	filter := old
+	fn(&filter)
+	a.setSourceFilter(filter)
+	err := a.updateSourceFilter(sourceMaster)
+	if err != nil {
+		a.setSourceFilter(old)
+	}
+	a.refreshSourceFilterProps()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	a.saveConfig()
+	return nil
+}
+
+// SetNoiseReduction 开启或者关闭输入降噪，开启后默认输入设备变为经过降噪的虚拟设备
+func (s *Source) SetNoiseReduction(enabled bool) *dbus.Error {
+	logger.Debugf("Source #%d SetNoiseReduction %v", s.index, enabled)
+	return s.audio.setSourceFilterOption(s, func(filter *sourceFilter) {
+		filter.NoiseReduction = enabled
+	})
+}
+
+// SetEchoCancellation 开启或者关闭回声消除，开启后默认输入和输出设备都变为模块创建的虚拟设备
+func (s *Source) SetEchoCancellation(enabled bool) *dbus.Error {
+	logger.Debugf("Source #%d SetEchoCancellation %v", s.index, enabled)
+	return s.audio.setSourceFilterOption(s, func(filter *sourceFilter) {
+		filter.EchoCancellation = enabled
+	})
+}
@@ -0,0 +1,37 @@
+package audio
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSourceFilter(t *testing.T) {
+	Convey("getFilterModuleArgs", t, func(c C) {
+		args := getFilterModuleArgs(sourceFilter{NoiseReduction: true},
+			"alsa_input.pci-0000_00_1f.3.analog-stereo", "alsa_output.pci-0000_00_1f.3.analog-stereo")
+		c.So(args, ShouldContain, "source_name="+filterSourceName)
+		c.So(args, ShouldContain, "source_master=alsa_input.pci-0000_00_1f.3.analog-stereo")
+		c.So(args, ShouldContain, "sink_master=alsa_output.pci-0000_00_1f.3.analog-stereo")
+		c.So(args, ShouldContain, `aec_args="noise_suppression=1 analog_gain_control=0 digital_gain_control=1"`)
+
+		args = getFilterModuleArgs(sourceFilter{EchoCancellation: true}, "a", "b")
+		c.So(args, ShouldContain, `aec_args="noise_suppression=0 analog_gain_control=0 digital_gain_control=1"`)
+	})
+
+	Convey("sourceFilter.enabled", t, func(c C) {
+		c.So(sourceFilter{}.enabled(), ShouldBeFalse)
+		c.So(sourceFilter{NoiseReduction: true}.enabled(), ShouldBeTrue)
+		c.So(sourceFilter{EchoCancellation: true}.enabled(), ShouldBeTrue)
+	})
+
+	Convey("findModules", t, func(c C) {
+		out := "0\tmodule-device-restore\t\n" +
+			"24\tmodule-echo-cancel\tsource_name=deepin_filter_source sink_name=deepin_filter_sink\n" +
+			"25\tmodule-echo-cancel\tsource_name=other\n" +
+			"26\tmodule-switch-on-connect\t\n"
+		c.So(findModules(out, filterModuleName, "source_name="+filterSourceName), ShouldResemble, []int{24})
+		c.So(findModules(out, filterModuleName, "sink_name=none"), ShouldBeNil)
+		c.So(findModules("", filterModuleName, "source_name="+filterSourceName), ShouldBeNil)
+	})
+}
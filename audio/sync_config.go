@@ -60,6 +60,8 @@ type syncSoundEffect struct {
 type syncData struct {
 	Version     string           `json:"version"`
 	SoundEffect *syncSoundEffect `json:"soundeffect"`
+	// 均衡器的预设和每个端口的设置
+	Equalizer *equalizerConfig `json:"equalizer,omitempty"`
 }
 
 type syncConfig struct {
@@ -99,6 +101,7 @@ func (sc *syncConfig) Get() (interface{}, error) {
 			TrashEmpty:              s.GetBoolean(gsKeyTrashEmpty),
 			XDeepinAppSentToDesktop: s.GetBoolean(gsKeyXDeepinAppSentToDesktop),
 		},
+		Equalizer: sc.a.equalizer.dump(),
 	}, nil
 }
 
@@ -136,6 +139,11 @@ func (sc *syncConfig) Set(data []byte) error {
 		s.SetBoolean(gsKeyXDeepinAppSentToDesktop, soundEffect.XDeepinAppSentToDesktop)
 		s.Unref()
 	}
+	if info.Equalizer != nil {
+		sc.a.equalizer.load(info.Equalizer)
+		sc.a.saveConfig()
+		sc.a.updateOutputFilters()
+	}
 	return nil
 }
 
@@ -24,9 +24,11 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	"pkg.deepin.io/dde/api/session"
 	"pkg.deepin.io/dde/daemon/calltrace"
+	"pkg.deepin.io/dde/daemon/common/dsync"
 	"pkg.deepin.io/dde/daemon/loader"
 	"pkg.deepin.io/gir/gio-2.0"
 	"pkg.deepin.io/gir/glib-2.0"
@@ -111,6 +113,11 @@ func (s *SessionDaemon) register(service *dbusutil.Service) error {
 		s.log.Fatal(err)
 	}
 
+	err = service.Export(dsync.LocalSyncPath, dsync.NewLocalSync())
+	if err != nil {
+		s.log.Fatal(err)
+	}
+
 	err = service.RequestName(dbusServiceName)
 	if err != nil {
 		return err
@@ -261,7 +268,34 @@ func (s *SessionDaemon) StartPart2() *dbus.Error {
 	}
 	// start part2
 	err := loader.EnableModules(s.part2EnabledModules, s.part2DisabledModules, 0)
-	return dbusutil.ToError(err)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	s.printStartupProfile()
+	return nil
+}
+
+// printStartupProfile 在指定了 --profile-startup 时打印各个模块的启动耗时
+func (s *SessionDaemon) printStartupProfile() {
+	if !*s.flags.ProfileStartup {
+		return
+	}
+
+	var begin, end time.Time
+	fmt.Printf("%-20s %12s  %s\n", "MODULE", "DURATION", "ERROR")
+	for _, info := range loader.GetStartupProfile() {
+		fmt.Printf("%-20s %12s  %s\n", info.Name, info.Duration, info.Error)
+		if info.StartTime.IsZero() {
+			continue
+		}
+		if begin.IsZero() || info.StartTime.Before(begin) {
+			begin = info.StartTime
+		}
+		if finish := info.StartTime.Add(info.Duration); finish.After(end) {
+			end = finish
+		}
+	}
+	fmt.Println("total:", end.Sub(begin))
 }
 
 func filterList(origin, condition []string) []string {
@@ -22,4 +22,5 @@ package main
 type Flags struct {
 	IgnoreMissingModules *bool
 	ForceStart           *bool
+	ProfileStartup       *bool
 }
@@ -100,6 +100,7 @@ func main() {
 	flags := new(Flags)
 	flags.IgnoreMissingModules = cmd.Flag("Ignore", "ignore missing modules, --no-ignore to revert it.").Short('i').Default("true").Bool()
 	flags.ForceStart = cmd.Flag("force", "Force start disabled module.").Short('f').Bool()
+	flags.ProfileStartup = cmd.Flag("profile-startup", "Print the start time of each module.").Bool()
 
 	cmd.Command("auto", "Automatically get enabled and disabled modules from settings.").Default()
 	enablingModules := cmd.Command("enable", "Enable modules and their dependencies, ignore settings.").Arg("module", "module names.").Required().Strings()
@@ -126,6 +127,10 @@ func main() {
 	}
 
 	loader.SetService(service)
+	err = loader.ExportManager(service)
+	if err != nil {
+		logger.Warning("failed to export modules manager:", err)
+	}
 
 	logger.SetLogLevel(log.LevelInfo)
 	if cmd.IsLogLevelNone() &&
@@ -161,6 +166,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 由 session 启动时 part2 的模块在 StartPart2 中启动，在那里打印
+	if needRunMainLoop && !(subCmd == "auto" && hasDDECookie) {
+		app.printStartupProfile()
+	}
+
 	err = migrateUserEnv()
 	if err != nil {
 		logger.Warning("failed to migrate user env:", err)
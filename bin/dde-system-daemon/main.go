@@ -103,6 +103,10 @@ func main() {
 	}
 
 	loader.SetService(service)
+	err = loader.ExportManager(service)
+	if err != nil {
+		logger.Warning("failed to export modules manager:", err)
+	}
 	loader.StartAll()
 	defer loader.StopAll()
 
@@ -0,0 +1,98 @@
+package bluetooth
+
+import (
+	dbus "pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// 读取设备 org.bluez.Battery1 接口的电量，电量低时发送通知。
+
+const (
+	bluezBatteryDBusInterface = "org.bluez.Battery1"
+
+	// 电量不高于这个值时发送通知
+	lowBatteryLevel = 20
+)
+
+func getDeviceBattery(conn *dbus.Conn, dpath dbus.ObjectPath) int32 {
+	v, err := conn.Object(bluezDBusServiceName, dpath).GetProperty(bluezBatteryDBusInterface + ".Percentage")
+	if err != nil {
+		return -1
+	}
+	return getBatteryValue(v)
+}
+
+func getBatteryValue(v dbus.Variant) int32 {
+	percentage, ok := v.Value().(byte)
+	if !ok {
+		return -1
+	}
+	return int32(percentage)
+}
+
+func (d *device) setBattery(value int32) {
+	d.mu.Lock()
+	if d.Battery == value {
+		d.mu.Unlock()
+		return
+	}
+	d.Battery = value
+	var needNotify bool
+	if value < 0 || value > lowBatteryLevel {
+		d.lowBatteryNotified = false
+	} else if !d.lowBatteryNotified {
+		// 电量回升到 lowBatteryLevel 以上之前只通知一次
+		d.lowBatteryNotified = true
+		needNotify = true
+	}
+	d.mu.Unlock()
+
+	logger.Debugf("%s Battery: %d", d, value)
+	d.notifyDevicePropertiesChanged()
+	if needNotify {
+		notifyLowBattery(d.Alias, value)
+	}
+}
+
+// initBatterySignals 监听设备的电量变化，Battery1 接口的添加和删除在 handleInterfacesAdded 和 handleInterfacesRemoved 中处理
+func (b *Bluetooth) initBatterySignals() {
+	err := dbusutil.NewMatchRuleBuilder().
+		Type("signal").
+		Sender(bluezDBusServiceName).
+		Interface("org.freedesktop.DBus.Properties").
+		Member("PropertiesChanged").
+		ArgNamespace(0, bluezBatteryDBusInterface).Build().
+		AddTo(b.systemSigLoop.Conn())
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	b.systemSigLoop.AddHandler(&dbusutil.SignalRule{
+		Name: "org.freedesktop.DBus.Properties.PropertiesChanged",
+	}, func(sig *dbus.Signal) {
+		if len(sig.Body) != 3 {
+			return
+		}
+		ifc, ok := sig.Body[0].(string)
+		if !ok || ifc != bluezBatteryDBusInterface {
+			return
+		}
+		props, ok := sig.Body[1].(map[string]dbus.Variant)
+		if !ok {
+			return
+		}
+		v, ok := props["Percentage"]
+		if !ok {
+			return
+		}
+		b.updateDeviceBattery(sig.Path, getBatteryValue(v))
+	})
+}
+
+func (b *Bluetooth) updateDeviceBattery(dpath dbus.ObjectPath, value int32) {
+	d, err := b.getDevice(dpath)
+	if err != nil {
+		return
+	}
+	d.setBattery(value)
+}
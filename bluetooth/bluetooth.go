@@ -58,7 +58,7 @@ const (
 
 type dbusObjectData map[string]dbus.Variant
 
-//go:generate dbusutil-gen -type Bluetooth bluetooth.go
+//go:generate dbusutil-gen -type Bluetooth,transfer -import pkg.deepin.io/lib/dbus1 bluetooth.go transfer.go
 
 type Bluetooth struct {
 	service       *dbusutil.Service
@@ -69,6 +69,16 @@ type Bluetooth struct {
 	apiDevice     *apidevice.Device
 	agent         *agent
 
+	// obex
+	sessionSigLoop    *dbusutil.SignalLoop
+	sessionDBusDaemon *ofdbus.DBus
+	obexAgent         *obexAgent
+	transfersMu       sync.Mutex
+	transfers         []*transfer
+	transferId        uint32
+	// 还没有对应的传输时收到的结束状态，SendFile 返回之前传输就可能结束
+	obexFinished map[dbus.ObjectPath]string
+
 	// adapter
 	adaptersLock sync.Mutex
 	adapters     map[dbus.ObjectPath]*adapter
@@ -98,6 +108,13 @@ type Bluetooth struct {
 		SetAdapterDiscoverable        func() `in:"adapter,discoverable"`
 		SetAdapterDiscovering         func() `in:"adapter,discovering"`
 		SetAdapterDiscoverableTimeout func() `in:"adapter,timeout"`
+
+		SetDeviceAutoReconnect func() `in:"device,policy"`
+
+		SendFiles           func() `in:"device,files" out:"transfers"`
+		ConfirmTransfer     func() `in:"transfer,accept"`
+		GetReceiveDirectory func() `out:"dir"`
+		SetReceiveDirectory func() `in:"dir"`
 	}
 
 	signals *struct {
@@ -145,6 +162,28 @@ type Bluetooth struct {
 		Cancelled struct {
 			device dbus.ObjectPath
 		}
+
+		// RequestTransfer you should call ConfirmTransfer with accept
+		RequestTransfer struct {
+			transfer dbus.ObjectPath
+			device   dbus.ObjectPath
+			name     string
+			size     uint64
+		}
+
+		// file transfer signals
+		TransferAdded, TransferCancelled struct {
+			transfer dbus.ObjectPath
+		}
+		TransferProgress struct {
+			transfer    dbus.ObjectPath
+			transferred uint64
+			size        uint64
+		}
+		TransferRemoved struct {
+			transfer dbus.ObjectPath
+			status   string
+		}
 	}
 }
 
@@ -156,8 +195,9 @@ func newBluetooth(service *dbusutil.Service) (b *Bluetooth) {
 	}
 
 	b = &Bluetooth{
-		service:       service,
-		systemSigLoop: dbusutil.NewSignalLoop(systemConn, 10),
+		service:        service,
+		systemSigLoop:  dbusutil.NewSignalLoop(systemConn, 10),
+		sessionSigLoop: dbusutil.NewSignalLoop(service.Conn(), 10),
 	}
 	b.adapters = make(map[dbus.ObjectPath]*adapter)
 	return
@@ -165,6 +205,7 @@ func newBluetooth(service *dbusutil.Service) (b *Bluetooth) {
 
 func (b *Bluetooth) destroy() {
 	b.agent.destroy()
+	b.destroyObex()
 
 	b.objectManager.RemoveHandler(proxy.RemoveAllHandlers)
 	b.sysDBusDaemon.RemoveHandler(proxy.RemoveAllHandlers)
@@ -214,12 +255,15 @@ func (b *Bluetooth) init() {
 	b.objectManager.ConnectInterfacesAdded(b.handleInterfacesAdded)
 	b.objectManager.ConnectInterfacesRemoved(b.handleInterfacesRemoved)
 
+	b.initBatterySignals()
+
 	b.agent.init()
 	b.loadObjects()
+	b.initObex()
 
 	b.config.clearSpareConfig(b)
 	b.config.save()
-	go b.tryConnectPairedDevices()
+	go b.tryConnectPairedDevices(true)
 	// move to power module
 	// b.wakeupWorkaround()
 }
@@ -291,6 +335,11 @@ func (b *Bluetooth) handleInterfacesAdded(path dbus.ObjectPath, data map[string]
 	if _, ok := data[bluezDeviceDBusInterface]; ok {
 		b.addDevice(path)
 	}
+	if props, ok := data[bluezBatteryDBusInterface]; ok {
+		if v, ok := props["Percentage"]; ok {
+			b.updateDeviceBattery(path, getBatteryValue(v))
+		}
+	}
 }
 
 func (b *Bluetooth) handleInterfacesRemoved(path dbus.ObjectPath, interfaces []string) {
@@ -299,6 +348,8 @@ func (b *Bluetooth) handleInterfacesRemoved(path dbus.ObjectPath, interfaces []s
 	}
 	if isStringInArray(bluezDeviceDBusInterface, interfaces) {
 		b.removeDevice(path)
+	} else if isStringInArray(bluezBatteryDBusInterface, interfaces) {
+		b.updateDeviceBattery(path, -1)
 	}
 }
 
@@ -338,13 +389,14 @@ func (b *Bluetooth) addDevice(dpath dbus.ObjectPath) {
 	}
 
 	b.config.addDeviceConfig(d.getAddress())
+	d.AutoReconnect = b.config.getDeviceConfigAutoReconnect(d.getAddress())
 
 	b.devicesLock.Lock()
 	b.devices[d.AdapterPath] = append(b.devices[d.AdapterPath], d)
 	b.devicesLock.Unlock()
 
 	connected := b.config.getDeviceConfigConnected(d.getAddress())
-	if connected {
+	if connected && d.AutoReconnect != autoReconnectNever {
 		time.AfterFunc(25*time.Second, func() {
 			d, _ := b.getDevice(dpath)
 			if d == nil {
@@ -587,9 +639,14 @@ func (b *Bluetooth) updateState() {
 	b.PropsMu.Unlock()
 }
 
-func (b *Bluetooth) tryConnectPairedDevices() {
+// tryConnectPairedDevices 自动连接已经配对的设备，login 表示是否为登录时的自动连接，否则为唤醒后的自动连接
+func (b *Bluetooth) tryConnectPairedDevices(login bool) {
 	var devList = b.getPairedDeviceList()
 	for _, dev := range devList {
+		if !canAutoConnect(dev.getAutoReconnect(), login) {
+			logger.Debug("skip auto connect device:", dev.String())
+			continue
+		}
 		logger.Info("[DEBUG] Auto connect device:", dev.Path)
 		obj, err := b.getDevice(dev.Path)
 		if err != nil {
@@ -679,6 +736,6 @@ func (b *Bluetooth) wakeupWorkaround() {
 			}
 			_ = aobj.core.Discoverable().Set(0, b.config.Discoverable)
 		}
-		b.tryConnectPairedDevices()
+		b.tryConnectPairedDevices(false)
 	})
 }
@@ -2,6 +2,8 @@ package bluetooth
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	dbus "pkg.deepin.io/lib/dbus1"
 	"pkg.deepin.io/lib/dbusutil"
@@ -65,6 +67,75 @@ func (b *Bluetooth) SetDeviceTrusted(dpath dbus.ObjectPath, trusted bool) *dbus.
 	return nil
 }
 
+// SetDeviceAutoReconnect 设置设备的自动重连策略，policy 为 always、login 或 never
+func (b *Bluetooth) SetDeviceAutoReconnect(dpath dbus.ObjectPath, policy string) *dbus.Error {
+	logger.Debugf("SetDeviceAutoReconnect %q %q", dpath, policy)
+	if !isAutoReconnectPolicyValid(policy) {
+		return dbusutil.ToError(fmt.Errorf("invalid auto reconnect policy %q", policy))
+	}
+	d, err := b.getDevice(dpath)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	b.config.setDeviceConfigAutoReconnect(d.getAddress(), policy)
+	d.mu.Lock()
+	d.AutoReconnect = policy
+	d.mu.Unlock()
+	if policy != autoReconnectAlways {
+		d.stopReconnect()
+	}
+	d.notifyDevicePropertiesChanged()
+	return nil
+}
+
+// SendFiles 把文件发送到设备，files 为文件的绝对路径，返回每个文件的传输对象的路径，
+// 传输在后台进行，进度通过 TransferProgress 信号和传输对象的属性获取。
+func (b *Bluetooth) SendFiles(dpath dbus.ObjectPath, files []string) ([]dbus.ObjectPath, *dbus.Error) {
+	logger.Debugf("SendFiles %q %q", dpath, files)
+	transfers, err := b.sendFiles(dpath, files)
+	if err != nil {
+		logger.Warning(err)
+		return nil, dbusutil.ToError(err)
+	}
+	return transfers, nil
+}
+
+// ConfirmTransfer should call when you receive RequestTransfer signal
+func (b *Bluetooth) ConfirmTransfer(transferPath dbus.ObjectPath, accept bool) *dbus.Error {
+	logger.Infof("ConfirmTransfer %q %v", transferPath, accept)
+	t, err := b.getTransfer(transferPath)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = t.confirm(accept)
+	return dbusutil.ToError(err)
+}
+
+// GetReceiveDirectory 返回保存接收的文件的目录
+func (b *Bluetooth) GetReceiveDirectory() (string, *dbus.Error) {
+	return b.config.getReceiveDirectory(), nil
+}
+
+// SetReceiveDirectory 设置保存接收的文件的目录，dir 为空时使用下载目录
+func (b *Bluetooth) SetReceiveDirectory(dir string) *dbus.Error {
+	logger.Debugf("SetReceiveDirectory %q", dir)
+	if dir != "" {
+		if !filepath.IsAbs(dir) {
+			return dbusutil.ToError(fmt.Errorf("%q is not an absolute path", dir))
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
+		if !info.IsDir() {
+			return dbusutil.ToError(fmt.Errorf("%q is not a directory", dir))
+		}
+		dir = filepath.Clean(dir)
+	}
+	b.config.setReceiveDirectory(dir)
+	return nil
+}
+
 // GetDevices return all device objects that marshaled by json.
 func (b *Bluetooth) GetDevices(apath dbus.ObjectPath) (devicesJSON string, err *dbus.Error) {
 	b.devicesLock.Lock()
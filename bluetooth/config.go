@@ -32,6 +32,9 @@ type config struct {
 	Devices  map[string]*deviceConfig  // use adapter address/device address as key
 
 	Discoverable bool `json:"discoverable"`
+
+	// 接收文件的目录，为空时使用下载目录
+	ReceiveDirectory string `json:"receive_directory,omitempty"`
 }
 
 type adapterConfig struct {
@@ -40,6 +43,26 @@ type adapterConfig struct {
 
 type deviceConfig struct {
 	Connected bool
+	// 自动重连的策略，为空时同 autoReconnectAlways
+	AutoReconnect string `json:",omitempty"`
+}
+
+// 设备的自动重连策略
+const (
+	// 登录、唤醒后自动连接，意外断开后按照退避时间重连
+	autoReconnectAlways = "always"
+	// 只在登录后自动连接
+	autoReconnectLogin = "login"
+	// 不自动连接
+	autoReconnectNever = "never"
+)
+
+func isAutoReconnectPolicyValid(policy string) bool {
+	switch policy {
+	case autoReconnectAlways, autoReconnectLogin, autoReconnectNever:
+		return true
+	}
+	return false
 }
 
 func newConfig() (c *config) {
@@ -228,3 +251,47 @@ func (c *config) setDeviceConfigConnected(address string, connected bool) {
 	c.save()
 	return
 }
+
+func (c *config) getDeviceConfigAutoReconnect(address string) string {
+	dc, ok := c.getDeviceConfig(address)
+	if !ok {
+		return autoReconnectAlways
+	}
+
+	c.core.Lock()
+	defer c.core.Unlock()
+	if dc.AutoReconnect == "" {
+		return autoReconnectAlways
+	}
+	return dc.AutoReconnect
+}
+
+func (c *config) setDeviceConfigAutoReconnect(address string, policy string) {
+	dc, ok := c.getDeviceConfig(address)
+	if !ok {
+		return
+	}
+
+	c.core.Lock()
+	dc.AutoReconnect = policy
+	c.core.Unlock()
+
+	c.save()
+}
+
+func (c *config) getReceiveDirectory() string {
+	c.core.Lock()
+	dir := c.ReceiveDirectory
+	c.core.Unlock()
+	if dir == "" {
+		dir = getDefaultReceiveDirectory()
+	}
+	return dir
+}
+
+func (c *config) setReceiveDirectory(dir string) {
+	c.core.Lock()
+	c.ReceiveDirectory = dir
+	c.core.Unlock()
+	c.save()
+}
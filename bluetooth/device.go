@@ -74,6 +74,10 @@ type device struct {
 	Icon    string
 	RSSI    int16
 	Address string
+	// 电量百分比，设备不支持时为 -1
+	Battery int32
+	// 自动重连的策略
+	AutoReconnect string
 
 	connected         bool
 	connectedTime     time.Time
@@ -87,6 +91,11 @@ type device struct {
 	mu                sync.Mutex
 	confirmation      chan bool
 	pairingFailedTime time.Time
+
+	lowBatteryNotified bool
+	// 意外断开后重连的定时器和已经重连的次数
+	reconnectTimer *time.Timer
+	reconnectTimes int
 }
 
 type connectPhase uint32
@@ -192,6 +201,7 @@ func newDevice(systemSigLoop *dbusutil.SignalLoop, dpath dbus.ObjectPath) (d *de
 	d.ServicesResolved, _ = d.core.ServicesResolved().Get(0)
 	d.Icon, _ = d.core.Icon().Get(0)
 	d.RSSI, _ = d.core.RSSI().Get(0)
+	d.Battery = getDeviceBattery(systemConn, dpath)
 	d.updateState()
 	d.disconnectChan = make(chan struct{})
 	d.core.InitSignalExt(systemSigLoop, true)
@@ -200,6 +210,7 @@ func newDevice(systemSigLoop *dbusutil.SignalLoop, dpath dbus.ObjectPath) (d *de
 }
 
 func (d *device) destroy() {
+	d.stopReconnect()
 	d.core.RemoveHandler(proxy.RemoveAllHandlers)
 }
 
@@ -244,12 +255,15 @@ func (d *device) connectProperties() {
 			return
 		}
 		logger.Debugf("%s Connected: %v", d, connected)
+		d.mu.Lock()
 		d.connected = connected
+		d.mu.Unlock()
 
 		needNotify := true
 
 		if connected {
 			d.connectedTime = time.Now()
+			d.stopReconnect()
 		} else {
 			// when disconnected quickly after connecting, automatically try to connect
 			sinceConnected := time.Since(d.connectedTime)
@@ -280,6 +294,10 @@ func (d *device) connectProperties() {
 		if needNotify {
 			d.notifyConnectedChanged()
 		}
+		if !connected && needNotify {
+			// 不是调用 Disconnect 断开的
+			d.startReconnect()
+		}
 		return
 	})
 	if err != nil {
@@ -326,7 +344,9 @@ func (d *device) connectProperties() {
 		if !hasValue {
 			return
 		}
+		d.mu.Lock()
 		d.Paired = value
+		d.mu.Unlock()
 		logger.Debugf("%s Paired: %v", d, value)
 		d.notifyDevicePropertiesChanged()
 	})
@@ -579,6 +599,7 @@ func (d *device) Connect() {
 
 func (d *device) Disconnect() {
 	logger.Debugf("%s call Disconnect()", d)
+	d.stopReconnect()
 
 	disconnectPhase := d.getDisconnectPhase()
 	if disconnectPhase != disconnectPhaseNone {
@@ -34,6 +34,7 @@ type daemon struct {
 func newBluetoothDaemon(logger *log.Logger) *daemon {
 	var d = new(daemon)
 	d.ModuleBase = loader.NewModuleBase("bluetooth", d, logger)
+	d.SetStartConcurrently(true)
 	return d
 }
 
@@ -61,7 +62,7 @@ func HandlePrepareForSleep(sleep bool) {
 		}
 		_ = aobj.core.Discoverable().Set(0, globalBluetooth.config.Discoverable)
 	}
-	globalBluetooth.tryConnectPairedDevices()
+	globalBluetooth.tryConnectPairedDevices(false)
 }
 
 func (*daemon) Start() error {
@@ -0,0 +1,499 @@
+package bluetooth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	ofdbus "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.dbus"
+	dbus "pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/dbusutil/proxy"
+	"pkg.deepin.io/lib/xdg/userdir"
+)
+
+// 使用 obexd 通过 OPP 发送和接收文件，obexd 在 session bus 上。
+// 接收时 obexd 调用 obexAgent 的 AuthorizePush，发送 RequestTransfer 信号后等待用户调用 ConfirmTransfer，
+// 同意后返回保存文件的路径；发送时为每次 SendFiles 创建一个 opp 会话，每个文件调用一次 SendFile。
+
+const (
+	obexDBusServiceName = "org.bluez.obex"
+	obexDBusPath        = "/org/bluez/obex"
+
+	obexAgentManagerDBusInterface = obexDBusServiceName + ".AgentManager1"
+	obexClientDBusInterface       = obexDBusServiceName + ".Client1"
+	obexSessionDBusInterface      = obexDBusServiceName + ".Session1"
+	obexObjectPushDBusInterface   = obexDBusServiceName + ".ObjectPush1"
+	obexTransferDBusInterface     = obexDBusServiceName + ".Transfer1"
+
+	obexAgentDBusPath      = dbusPath + "/ObexAgent"
+	obexAgentDBusInterface = obexDBusServiceName + ".Agent1"
+
+	// obexd 等待 AuthorizePush 返回的时间也是 60 秒，超时后会调用 Cancel
+	obexConfirmTimeout = 60 * time.Second
+)
+
+type obexAgent struct {
+	b *Bluetooth
+
+	mu sync.Mutex
+	// 正在等待用户确认的接收，obexd 同时只会有一个请求
+	pending *transfer
+
+	methods *struct {
+		AuthorizePush func() `in:"transfer" out:"filename"`
+	}
+}
+
+func (*obexAgent) GetInterfaceName() string {
+	return obexAgentDBusInterface
+}
+
+func (a *obexAgent) Release() *dbus.Error {
+	logger.Info("obex agent Release()")
+	return nil
+}
+
+// AuthorizePush 在收到文件时被 obexd 调用，返回保存文件的路径，返回错误时拒绝接收
+func (a *obexAgent) AuthorizePush(transferPath dbus.ObjectPath) (string, *dbus.Error) {
+	logger.Info("AuthorizePush()", transferPath)
+	filename, err := a.b.authorizePush(transferPath)
+	if err != nil {
+		logger.Warning("reject push:", err)
+		return "", dbusutil.ToError(err)
+	}
+	return filename, nil
+}
+
+// Cancel 在请求超时或者对方取消时被 obexd 调用
+func (a *obexAgent) Cancel() *dbus.Error {
+	logger.Info("obex agent Cancel()")
+	a.mu.Lock()
+	t := a.pending
+	a.mu.Unlock()
+	if t != nil {
+		_ = t.confirm(false)
+	}
+	return nil
+}
+
+func (a *obexAgent) setPending(t *transfer) {
+	a.mu.Lock()
+	a.pending = t
+	a.mu.Unlock()
+}
+
+func getDefaultReceiveDirectory() string {
+	return userdir.Get(userdir.Download)
+}
+
+// getReceiveFilename 返回 dir 中还不存在的文件路径，文件已经存在时在名称后面加上序号，如 a(1).txt
+func getReceiveFilename(dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	filename := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		_, err := os.Lstat(filename)
+		if err != nil {
+			return filename
+		}
+		filename = filepath.Join(dir, fmt.Sprintf("%s(%d)%s", base, i, ext))
+	}
+}
+
+// getReceiveName 去掉对方发送的文件名中的路径，避免写到接收目录之外
+func getReceiveName(name string) (string, error) {
+	name = filepath.Base(name)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return name, nil
+}
+
+func (b *Bluetooth) initObex() {
+	b.sessionSigLoop.Start()
+
+	b.obexAgent = &obexAgent{b: b}
+	err := b.service.Export(obexAgentDBusPath, b.obexAgent)
+	if err != nil {
+		logger.Warning("failed to export obex agent:", err)
+		return
+	}
+
+	b.sessionDBusDaemon = ofdbus.NewDBus(b.service.Conn())
+	b.sessionDBusDaemon.InitSignalExt(b.sessionSigLoop, true)
+	_, err = b.sessionDBusDaemon.ConnectNameOwnerChanged(b.handleObexNameOwnerChanged)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	err = dbusutil.NewMatchRuleBuilder().
+		Type("signal").
+		Sender(obexDBusServiceName).
+		Interface("org.freedesktop.DBus.Properties").
+		Member("PropertiesChanged").
+		ArgNamespace(0, obexTransferDBusInterface).Build().
+		AddTo(b.service.Conn())
+	if err != nil {
+		logger.Warning(err)
+	}
+	b.sessionSigLoop.AddHandler(&dbusutil.SignalRule{
+		Name: "org.freedesktop.DBus.Properties.PropertiesChanged",
+	}, func(sig *dbus.Signal) {
+		if len(sig.Body) != 3 {
+			return
+		}
+		ifc, ok := sig.Body[0].(string)
+		if !ok || ifc != obexTransferDBusInterface {
+			return
+		}
+		props, ok := sig.Body[1].(map[string]dbus.Variant)
+		if !ok {
+			return
+		}
+		b.handleObexTransferPropertiesChanged(sig.Path, props)
+	})
+
+	b.registerObexAgent()
+}
+
+func (b *Bluetooth) destroyObex() {
+	if b.obexAgent == nil {
+		return
+	}
+	b.removeAllTransfers(transferStatusCancelled)
+
+	err := b.service.Conn().Object(obexDBusServiceName, obexDBusPath).
+		Call(obexAgentManagerDBusInterface+".UnregisterAgent", 0, dbus.ObjectPath(obexAgentDBusPath)).Err
+	if err != nil {
+		logger.Warning(err)
+	}
+	err = b.service.StopExport(b.obexAgent)
+	if err != nil {
+		logger.Warning(err)
+	}
+	if b.sessionDBusDaemon != nil {
+		b.sessionDBusDaemon.RemoveHandler(proxy.RemoveAllHandlers)
+	}
+	b.sessionSigLoop.Stop()
+}
+
+// registerObexAgent 注册 obex agent，obexd 没有运行时会被 dbus 启动
+func (b *Bluetooth) registerObexAgent() {
+	err := b.service.Conn().Object(obexDBusServiceName, obexDBusPath).
+		Call(obexAgentManagerDBusInterface+".RegisterAgent", 0, dbus.ObjectPath(obexAgentDBusPath)).Err
+	if err != nil {
+		logger.Warning("failed to register obex agent:", err)
+	}
+}
+
+func (b *Bluetooth) handleObexNameOwnerChanged(name, oldOwner, newOwner string) {
+	if name != obexDBusServiceName {
+		return
+	}
+	if newOwner != "" {
+		logger.Info("obexd is starting")
+		b.registerObexAgent()
+	} else {
+		logger.Info("obexd stopped")
+		b.removeAllTransfers(transferStatusError)
+	}
+}
+
+func (b *Bluetooth) handleObexTransferPropertiesChanged(obexPath dbus.ObjectPath, props map[string]dbus.Variant) {
+	status, hasStatus := getObexTransferStatus(props)
+	b.transfersMu.Lock()
+	t := b.getTransferByObexPath(obexPath)
+	if t == nil {
+		if isTransferFinished(status) {
+			// 发送的传输可能在 SendFile 返回之前结束，由 doSendFiles 取出
+			b.saveObexFinished(obexPath, status)
+		}
+		b.transfersMu.Unlock()
+		return
+	}
+	b.transfersMu.Unlock()
+
+	updateTransferProgress(t, props)
+	if hasStatus {
+		t.updateStatus(status)
+	}
+}
+
+const maxObexFinished = 16
+
+// saveObexFinished 需要持有 b.transfersMu，其他程序的传输也会被记录，数量超过上限时清空
+func (b *Bluetooth) saveObexFinished(obexPath dbus.ObjectPath, status string) {
+	if b.obexFinished == nil || len(b.obexFinished) >= maxObexFinished {
+		b.obexFinished = make(map[dbus.ObjectPath]string)
+	}
+	b.obexFinished[obexPath] = status
+}
+
+func getObexTransferStatus(props map[string]dbus.Variant) (string, bool) {
+	v, ok := props["Status"]
+	if !ok {
+		return "", false
+	}
+	status, _ := v.Value().(string)
+	return status, true
+}
+
+func updateTransferProgress(t *transfer, props map[string]dbus.Variant) {
+	v, ok := props["Transferred"]
+	if !ok {
+		return
+	}
+	transferred, _ := v.Value().(uint64)
+	var size uint64
+	if v, ok := props["Size"]; ok {
+		size, _ = v.Value().(uint64)
+	}
+	t.updateProgress(size, transferred)
+}
+
+func (b *Bluetooth) getObexProperties(path dbus.ObjectPath, ifc string) (map[string]dbus.Variant, error) {
+	var props map[string]dbus.Variant
+	err := b.service.Conn().Object(obexDBusServiceName, path).
+		Call("org.freedesktop.DBus.Properties.GetAll", 0, ifc).Store(&props)
+	return props, err
+}
+
+// getObexSessionDevice 返回会话对端设备的路径
+func (b *Bluetooth) getObexSessionDevice(sessionPath dbus.ObjectPath) (dbus.ObjectPath, error) {
+	props, err := b.getObexProperties(sessionPath, obexSessionDBusInterface)
+	if err != nil {
+		return "", err
+	}
+	source, _ := props["Source"].Value().(string)
+	destination, _ := props["Destination"].Value().(string)
+
+	b.devicesLock.Lock()
+	defer b.devicesLock.Unlock()
+	for _, devices := range b.devices {
+		for _, d := range devices {
+			if d.Address == destination && d.adapter.address == source {
+				return d.Path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("can not find device %s/%s", source, destination)
+}
+
+func (b *Bluetooth) authorizePush(transferPath dbus.ObjectPath) (string, error) {
+	props, err := b.getObexProperties(transferPath, obexTransferDBusInterface)
+	if err != nil {
+		return "", err
+	}
+	name, _ := props["Name"].Value().(string)
+	size, _ := props["Size"].Value().(uint64)
+	sessionPath, _ := props["Session"].Value().(dbus.ObjectPath)
+
+	name, err = getReceiveName(name)
+	if err != nil {
+		return "", err
+	}
+	devPath, err := b.getObexSessionDevice(sessionPath)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := b.newTransfer(transferDirectionReceive, devPath, name, "", size)
+	if err != nil {
+		return "", err
+	}
+	t.mu.Lock()
+	t.obexPath = transferPath
+	t.session = sessionPath
+	t.confirmCh = make(chan bool, 1)
+	t.mu.Unlock()
+
+	b.obexAgent.setPending(t)
+	defer b.obexAgent.setPending(nil)
+
+	err = b.service.Emit(b, "RequestTransfer", t.path, devPath, name, size)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	var accept bool
+	timer := time.NewTimer(obexConfirmTimeout)
+	select {
+	case accept = <-t.confirmCh:
+		timer.Stop()
+	case <-timer.C:
+		logger.Info("confirm transfer timeout")
+	}
+
+	t.mu.Lock()
+	t.confirmCh = nil
+	t.mu.Unlock()
+
+	if !accept {
+		b.removeTransfer(t, transferStatusCancelled)
+		return "", errBluezRejected
+	}
+
+	dir := b.config.getReceiveDirectory()
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		b.removeTransfer(t, transferStatusError)
+		return "", err
+	}
+	filename := getReceiveFilename(dir, name)
+	t.PropsMu.Lock()
+	t.setPropFilename(filename)
+	t.PropsMu.Unlock()
+	logger.Infof("%s receive to %q", t, filename)
+	return filename, nil
+}
+
+func (b *Bluetooth) sendFiles(dpath dbus.ObjectPath, files []string) ([]dbus.ObjectPath, error) {
+	d, err := b.getDevice(dpath)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no file to send")
+	}
+	var sizes []uint64
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			return nil, fmt.Errorf("%q is not an absolute path", file)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%q is not a regular file", file)
+		}
+		sizes = append(sizes, uint64(info.Size()))
+	}
+
+	var transfers []*transfer
+	var paths []dbus.ObjectPath
+	for i, file := range files {
+		t, err := b.newTransfer(transferDirectionSend, dpath, filepath.Base(file), file, sizes[i])
+		if err != nil {
+			for _, t := range transfers {
+				b.removeTransfer(t, transferStatusCancelled)
+			}
+			return nil, err
+		}
+		transfers = append(transfers, t)
+		paths = append(paths, t.path)
+	}
+
+	go b.doSendFiles(d.adapter.address, d.Address, transfers)
+	return paths, nil
+}
+
+// doSendFiles 创建会话后依次调用 SendFile，obexd 会按顺序传输
+func (b *Bluetooth) doSendFiles(source, destination string, transfers []*transfer) {
+	var sessionPath dbus.ObjectPath
+	args := map[string]dbus.Variant{
+		"Target": dbus.MakeVariant("opp"),
+		"Source": dbus.MakeVariant(source),
+	}
+	err := b.service.Conn().Object(obexDBusServiceName, obexDBusPath).
+		Call(obexClientDBusInterface+".CreateSession", 0, destination, args).Store(&sessionPath)
+	if err != nil {
+		logger.Warningf("failed to create obex session to %s: %v", destination, err)
+		for _, t := range transfers {
+			b.removeTransfer(t, transferStatusError)
+		}
+		return
+	}
+	logger.Debug("create obex session", sessionPath)
+
+	// 先设置所有传输的会话，避免第一个文件发送完成后会话被移除
+	for _, t := range transfers {
+		t.mu.Lock()
+		t.session = sessionPath
+		t.mu.Unlock()
+	}
+
+	for _, t := range transfers {
+		if t.isCancelled() {
+			continue
+		}
+		var obexPath dbus.ObjectPath
+		var props map[string]dbus.Variant
+		err = b.service.Conn().Object(obexDBusServiceName, sessionPath).
+			Call(obexObjectPushDBusInterface+".SendFile", 0, t.Filename).Store(&obexPath, &props)
+		if err != nil {
+			logger.Warningf("failed to send %q: %v", t.Filename, err)
+			b.removeTransfer(t, transferStatusError)
+			continue
+		}
+
+		b.transfersMu.Lock()
+		t.mu.Lock()
+		t.obexPath = obexPath
+		cancelled := t.cancelled
+		t.mu.Unlock()
+		finished := b.obexFinished[obexPath]
+		delete(b.obexFinished, obexPath)
+		b.transfersMu.Unlock()
+		if cancelled && finished == "" {
+			// 调用 SendFile 时被取消
+			err = b.service.Conn().Object(obexDBusServiceName, obexPath).
+				Call(obexTransferDBusInterface+".Cancel", 0).Err
+			if err != nil {
+				logger.Warning(err)
+			}
+		}
+		b.syncObexTransfer(t, obexPath, props, finished)
+	}
+
+	b.removeObexSessionIfUnused(sessionPath)
+}
+
+// syncObexTransfer 在设置 obexPath 之后同步传输的状态，props 是 SendFile 返回的属性，
+// finished 是在这之前收到的结束状态
+func (b *Bluetooth) syncObexTransfer(t *transfer, obexPath dbus.ObjectPath, props map[string]dbus.Variant,
+	finished string) {
+	updateTransferProgress(t, props)
+	reply, _ := getObexTransferStatus(props)
+
+	var current string
+	var removed bool
+	if finished == "" && !isTransferFinished(reply) {
+		currentProps, err := b.getObexProperties(obexPath, obexTransferDBusInterface)
+		if err != nil {
+			logger.Debugf("failed to get properties of %s: %v", obexPath, err)
+			removed = true
+		} else {
+			updateTransferProgress(t, currentProps)
+			current, _ = getObexTransferStatus(currentProps)
+		}
+	}
+	t.updateStatus(getSyncedStatus(reply, finished, current, removed))
+}
+
+// removeObexSessionIfUnused 会话中的传输都结束后移除会话，断开 obex 连接
+func (b *Bluetooth) removeObexSessionIfUnused(sessionPath dbus.ObjectPath) {
+	b.transfersMu.Lock()
+	for _, t := range b.transfers {
+		t.mu.Lock()
+		session := t.session
+		t.mu.Unlock()
+		if session == sessionPath {
+			b.transfersMu.Unlock()
+			return
+		}
+	}
+	b.transfersMu.Unlock()
+
+	logger.Debug("remove obex session", sessionPath)
+	err := b.service.Conn().Object(obexDBusServiceName, obexDBusPath).
+		Call(obexClientDBusInterface+".RemoveSession", 0, sessionPath).Err
+	if err != nil {
+		logger.Debug(err)
+	}
+}
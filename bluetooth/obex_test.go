@@ -0,0 +1,63 @@
+package bluetooth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetReceiveFilename(t *testing.T) {
+	Convey("getReceiveFilename", t, func(c C) {
+		tests := []struct {
+			existing []string
+			name     string
+			result   string
+		}{
+			{nil, "a.txt", "a.txt"},
+			{[]string{"a.txt"}, "a.txt", "a(1).txt"},
+			{[]string{"a.txt", "a(1).txt"}, "a.txt", "a(2).txt"},
+			{[]string{"a.txt", "a(2).txt"}, "a.txt", "a(1).txt"},
+			{nil, "README", "README"},
+			{[]string{"README"}, "README", "README(1)"},
+			{[]string{"a.tar.gz"}, "a.tar.gz", "a.tar(1).gz"},
+		}
+		for _, test := range tests {
+			dir, err := ioutil.TempDir("", "bluetooth-receive")
+			c.So(err, ShouldBeNil)
+			for _, name := range test.existing {
+				err = ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+				c.So(err, ShouldBeNil)
+			}
+			c.So(getReceiveFilename(dir, test.name), ShouldEqual, filepath.Join(dir, test.result))
+			os.RemoveAll(dir)
+		}
+	})
+
+	Convey("getReceiveName", t, func(c C) {
+		tests := []struct {
+			name   string
+			result string
+			ok     bool
+		}{
+			{"a.txt", "a.txt", true},
+			{"../a.txt", "a.txt", true},
+			{"/etc/passwd", "passwd", true},
+			{"a/b", "b", true},
+			{"a/", "a", true},
+			{"..", "", false},
+			{"a/..", "", false},
+			{".", "", false},
+			{"", "", false},
+			{"/", "", false},
+			{"//", "", false},
+		}
+		for _, test := range tests {
+			name, err := getReceiveName(test.name)
+			c.So(name, ShouldEqual, test.result)
+			c.So(err == nil, ShouldEqual, test.ok)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package bluetooth
+
+import (
+	"time"
+)
+
+// 设备意外断开后按照退避时间重新连接，只用于策略为 autoReconnectAlways 的设备。
+
+const (
+	reconnectMinDelay = 5 * time.Second
+	reconnectMaxDelay = 5 * time.Minute
+	reconnectMaxTimes = 8
+)
+
+// getReconnectDelay 返回第 n 次重连前等待的时间，n 从 0 开始，每次加倍
+func getReconnectDelay(n int) time.Duration {
+	if n >= 16 {
+		return reconnectMaxDelay
+	}
+	delay := reconnectMinDelay << uint(n)
+	if delay > reconnectMaxDelay {
+		delay = reconnectMaxDelay
+	}
+	return delay
+}
+
+// canAutoConnect 判断策略是否允许自动连接，login 表示是否为登录时的自动连接
+func canAutoConnect(policy string, login bool) bool {
+	switch policy {
+	case autoReconnectAlways:
+		return true
+	case autoReconnectLogin:
+		return login
+	default:
+		return false
+	}
+}
+
+func (d *device) getAutoReconnect() string {
+	return globalBluetooth.config.getDeviceConfigAutoReconnect(d.getAddress())
+}
+
+func (d *device) startReconnect() {
+	if d.getAutoReconnect() != autoReconnectAlways {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.reconnectTimer != nil {
+		return
+	}
+	d.reconnectTimes = 0
+	d.scheduleReconnect()
+}
+
+// scheduleReconnect 需要持有 d.mu
+func (d *device) scheduleReconnect() {
+	if d.reconnectTimes >= reconnectMaxTimes {
+		logger.Debugf("%s stop reconnecting after %d times", d, d.reconnectTimes)
+		return
+	}
+	delay := getReconnectDelay(d.reconnectTimes)
+	d.reconnectTimes++
+	logger.Debugf("%s will reconnect after %v", d, delay)
+	d.reconnectTimer = time.AfterFunc(delay, d.tryReconnect)
+}
+
+func (d *device) stopReconnect() {
+	d.mu.Lock()
+	if d.reconnectTimer != nil {
+		d.reconnectTimer.Stop()
+		d.reconnectTimer = nil
+	}
+	d.mu.Unlock()
+}
+
+func (d *device) tryReconnect() {
+	d.mu.Lock()
+	if d.reconnectTimer == nil {
+		// 已经被取消
+		d.mu.Unlock()
+		return
+	}
+	times := d.reconnectTimes
+	connected := d.connected
+	paired := d.Paired
+	d.mu.Unlock()
+
+	if connected || !paired || d.getAutoReconnect() != autoReconnectAlways {
+		d.stopReconnect()
+		return
+	}
+	powered, err := d.adapter.core.Powered().Get(0)
+	if err != nil || !powered {
+		d.stopReconnect()
+		return
+	}
+
+	logger.Infof("%s reconnect, times: %d", d, times)
+	err = d.doConnect(false)
+	if err != nil {
+		logger.Debugf("%s failed to reconnect: %v", d, err)
+	}
+
+	d.mu.Lock()
+	if d.reconnectTimer != nil {
+		d.reconnectTimer = nil
+		if !d.connected {
+			d.scheduleReconnect()
+		}
+	}
+	d.mu.Unlock()
+}
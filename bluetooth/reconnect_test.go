@@ -0,0 +1,49 @@
+package bluetooth
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReconnect(t *testing.T) {
+	Convey("getReconnectDelay", t, func(c C) {
+		tests := []struct {
+			n     int
+			delay time.Duration
+		}{
+			{0, 5 * time.Second},
+			{1, 10 * time.Second},
+			{2, 20 * time.Second},
+			{5, 160 * time.Second},
+			{6, 5 * time.Minute},
+			{15, 5 * time.Minute},
+			{16, 5 * time.Minute},
+			{100, 5 * time.Minute},
+		}
+		for _, test := range tests {
+			c.So(getReconnectDelay(test.n), ShouldEqual, test.delay)
+		}
+	})
+
+	Convey("canAutoConnect", t, func(c C) {
+		tests := []struct {
+			policy string
+			login  bool
+			result bool
+		}{
+			{autoReconnectAlways, true, true},
+			{autoReconnectAlways, false, true},
+			{autoReconnectLogin, true, true},
+			{autoReconnectLogin, false, false},
+			{autoReconnectNever, true, false},
+			{autoReconnectNever, false, false},
+			{"", true, false},
+			{"unknown", true, false},
+		}
+		for _, test := range tests {
+			c.So(canAutoConnect(test.policy, test.login), ShouldEqual, test.result)
+		}
+	})
+}
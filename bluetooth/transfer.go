@@ -0,0 +1,304 @@
+package bluetooth
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	dbus "pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// 文件传输对象，每个发送或者接收的文件对应一个，导出在 /com/deepin/daemon/Bluetooth/TransferN，
+// 传输结束后发送 TransferRemoved 信号并取消导出。
+
+const (
+	transferDBusPathPrefix = dbusPath + "/Transfer"
+	transferDBusInterface  = dbusInterface + ".Transfer"
+
+	transferDirectionSend    = "send"
+	transferDirectionReceive = "receive"
+)
+
+var (
+	errInvalidTransferPath = fmt.Errorf("invalid transfer path")
+)
+
+// 传输的状态，除了 cancelled 都和 obexd 中 Transfer1 的 Status 相同
+const (
+	transferStatusQueued    = "queued"
+	transferStatusActive    = "active"
+	transferStatusSuspended = "suspended"
+	transferStatusComplete  = "complete"
+	transferStatusError     = "error"
+	transferStatusCancelled = "cancelled"
+)
+
+type transfer struct {
+	b       *Bluetooth
+	service *dbusutil.Service
+	path    dbus.ObjectPath
+
+	mu sync.Mutex
+	// obexd 中 Transfer1 对象的路径，发送时调用 SendFile 之后才有
+	obexPath dbus.ObjectPath
+	// obexd 中 Session1 对象的路径
+	session dbus.ObjectPath
+	// 接收时等待用户确认
+	confirmCh chan bool
+	cancelled bool
+
+	PropsMu     sync.RWMutex
+	Device      dbus.ObjectPath
+	Direction   string
+	Name        string
+	Filename    string
+	Size        uint64
+	Transferred uint64
+	Status      string
+
+	methods *struct {
+		Cancel func()
+	}
+}
+
+func (*transfer) GetInterfaceName() string {
+	return transferDBusInterface
+}
+
+func (t *transfer) String() string {
+	return string(t.path)
+}
+
+func (t *transfer) getObexPath() dbus.ObjectPath {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.obexPath
+}
+
+func (t *transfer) isCancelled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cancelled
+}
+
+// Cancel 取消传输，等待确认的接收请求会被拒绝
+func (t *transfer) Cancel() *dbus.Error {
+	logger.Info("Cancel transfer", t)
+	t.mu.Lock()
+	if t.cancelled {
+		t.mu.Unlock()
+		return nil
+	}
+	t.cancelled = true
+	obexPath := t.obexPath
+	confirmCh := t.confirmCh
+	t.mu.Unlock()
+
+	err := t.service.Emit(t.b, "TransferCancelled", t.path)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	if confirmCh != nil {
+		// 由 authorizePush 移除
+		select {
+		case confirmCh <- false:
+		default:
+		}
+		return nil
+	}
+	if obexPath == "" {
+		// 还没有开始发送
+		t.b.removeTransfer(t, transferStatusCancelled)
+		return nil
+	}
+	// obexd 取消后把 Status 设置为 error，由 updateStatus 移除
+	err = t.service.Conn().Object(obexDBusServiceName, obexPath).
+		Call(obexTransferDBusInterface+".Cancel", 0).Err
+	if err != nil {
+		logger.Warningf("failed to cancel %s: %v", t, err)
+		return dbusutil.ToError(err)
+	}
+	return nil
+}
+
+func (t *transfer) confirm(accept bool) error {
+	t.mu.Lock()
+	confirmCh := t.confirmCh
+	t.mu.Unlock()
+	if confirmCh == nil {
+		return errBluezCanceled
+	}
+	select {
+	case confirmCh <- accept:
+		return nil
+	default:
+		return errBluezCanceled
+	}
+}
+
+func (t *transfer) updateProgress(size, transferred uint64) {
+	t.PropsMu.Lock()
+	if size > 0 {
+		t.setPropSize(size)
+	} else {
+		size = t.Size
+	}
+	changed := t.setPropTransferred(transferred)
+	t.PropsMu.Unlock()
+
+	if changed {
+		err := t.service.Emit(t.b, "TransferProgress", t.path, transferred, size)
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+}
+
+func isTransferFinished(status string) bool {
+	return status == transferStatusComplete || status == transferStatusError
+}
+
+// getSyncedStatus 返回 SendFile 返回之后传输的状态。reply 是 SendFile 返回的状态，finished 是在这之前收到的结束状态，
+// current 是之后读取的状态，removed 表示 obexd 已经移除了 Transfer1 对象。
+// 很快失败的传输也会被移除，所以没有收到 complete 的被移除的传输当作失败。
+func getSyncedStatus(reply, finished, current string, removed bool) string {
+	if finished != "" {
+		return finished
+	}
+	if isTransferFinished(reply) {
+		return reply
+	}
+	if removed {
+		return transferStatusError
+	}
+	if current != "" {
+		return current
+	}
+	return reply
+}
+
+func (t *transfer) updateStatus(status string) {
+	logger.Debugf("%s Status: %s", t, status)
+	switch status {
+	case transferStatusComplete:
+		t.PropsMu.Lock()
+		if t.Size > 0 && t.Transferred != t.Size {
+			t.setPropTransferred(t.Size)
+		}
+		t.PropsMu.Unlock()
+		t.b.removeTransfer(t, status)
+	case transferStatusError:
+		if t.isCancelled() {
+			status = transferStatusCancelled
+		}
+		t.b.removeTransfer(t, status)
+	default:
+		t.PropsMu.Lock()
+		t.setPropStatus(status)
+		t.PropsMu.Unlock()
+	}
+}
+
+func (b *Bluetooth) newTransfer(direction string, device dbus.ObjectPath, name, filename string,
+	size uint64) (*transfer, error) {
+	b.transfersMu.Lock()
+	b.transferId++
+	t := &transfer{
+		b:         b,
+		service:   b.service,
+		path:      dbus.ObjectPath(transferDBusPathPrefix + strconv.FormatUint(uint64(b.transferId), 10)),
+		Device:    device,
+		Direction: direction,
+		Name:      name,
+		Filename:  filename,
+		Size:      size,
+		Status:    transferStatusQueued,
+	}
+	err := b.service.Export(t.path, t)
+	if err != nil {
+		b.transfersMu.Unlock()
+		return nil, err
+	}
+	b.transfers = append(b.transfers, t)
+	b.transfersMu.Unlock()
+
+	logger.Infof("add transfer %s, %s %q", t, direction, name)
+	err = b.service.Emit(b, "TransferAdded", t.path)
+	if err != nil {
+		logger.Warning(err)
+	}
+	return t, nil
+}
+
+func (b *Bluetooth) getTransfer(path dbus.ObjectPath) (*transfer, error) {
+	b.transfersMu.Lock()
+	defer b.transfersMu.Unlock()
+	for _, t := range b.transfers {
+		if t.path == path {
+			return t, nil
+		}
+	}
+	return nil, errInvalidTransferPath
+}
+
+// getTransferByObexPath 需要持有 b.transfersMu
+func (b *Bluetooth) getTransferByObexPath(obexPath dbus.ObjectPath) *transfer {
+	for _, t := range b.transfers {
+		if t.getObexPath() == obexPath {
+			return t
+		}
+	}
+	return nil
+}
+
+// removeTransfer 移除已经结束的传输，发送的会话中没有其他传输时移除会话
+func (b *Bluetooth) removeTransfer(t *transfer, status string) {
+	b.transfersMu.Lock()
+	var found bool
+	for i, item := range b.transfers {
+		if item == t {
+			b.transfers = append(b.transfers[:i], b.transfers[i+1:]...)
+			found = true
+			break
+		}
+	}
+	b.transfersMu.Unlock()
+	if !found {
+		return
+	}
+
+	logger.Infof("remove transfer %s, status: %s", t, status)
+	t.PropsMu.Lock()
+	t.setPropStatus(status)
+	direction := t.Direction
+	t.PropsMu.Unlock()
+
+	err := b.service.StopExport(t)
+	if err != nil {
+		logger.Warning(err)
+	}
+	err = b.service.Emit(b, "TransferRemoved", t.path, status)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	t.mu.Lock()
+	session := t.session
+	t.mu.Unlock()
+	if direction == transferDirectionSend && session != "" {
+		b.removeObexSessionIfUnused(session)
+	}
+}
+
+func (b *Bluetooth) removeAllTransfers(status string) {
+	b.transfersMu.Lock()
+	transfers := make([]*transfer, len(b.transfers))
+	copy(transfers, b.transfers)
+	b.transfersMu.Unlock()
+
+	for _, t := range transfers {
+		b.removeTransfer(t, status)
+	}
+}
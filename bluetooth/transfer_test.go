@@ -0,0 +1,53 @@
+package bluetooth
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	dbus "pkg.deepin.io/lib/dbus1"
+)
+
+func TestTransferStatus(t *testing.T) {
+	Convey("getSyncedStatus", t, func(c C) {
+		tests := []struct {
+			reply    string
+			finished string
+			current  string
+			removed  bool
+			result   string
+		}{
+			// SendFile 返回之前已经收到结束的信号
+			{transferStatusQueued, transferStatusComplete, "", false, transferStatusComplete},
+			{transferStatusQueued, transferStatusError, "", true, transferStatusError},
+			// SendFile 返回的就是结束状态
+			{transferStatusComplete, "", "", true, transferStatusComplete},
+			{transferStatusError, "", "", true, transferStatusError},
+			// 没有收到结束状态，对象已经被移除
+			{transferStatusQueued, "", "", true, transferStatusError},
+			{transferStatusActive, "", "", true, transferStatusError},
+			// 传输还在进行
+			{transferStatusQueued, "", transferStatusActive, false, transferStatusActive},
+			{transferStatusQueued, "", transferStatusComplete, false, transferStatusComplete},
+			{transferStatusQueued, "", "", false, transferStatusQueued},
+		}
+		for _, test := range tests {
+			c.So(getSyncedStatus(test.reply, test.finished, test.current, test.removed), ShouldEqual, test.result)
+		}
+	})
+
+	Convey("saveObexFinished", t, func(c C) {
+		b := &Bluetooth{}
+		b.saveObexFinished("/org/bluez/obex/client/session0/transfer0", transferStatusComplete)
+		c.So(b.obexFinished, ShouldResemble, map[dbus.ObjectPath]string{
+			"/org/bluez/obex/client/session0/transfer0": transferStatusComplete,
+		})
+
+		for i := 0; i < maxObexFinished; i++ {
+			b.saveObexFinished(dbus.ObjectPath("/org/bluez/obex/server/session1/transfer"+string(rune('a'+i))),
+				transferStatusError)
+		}
+		c.So(len(b.obexFinished), ShouldBeLessThanOrEqualTo, maxObexFinished)
+		_, ok := b.obexFinished["/org/bluez/obex/client/session0/transfer0"]
+		c.So(ok, ShouldBeFalse)
+	})
+}
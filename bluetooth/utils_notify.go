@@ -32,6 +32,7 @@ const (
 	notifyIconBluetoothConnected     = "notification-bluetooth-connected"
 	notifyIconBluetoothDisconnected  = "notification-bluetooth-disconnected"
 	notifyIconBluetoothConnectFailed = "notification-bluetooth-error"
+	notifyIconBluetoothBatteryLow    = "notification-battery-low"
 )
 
 var globalNotifications *notifications.Notifications
@@ -81,3 +82,8 @@ func notifyConnectFailedHostDown(alias string) {
 func notifyConnectFailedAux(alias, format string) {
 	notify(notifyIconBluetoothConnectFailed, Tr("Bluetooth connection failed"), fmt.Sprintf(format, alias))
 }
+
+func notifyLowBattery(alias string, percentage int32) {
+	format := Tr("The battery of %q is low (%d%%)")
+	notify(notifyIconBluetoothBatteryLow, Tr("Low battery"), fmt.Sprintf(format, alias, percentage))
+}
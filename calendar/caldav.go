@@ -0,0 +1,293 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CalDAV (RFC 4791) 同步后端，用 getctag 判断集合是否有变化，用 ETag 判断单个日程是否有变化。
+
+var errPreconditionFailed = errors.New("precondition failed")
+
+const (
+	calDAVTimeout = 30 * time.Second
+
+	propfindCTagBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">
+  <d:prop><cs:getctag/></d:prop>
+</d:propfind>`
+
+	propfindETagBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop><d:getetag/><d:resourcetype/></d:prop>
+</d:propfind>`
+)
+
+func init() {
+	registerSyncProvider("caldav", newCalDAVProvider)
+}
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href     string        `xml:"DAV: href"`
+	Propstat []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Status string  `xml:"DAV: status"`
+	Prop   davProp `xml:"DAV: prop"`
+}
+
+type davProp struct {
+	GetCTag      string          `xml:"http://calendarserver.org/ns/ getctag"`
+	GetETag      string          `xml:"DAV: getetag"`
+	ResourceType davResourceType `xml:"DAV: resourcetype"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"DAV: collection"`
+}
+
+func (ps *davPropstat) ok() bool {
+	return strings.Contains(ps.Status, " 200 ")
+}
+
+type calDAVProvider struct {
+	client   *http.Client
+	base     *url.URL
+	username string
+	password string
+}
+
+func newCalDAVProvider(account *SyncAccount) (syncProvider, error) {
+	base, err := url.Parse(account.URL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid CalDAV url %q", account.URL)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return &calDAVProvider{
+		client:   &http.Client{Timeout: calDAVTimeout},
+		base:     base,
+		username: account.Username,
+		password: account.Password,
+	}, nil
+}
+
+// resolve 返回 href 在集合所在服务器上的绝对路径
+func (p *calDAVProvider) resolve(href string) (string, error) {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	return p.base.ResolveReference(ref).Path, nil
+}
+
+func (p *calDAVProvider) newRequest(method, href string, body []byte) (*http.Request, error) {
+	u := p.base
+	if href != "" {
+		ref := &url.URL{Path: href}
+		u = p.base.ResolveReference(ref)
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	return req, nil
+}
+
+func (p *calDAVProvider) propfind(depth, body string) (*davMultistatus, error) {
+	req, err := p.newRequest("PROPFIND", "", []byte(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("PROPFIND %s: %s", req.URL, resp.Status)
+	}
+	var ms davMultistatus
+	err = xml.NewDecoder(resp.Body).Decode(&ms)
+	if err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+func (p *calDAVProvider) getCTag() (string, error) {
+	ms, err := p.propfind("0", propfindCTagBody)
+	if err != nil {
+		return "", err
+	}
+	for _, resp := range ms.Responses {
+		for _, ps := range resp.Propstat {
+			if ps.ok() && ps.Prop.GetCTag != "" {
+				return ps.Prop.GetCTag, nil
+			}
+		}
+	}
+	// 服务器不支持 getctag
+	return "", nil
+}
+
+func (p *calDAVProvider) listObjects() (map[string]string, error) {
+	ms, err := p.propfind("1", propfindETagBody)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, resp := range ms.Responses {
+		href, err := p.resolve(resp.Href)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		if href == p.base.Path {
+			continue
+		}
+		for _, ps := range resp.Propstat {
+			if !ps.ok() || ps.Prop.ResourceType.Collection != nil {
+				continue
+			}
+			result[href] = ps.Prop.GetETag
+		}
+	}
+	return result, nil
+}
+
+var hrefUnsafeCharReg = regexp.MustCompile(`[^A-Za-z0-9@._-]`)
+
+func (p *calDAVProvider) hrefForUID(uid string) string {
+	return p.base.Path + hrefUnsafeCharReg.ReplaceAllString(uid, "_") + ".ics"
+}
+
+func (p *calDAVProvider) getObject(href string) ([]byte, string, error) {
+	req, err := p.newRequest(http.MethodGet, href, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+func (p *calDAVProvider) getETag(href string) (string, error) {
+	req, err := p.newRequest(http.MethodHead, href, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HEAD %s: %s", req.URL, resp.Status)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// putObject 上传日程，etag 为空时表示新建。
+func (p *calDAVProvider) putObject(href string, data []byte, etag string) (string, error) {
+	req, err := p.newRequest(http.MethodPut, href, data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	} else {
+		req.Header.Set("If-None-Match", "*")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusPreconditionFailed:
+		return "", errPreconditionFailed
+	default:
+		return "", fmt.Errorf("PUT %s: %s", req.URL, resp.Status)
+	}
+
+	newETag := resp.Header.Get("ETag")
+	if newETag == "" {
+		// 有的服务器在内容被修改过时不返回 ETag
+		return p.getETag(href)
+	}
+	return newETag, nil
+}
+
+func (p *calDAVProvider) deleteObject(href string, etag string) error {
+	req, err := p.newRequest(http.MethodDelete, href, nil)
+	if err != nil {
+		return err
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	case http.StatusPreconditionFailed:
+		return errPreconditionFailed
+	default:
+		return fmt.Errorf("DELETE %s: %s", req.URL, resp.Status)
+	}
+}
@@ -0,0 +1,401 @@
+package calendar
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCollectionPath = "/calendars/test/work/"
+
+type testCalDAVObject struct {
+	data []byte
+	etag string
+}
+
+// testCalDAVServer 是一个只支持同步所需请求的 CalDAV 服务器
+type testCalDAVServer struct {
+	mu      sync.Mutex
+	objects map[string]*testCalDAVObject
+	version int
+}
+
+func newTestCalDAVServer() *testCalDAVServer {
+	return &testCalDAVServer{
+		objects: make(map[string]*testCalDAVObject),
+	}
+}
+
+func (s *testCalDAVServer) setObject(href string, data []byte) string {
+	s.version++
+	etag := `"` + strconv.Itoa(s.version) + `"`
+	s.objects[href] = &testCalDAVObject{data: data, etag: etag}
+	return etag
+}
+
+func (s *testCalDAVServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	href := r.URL.Path
+	if !strings.HasPrefix(href, testCollectionPath) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	obj := s.objects[href]
+
+	switch r.Method {
+	case "PROPFIND":
+		s.propfind(w, r.Header.Get("Depth"))
+
+	case http.MethodGet, http.MethodHead:
+		if obj == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", obj.etag)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+
+	case http.MethodPut:
+		ifMatch := r.Header.Get("If-Match")
+		if (ifMatch != "" && (obj == nil || obj.etag != ifMatch)) ||
+			(r.Header.Get("If-None-Match") == "*" && obj != nil) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("ETag", s.setObject(href, data))
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodDelete:
+		if obj == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch != "" && obj.etag != ifMatch {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		delete(s.objects, href)
+		s.version++
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *testCalDAVServer) propfind(w http.ResponseWriter, depth string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">`)
+	fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/></d:resourcetype><cs:getctag>ctag-%d</cs:getctag>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, testCollectionPath, s.version)
+	if depth == "1" {
+		for href, obj := range s.objects {
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop>
+<d:getetag>%s</d:getetag><d:resourcetype/>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, obj.etag)
+		}
+	}
+	fmt.Fprint(w, `</d:multistatus>`)
+}
+
+func newTestCalDAVProvider(t *testing.T, url string) *calDAVProvider {
+	p, err := newCalDAVProvider(&SyncAccount{
+		Name:     "test",
+		Provider: "caldav",
+		URL:      url + testCollectionPath,
+	})
+	require.Nil(t, err)
+	return p.(*calDAVProvider)
+}
+
+func TestCalDAVProvider(t *testing.T) {
+	server := newTestCalDAVServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	p := newTestCalDAVProvider(t, ts.URL)
+
+	ctag0, err := p.getCTag()
+	require.Nil(t, err)
+
+	href := p.hrefForUID("1@dde-calendar")
+	assert.Equal(t, testCollectionPath+"1@dde-calendar.ics", href)
+	etag, err := p.putObject(href, []byte("data1"), "")
+	require.Nil(t, err)
+	assert.NotEmpty(t, etag)
+
+	// 已经存在，不能再新建
+	_, err = p.putObject(href, []byte("data1"), "")
+	assert.Equal(t, errPreconditionFailed, err)
+
+	ctag1, err := p.getCTag()
+	require.Nil(t, err)
+	assert.NotEqual(t, ctag0, ctag1)
+
+	objects, err := p.listObjects()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{href: etag}, objects)
+
+	data, etag1, err := p.getObject(href)
+	require.Nil(t, err)
+	assert.Equal(t, "data1", string(data))
+	assert.Equal(t, etag, etag1)
+
+	etag2, err := p.putObject(href, []byte("data2"), etag)
+	require.Nil(t, err)
+	assert.NotEqual(t, etag, etag2)
+
+	// etag 已过期
+	_, err = p.putObject(href, []byte("data3"), etag)
+	assert.Equal(t, errPreconditionFailed, err)
+	err = p.deleteObject(href, etag)
+	assert.Equal(t, errPreconditionFailed, err)
+
+	err = p.deleteObject(href, etag2)
+	assert.Nil(t, err)
+	objects, err = p.listObjects()
+	require.Nil(t, err)
+	assert.Len(t, objects, 0)
+}
+
+func newTestSyncDB(t *testing.T) (*gorm.DB, func()) {
+	dir, err := ioutil.TempDir("", "calendar-sync-test")
+	require.Nil(t, err)
+	db, err := gorm.Open("sqlite3", filepath.Join(dir, "scheduler.db"))
+	require.Nil(t, err)
+	err = db.AutoMigrate(&Job{}, &JobType{}, &SyncRecord{}, &SyncAccountState{}).Error
+	require.Nil(t, err)
+	err = initJobTypeTable(db)
+	require.Nil(t, err)
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func newTestAccountSyncer(t *testing.T, db *gorm.DB, url, policy string) *accountSyncer {
+	cfg := &SyncConfig{
+		Accounts: []*SyncAccount{
+			{
+				Name:           "test",
+				Provider:       "caldav",
+				URL:            url + testCollectionPath,
+				Types:          []uint{1},
+				ConflictPolicy: policy,
+			},
+		},
+	}
+	require.Nil(t, cfg.validate())
+	as, err := newAccountSyncer(db, cfg.Accounts[0])
+	require.Nil(t, err)
+	return as
+}
+
+const testRemoteEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//Example Client//EN
+BEGIN:VEVENT
+UID:remote-1@example.com
+DTSTAMP:20190820T080000Z
+DTSTART:20190902T010000Z
+DTEND:20190902T020000Z
+SUMMARY:%s
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestAccountSyncer(t *testing.T) {
+	server := newTestCalDAVServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	db, cleanup := newTestSyncDB(t)
+	defer cleanup()
+	as := newTestAccountSyncer(t, db, ts.URL, conflictPolicyServer)
+
+	// 本地新建，上传到远端
+	job := &Job{
+		Type:  1,
+		Title: "local job",
+		Start: newTimeYMDHM(2019, 9, 1, 9, 0),
+		End:   newTimeYMDHM(2019, 9, 1, 10, 0),
+	}
+	require.Nil(t, db.Create(job).Error)
+	// 不属于该账户的类型
+	require.Nil(t, db.Create(&Job{Type: 2, Title: "other"}).Error)
+
+	changed, err := as.sync()
+	require.Nil(t, err)
+	assert.Len(t, changed, 0)
+	assert.Len(t, server.objects, 1)
+	localHref := testCollectionPath + jobUID(job.ID) + ".ics"
+	require.NotNil(t, server.objects[localHref])
+	assert.Contains(t, string(server.objects[localHref].data), "SUMMARY:local job")
+
+	// 远端新建，下载到本地
+	remoteHref := testCollectionPath + "remote-1.ics"
+	server.setObject(remoteHref, []byte(fmt.Sprintf(testRemoteEvent, "remote job")))
+	changed, err = as.sync()
+	require.Nil(t, err)
+	require.Len(t, changed, 1)
+	var remoteJob Job
+	require.Nil(t, db.First(&remoteJob, changed[0]).Error)
+	assert.Equal(t, "remote job", remoteJob.Title)
+	assert.Equal(t, 1, remoteJob.Type)
+
+	// 没有变化
+	changed, err = as.sync()
+	require.Nil(t, err)
+	assert.Len(t, changed, 0)
+
+	// 远端修改
+	server.setObject(remoteHref, []byte(fmt.Sprintf(testRemoteEvent, "remote job 2")))
+	changed, err = as.sync()
+	require.Nil(t, err)
+	assert.Equal(t, []uint{remoteJob.ID}, changed)
+	require.Nil(t, db.First(&remoteJob, remoteJob.ID).Error)
+	assert.Equal(t, "remote job 2", remoteJob.Title)
+
+	// 本地修改
+	require.Nil(t, db.Model(job).Update("Title", "local job 2").Error)
+	changed, err = as.sync()
+	require.Nil(t, err)
+	assert.Len(t, changed, 0)
+	assert.Contains(t, string(server.objects[localHref].data), "SUMMARY:local job 2")
+
+	// 冲突，远端优先
+	require.Nil(t, db.Model(&remoteJob).Update("Title", "local change").Error)
+	server.setObject(remoteHref, []byte(fmt.Sprintf(testRemoteEvent, "remote change")))
+	changed, err = as.sync()
+	require.Nil(t, err)
+	assert.Equal(t, []uint{remoteJob.ID}, changed)
+	require.Nil(t, db.First(&remoteJob, remoteJob.ID).Error)
+	assert.Equal(t, "remote change", remoteJob.Title)
+
+	// 本地删除
+	require.Nil(t, db.Unscoped().Delete(job).Error)
+	changed, err = as.sync()
+	require.Nil(t, err)
+	assert.Len(t, changed, 0)
+	assert.Nil(t, server.objects[localHref])
+
+	// 远端删除
+	delete(server.objects, remoteHref)
+	server.version++
+	changed, err = as.sync()
+	require.Nil(t, err)
+	assert.Equal(t, []uint{remoteJob.ID}, changed)
+	assert.True(t, db.First(&Job{}, remoteJob.ID).RecordNotFound())
+
+	var count int
+	require.Nil(t, db.Model(&SyncRecord{}).Count(&count).Error)
+	assert.Equal(t, 0, count)
+}
+
+func TestAccountSyncerConflictLocal(t *testing.T) {
+	server := newTestCalDAVServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	db, cleanup := newTestSyncDB(t)
+	defer cleanup()
+	as := newTestAccountSyncer(t, db, ts.URL, conflictPolicyLocal)
+
+	remoteHref := testCollectionPath + "remote-1.ics"
+	server.setObject(remoteHref, []byte(fmt.Sprintf(testRemoteEvent, "remote job")))
+	changed, err := as.sync()
+	require.Nil(t, err)
+	require.Len(t, changed, 1)
+
+	var job Job
+	require.Nil(t, db.First(&job, changed[0]).Error)
+	require.Nil(t, db.Model(&job).Update("Title", "local change").Error)
+	server.setObject(remoteHref, []byte(fmt.Sprintf(testRemoteEvent, "remote change")))
+
+	changed, err = as.sync()
+	require.Nil(t, err)
+	assert.Len(t, changed, 0)
+	data := string(server.objects[remoteHref].data)
+	assert.Contains(t, data, "SUMMARY:local change")
+	// 保留远端的 UID
+	assert.Contains(t, data, "UID:remote-1@example.com")
+}
+
+func TestAccountSyncerOverrides(t *testing.T) {
+	server := newTestCalDAVServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	db, cleanup := newTestSyncDB(t)
+	defer cleanup()
+	as := newTestAccountSyncer(t, db, ts.URL, conflictPolicyServer)
+
+	data, err := ioutil.ReadFile("testdata/recurring.ics")
+	require.Nil(t, err)
+	remoteHref := testCollectionPath + "weekly.ics"
+	server.setObject(remoteHref, data)
+
+	changed, err := as.sync()
+	require.Nil(t, err)
+	// 重复日程和单独修改的一次
+	require.Len(t, changed, 2)
+	var series, override Job
+	require.Nil(t, db.First(&series, changed[0]).Error)
+	require.Nil(t, db.First(&override, changed[1]).Error)
+	assert.Equal(t, "Weekly sync, team A", series.Title)
+	assert.Equal(t, series.ID, override.ParentID)
+	assert.True(t, override.OriginalStart.Equal(newTimeUTC(2019, 9, 9, 1, 0)))
+	assert.Equal(t, series.Type, override.Type)
+
+	// 本地修改单独的一次，连同重复日程一起上传
+	require.Nil(t, db.Model(&override).Update("Title", "moved again").Error)
+	changed, err = as.sync()
+	require.Nil(t, err)
+	assert.Len(t, changed, 0)
+	remoteData := string(server.objects[remoteHref].data)
+	assert.Contains(t, remoteData, "SUMMARY:moved again")
+	assert.Contains(t, remoteData, "RECURRENCE-ID:20190909T010000Z")
+	assert.Equal(t, 2, strings.Count(remoteData, "UID:weekly-sync@example.com"))
+
+	// 远端修改后，本地的单独修改以远端为准
+	server.setObject(remoteHref, data)
+	changed, err = as.sync()
+	require.Nil(t, err)
+	require.Len(t, changed, 2)
+	var overrides []*Job
+	require.Nil(t, db.Where("parent_id = ?", series.ID).Find(&overrides).Error)
+	require.Len(t, overrides, 1)
+	assert.Equal(t, "Weekly sync (moved)", overrides[0].Title)
+}
+
+func TestSyncConfigValidate(t *testing.T) {
+	cfg := &SyncConfig{
+		Accounts: []*SyncAccount{
+			{Name: "a", Provider: "caldav", Types: []uint{1, 2}},
+			{Name: "b", Provider: "caldav", Types: []uint{2}},
+		},
+	}
+	assert.NotNil(t, cfg.validate())
+
+	cfg.Accounts[1].Types = []uint{3}
+	assert.Nil(t, cfg.validate())
+	assert.Equal(t, conflictPolicyNewest, cfg.Accounts[0].ConflictPolicy)
+
+	cfg.Accounts[1].Provider = "unknown"
+	assert.NotNil(t, cfg.validate())
+}
@@ -3,6 +3,8 @@ package calendar
 import (
 	"fmt"
 	"time"
+
+	libdate "github.com/rickb777/date"
 )
 
 type Date struct {
@@ -28,3 +30,13 @@ func timeToDate(t time.Time) (d Date) {
 func (d Date) String() string {
 	return fmt.Sprintf("%d-%02d-%02d", d.Year, d.Month, d.Day)
 }
+
+const dateLayout = "2006-01-02"
+
+func parseDate(str string) (libdate.Date, error) {
+	t, err := time.ParseInLocation(dateLayout, str, time.Local)
+	if err != nil {
+		return libdate.Date{}, err
+	}
+	return libdate.NewAt(t), nil
+}
@@ -0,0 +1,784 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+	libdate "github.com/rickb777/date"
+)
+
+// iCalendar (RFC 5545) 的导入和导出
+
+const (
+	icsTimeLayoutUTC   = "20060102T150405Z"
+	icsTimeLayoutLocal = "20060102T150405"
+	icsDateLayout      = "20060102"
+
+	icsProdID        = "-//Deepin//dde-daemon calendar//EN"
+	icsMaxLineOctets = 75
+
+	minutesPerDay = 24 * 60
+
+	// 导入时没有 CATEGORIES 的日程归入“其他”类型
+	defaultImportTypeID = 3
+	// 导入时新建类型使用的颜色
+	defaultImportTypeColor = "#808080"
+)
+
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icsEvent 对应一个 VEVENT
+type icsEvent struct {
+	uid      string
+	typeName string
+	job      *Job
+
+	// LAST-MODIFIED，没有时取 DTSTAMP
+	lastModified time.Time
+	// 带有 RECURRENCE-ID 时，为 uid 相同的重复日程
+	parent *icsEvent
+}
+
+func jobUID(id uint) string {
+	return fmt.Sprintf("%d@dde-calendar", id)
+}
+
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseICSProperty(line string) (*icsProperty, error) {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			inQuote = !inQuote
+		case ';':
+			if !inQuote {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		case ':':
+			if inQuote {
+				continue
+			}
+			parts = append(parts, line[start:i])
+			prop := &icsProperty{
+				name:   strings.ToUpper(parts[0]),
+				params: make(map[string]string),
+				value:  line[i+1:],
+			}
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(param, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+			}
+			return prop, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid content line %q", line)
+}
+
+func parseICS(r io.Reader) ([]*icsEvent, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*icsEvent
+	var props []*icsProperty
+	var alarms [][]*icsProperty
+	var alarm []*icsProperty
+	var inEvent, inAlarm bool
+
+	for _, line := range lines {
+		prop, err := parseICSProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		isBegin := prop.name == "BEGIN"
+		isEnd := prop.name == "END"
+		component := strings.ToUpper(prop.value)
+
+		switch {
+		case isBegin && component == "VEVENT":
+			inEvent = true
+			props = nil
+			alarms = nil
+
+		case isEnd && component == "VEVENT":
+			if !inEvent {
+				return nil, errors.New("unexpected END:VEVENT")
+			}
+			event, err := newICSEvent(props, alarms)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+			inEvent = false
+
+		case inEvent && isBegin && component == "VALARM":
+			inAlarm = true
+			alarm = nil
+
+		case inAlarm && isEnd && component == "VALARM":
+			alarms = append(alarms, alarm)
+			inAlarm = false
+
+		case inAlarm:
+			alarm = append(alarm, prop)
+
+		case inEvent:
+			props = append(props, prop)
+		}
+	}
+	return events, nil
+}
+
+func newICSEvent(props []*icsProperty, alarms [][]*icsProperty) (*icsEvent, error) {
+	job := &Job{}
+	event := &icsEvent{job: job}
+
+	var dtStart, dtEnd, recurrenceID, dtStamp, lastModified *icsProperty
+	var exDates []*icsProperty
+	var duration string
+	for _, prop := range props {
+		switch prop.name {
+		case "UID":
+			event.uid = prop.value
+		case "SUMMARY":
+			job.Title = unescapeICSText(prop.value)
+		case "DESCRIPTION":
+			job.Description = unescapeICSText(prop.value)
+		case "CATEGORIES":
+			if event.typeName == "" {
+				categories := splitICSList(prop.value)
+				if len(categories) > 0 {
+					event.typeName = categories[0]
+				}
+			}
+		case "DTSTART":
+			dtStart = prop
+		case "DTEND":
+			dtEnd = prop
+		case "DURATION":
+			duration = prop.value
+		case "RRULE":
+			job.RRule = prop.value
+		case "EXDATE":
+			exDates = append(exDates, prop)
+		case "RECURRENCE-ID":
+			recurrenceID = prop
+		case "DTSTAMP":
+			dtStamp = prop
+		case "LAST-MODIFIED":
+			lastModified = prop
+		}
+	}
+
+	if dtStart == nil {
+		return nil, fmt.Errorf("event %q has no DTSTART", event.uid)
+	}
+
+	var err error
+	job.Start, job.AllDay, err = parseICSTime(dtStart.value, dtStart.params)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case dtEnd != nil:
+		job.End, _, err = parseICSTime(dtEnd.value, dtEnd.params)
+	case duration != "":
+		var d time.Duration
+		d, err = parseICSDuration(duration)
+		job.End = job.Start.Add(d)
+	default:
+		job.End = job.Start
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if job.AllDay {
+		// DTEND 不包含在日程内，而 Job.End 是最后一天的 23:59
+		endDay := job.End
+		if endDay.After(job.Start) {
+			endDay = endDay.AddDate(0, 0, -1)
+		}
+		job.End = setClock(endDay, Clock{Hour: 23, Minute: 59})
+	}
+
+	var ignore []time.Time
+	for _, prop := range exDates {
+		for _, value := range strings.Split(prop.value, ",") {
+			t, allDay, err := parseICSTime(value, prop.params)
+			if err != nil {
+				return nil, err
+			}
+			if allDay && !job.AllDay {
+				t = setClock(t, Clock{
+					Hour:   job.Start.Hour(),
+					Minute: job.Start.Minute(),
+					Second: job.Start.Second(),
+				})
+			}
+			ignore = append(ignore, t)
+		}
+	}
+	if len(ignore) > 0 {
+		err = job.setIgnore(ignore)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if recurrenceID != nil {
+		job.OriginalStart, _, err = parseICSTime(recurrenceID.value, recurrenceID.params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if lastModified == nil {
+		lastModified = dtStamp
+	}
+	if lastModified != nil {
+		event.lastModified, _, err = parseICSTime(lastModified.value, lastModified.params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Job 只支持一个提醒，取第一个可以转换的 VALARM
+	for _, alarm := range alarms {
+		remind, err := alarmToRemind(job, alarm)
+		if err != nil {
+			logger.Warningf("ignore alarm of event %q: %v", event.uid, err)
+			continue
+		}
+		job.Remind = remind
+		break
+	}
+
+	return event, nil
+}
+
+// resolveICSOverrides 找出带有 RECURRENCE-ID 的 VEVENT 所属的重复日程，找不到时作为独立的日程。
+func resolveICSOverrides(events []*icsEvent) {
+	masters := make(map[string]*icsEvent)
+	for _, event := range events {
+		if event.job.OriginalStart.IsZero() && event.job.RRule != "" {
+			masters[event.uid] = event
+		}
+	}
+
+	for _, event := range events {
+		if event.job.OriginalStart.IsZero() {
+			continue
+		}
+		event.job.RRule = ""
+		event.parent = masters[event.uid]
+		if event.parent == nil {
+			event.job.OriginalStart = time.Time{}
+		}
+	}
+}
+
+func parseICSTime(value string, params map[string]string) (t time.Time, allDay bool, err error) {
+	if params["VALUE"] == "DATE" || len(value) == len(icsDateLayout) {
+		t, err = time.ParseInLocation(icsDateLayout, value, time.Local)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(icsTimeLayoutUTC, value)
+		return t.In(time.Local), false, err
+	}
+
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		loc0, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+		if err != nil {
+			logger.Warningf("unknown TZID %q, use local time zone", tzid)
+		} else {
+			loc = loc0
+		}
+	}
+	t, err = time.ParseInLocation(icsTimeLayoutLocal, value, loc)
+	return t.In(time.Local), false, err
+}
+
+func formatICSTime(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format(icsDateLayout)
+	}
+	return t.UTC().Format(icsTimeLayoutUTC)
+}
+
+// icsTimeProperty 返回 DTSTART, EXDATE 等属性的内容行
+func icsTimeProperty(name string, allDay bool, times ...time.Time) string {
+	values := make([]string, len(times))
+	for idx, t := range times {
+		values[idx] = formatICSTime(t, allDay)
+	}
+	if allDay {
+		name += ";VALUE=DATE"
+	}
+	return name + ":" + strings.Join(values, ",")
+}
+
+var icsDurationReg = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseICSDuration(str string) (time.Duration, error) {
+	match := icsDurationReg.FindStringSubmatch(str)
+	if match == nil {
+		return 0, fmt.Errorf("invalid duration %q", str)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for idx, unit := range units {
+		numStr := match[idx+2]
+		if numStr == "" {
+			continue
+		}
+		n, err := strconv.Atoi(numStr)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * unit
+	}
+	if match[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+func formatICSDuration(minutes int) string {
+	if minutes == 0 {
+		return "PT0S"
+	}
+	var buf bytes.Buffer
+	if minutes < 0 {
+		buf.WriteByte('-')
+		minutes = -minutes
+	}
+	buf.WriteByte('P')
+	days, hours, mins := minutes/minutesPerDay, minutes%minutesPerDay/60, minutes%60
+	if days > 0 {
+		fmt.Fprintf(&buf, "%dD", days)
+	}
+	if hours > 0 || mins > 0 {
+		buf.WriteByte('T')
+		if hours > 0 {
+			fmt.Fprintf(&buf, "%dH", hours)
+		}
+		if mins > 0 {
+			fmt.Fprintf(&buf, "%dM", mins)
+		}
+	}
+	return buf.String()
+}
+
+func alarmToRemind(job *Job, props []*icsProperty) (string, error) {
+	var trigger *icsProperty
+	for _, prop := range props {
+		if prop.name == "TRIGGER" {
+			trigger = prop
+			break
+		}
+	}
+	if trigger == nil {
+		return "", errors.New("no TRIGGER")
+	}
+
+	start := job.Start
+	if job.AllDay {
+		start = setClock(job.Start, Clock{})
+	}
+
+	var offset time.Duration
+	if trigger.params["VALUE"] == "DATE-TIME" {
+		t, _, err := parseICSTime(trigger.value, trigger.params)
+		if err != nil {
+			return "", err
+		}
+		offset = t.Sub(start)
+	} else {
+		d, err := parseICSDuration(trigger.value)
+		if err != nil {
+			return "", err
+		}
+		offset = d
+		if trigger.params["RELATED"] == "END" {
+			offset += job.End.Sub(start)
+		}
+	}
+
+	return offsetToRemind(job.AllDay, int(offset/time.Minute))
+}
+
+// offsetToRemind 把相对开始时间的提醒偏移（分钟）转换成 Job.Remind 的格式
+func offsetToRemind(allDay bool, offset int) (string, error) {
+	before := -offset
+	if !allDay {
+		if before < 0 || before > 7*minutesPerDay {
+			return "", fmt.Errorf("trigger offset %d out of range", offset)
+		}
+		return strconv.Itoa(before), nil
+	}
+
+	nDays := 0
+	if before > 0 {
+		nDays = (before + minutesPerDay - 1) / minutesPerDay
+	}
+	clock := nDays*minutesPerDay - before
+	if nDays > 7 || clock >= minutesPerDay {
+		return "", fmt.Errorf("trigger offset %d out of range", offset)
+	}
+	return fmt.Sprintf("%d;%02d:%02d", nDays, clock/60, clock%60), nil
+}
+
+// getRemindOffset 返回提醒时间相对开始时间的偏移，单位为分钟
+func (j *Job) getRemindOffset() (int, error) {
+	if j.AllDay && remindReg1.MatchString(j.Remind) {
+		var nDays, hour, min int
+		_, err := fmt.Sscanf(j.Remind, "%d;%d:%d", &nDays, &hour, &min)
+		if err != nil {
+			return 0, err
+		}
+		return -nDays*minutesPerDay + hour*60 + min, nil
+	}
+
+	remindT, err := j.getRemindTime()
+	if err != nil {
+		return 0, err
+	}
+	start := j.Start
+	if j.AllDay {
+		start = setClock(j.Start, Clock{})
+	}
+	return int(remindT.Sub(start) / time.Minute), nil
+}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escapeICSText(str string) string {
+	return icsTextEscaper.Replace(str)
+}
+
+func unescapeICSText(str string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if c == '\\' && i+1 < len(str) {
+			i++
+			switch str[i] {
+			case 'n', 'N':
+				buf.WriteByte('\n')
+			default:
+				buf.WriteByte(str[i])
+			}
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+func splitICSList(value string) []string {
+	var result []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			result = append(result, unescapeICSText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(result, unescapeICSText(value[start:]))
+}
+
+// writeICSLine 写入一个内容行，超过 75 字节时折行
+func writeICSLine(buf *bytes.Buffer, line string) {
+	first := true
+	for {
+		limit := icsMaxLineOctets
+		if !first {
+			// 续行以空格开头
+			limit--
+		}
+		cut := len(line)
+		if cut > limit {
+			cut = limit
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+		}
+		if !first {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n")
+		line = line[cut:]
+		first = false
+		if line == "" {
+			return
+		}
+	}
+}
+
+func writeICS(w io.Writer, events []*icsEvent) error {
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:"+icsProdID)
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+	for _, event := range events {
+		err := event.writeTo(&buf)
+		if err != nil {
+			return err
+		}
+	}
+	writeICSLine(&buf, "END:VCALENDAR")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (event *icsEvent) writeTo(buf *bytes.Buffer) error {
+	job := event.job
+	stamp := job.UpdatedAt
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	end := job.End
+	if job.AllDay {
+		end = setClock(job.End, Clock{}).AddDate(0, 0, 1)
+	}
+
+	writeICSLine(buf, "BEGIN:VEVENT")
+	writeICSLine(buf, "UID:"+event.uid)
+	writeICSLine(buf, "DTSTAMP:"+stamp.UTC().Format(icsTimeLayoutUTC))
+	writeICSLine(buf, "LAST-MODIFIED:"+stamp.UTC().Format(icsTimeLayoutUTC))
+	writeICSLine(buf, icsTimeProperty("DTSTART", job.AllDay, job.Start))
+	writeICSLine(buf, icsTimeProperty("DTEND", job.AllDay, end))
+	if !job.OriginalStart.IsZero() {
+		writeICSLine(buf, icsTimeProperty("RECURRENCE-ID", job.AllDay, job.OriginalStart))
+	}
+	writeICSLine(buf, "SUMMARY:"+escapeICSText(job.Title))
+	if job.Description != "" {
+		writeICSLine(buf, "DESCRIPTION:"+escapeICSText(job.Description))
+	}
+	if event.typeName != "" {
+		writeICSLine(buf, "CATEGORIES:"+escapeICSText(event.typeName))
+	}
+	if job.RRule != "" {
+		writeICSLine(buf, "RRULE:"+job.RRule)
+	}
+
+	ignore, err := job.getIgnore()
+	if err != nil {
+		return err
+	}
+	if len(ignore) > 0 {
+		writeICSLine(buf, icsTimeProperty("EXDATE", job.AllDay, ignore...))
+	}
+
+	if job.Remind != "" {
+		offset, err := job.getRemindOffset()
+		if err != nil {
+			return err
+		}
+		writeICSLine(buf, "BEGIN:VALARM")
+		writeICSLine(buf, "ACTION:DISPLAY")
+		writeICSLine(buf, "DESCRIPTION:"+escapeICSText(job.Title))
+		writeICSLine(buf, "TRIGGER:"+formatICSDuration(offset))
+		writeICSLine(buf, "END:VALARM")
+	}
+	writeICSLine(buf, "END:VEVENT")
+	return nil
+}
+
+func (s *Scheduler) importICS(r io.Reader) ([]uint, error) {
+	events, err := parseICS(r)
+	if err != nil {
+		return nil, err
+	}
+	resolveICSOverrides(events)
+
+	// 先创建重复日程，再创建单独修改过的那些次
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].parent == nil && events[j].parent != nil
+	})
+
+	var ids []uint
+	typeIDs := make(map[string]uint)
+	err = s.withTx(func(tx *gorm.DB) error {
+		for _, event := range events {
+			job := event.job
+			job.ID = 0
+			job.ParentID = 0
+			if event.parent != nil {
+				job.ParentID = event.parent.job.ID
+				if job.ParentID == 0 {
+					// 所属的重复日程没有导入
+					job.OriginalStart = time.Time{}
+				}
+			}
+
+			err := job.validate()
+			if err != nil {
+				logger.Warningf("skip event %q: %v", event.uid, err)
+				continue
+			}
+
+			typeID, err := getImportTypeID(tx, event.typeName, typeIDs)
+			if err != nil {
+				return err
+			}
+			job.Type = int(typeID)
+			err = tx.Create(job).Error
+			if err != nil {
+				return err
+			}
+			ids = append(ids, job.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// getImportTypeID 按名称查找日程类型，找不到时新建一个
+func getImportTypeID(tx *gorm.DB, name string, cache map[string]uint) (uint, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return defaultImportTypeID, nil
+	}
+	if id, ok := cache[name]; ok {
+		return id, nil
+	}
+
+	var types []JobType
+	err := tx.Where("name = ?", name).Find(&types).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(types) > 0 {
+		cache[name] = types[0].ID
+		return types[0].ID, nil
+	}
+
+	jobType := &JobType{
+		Name:  name,
+		Color: defaultImportTypeColor,
+	}
+	err = tx.Create(jobType).Error
+	if err != nil {
+		return 0, err
+	}
+	cache[name] = jobType.ID
+	return jobType.ID, nil
+}
+
+func (s *Scheduler) exportICS(startDate, endDate libdate.Date, w io.Writer) error {
+	var allJobs []*Job
+	err := s.db.Find(&allJobs).Error
+	if err != nil {
+		return err
+	}
+
+	var types []JobType
+	err = s.db.Find(&types).Error
+	if err != nil {
+		return err
+	}
+	typeNames := make(map[uint]string, len(types))
+	for _, t := range types {
+		typeNames[t.ID] = t.Name
+	}
+
+	markOverridden(allJobs, allJobs)
+	overrides := make(map[uint][]*Job)
+	for _, job := range allJobs {
+		if job.ParentID != 0 {
+			overrides[job.ParentID] = append(overrides[job.ParentID], job)
+		}
+	}
+
+	// 单独修改过的一次在范围内时，也要导出整个重复日程
+	exported := make(map[uint]bool)
+	for _, job := range allJobs {
+		jobTimes, err := job.between(startDate, endDate)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		if len(jobTimes) == 0 {
+			continue
+		}
+		if job.ParentID != 0 {
+			exported[job.ParentID] = true
+		} else {
+			exported[job.ID] = true
+		}
+	}
+
+	var events []*icsEvent
+	for _, job := range allJobs {
+		if job.ParentID != 0 || !exported[job.ID] {
+			continue
+		}
+		uid := jobUID(job.ID)
+		events = append(events, &icsEvent{
+			uid:      uid,
+			typeName: typeNames[uint(job.Type)],
+			job:      job,
+		})
+		for _, override := range overrides[job.ID] {
+			events = append(events, &icsEvent{
+				uid:      uid,
+				typeName: typeNames[uint(override.Type)],
+				job:      override,
+			})
+		}
+	}
+	return writeICS(w, events)
+}
@@ -0,0 +1,167 @@
+package calendar
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	libdate "github.com/rickb777/date"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTimeUTC(y int, m time.Month, d int, h int, min int) time.Time {
+	return time.Date(y, m, d, h, min, 0, 0, time.UTC)
+}
+
+func loadICSEvents(t *testing.T, filename string) []*icsEvent {
+	f, err := os.Open(filename)
+	require.Nil(t, err)
+	defer f.Close()
+
+	events, err := parseICS(f)
+	require.Nil(t, err)
+	resolveICSOverrides(events)
+	return events
+}
+
+func assertJobEqual(t *testing.T, expected, actual *Job) {
+	assert.Equal(t, expected.Title, actual.Title)
+	assert.Equal(t, expected.Description, actual.Description)
+	assert.Equal(t, expected.AllDay, actual.AllDay)
+	assert.True(t, expected.Start.Equal(actual.Start), "start %v != %v", expected.Start, actual.Start)
+	assert.True(t, expected.End.Equal(actual.End), "end %v != %v", expected.End, actual.End)
+	assert.Equal(t, expected.RRule, actual.RRule)
+	assert.Equal(t, expected.Remind, actual.Remind)
+	assert.True(t, expected.OriginalStart.Equal(actual.OriginalStart),
+		"original start %v != %v", expected.OriginalStart, actual.OriginalStart)
+
+	ignore0, err := expected.getIgnore()
+	assert.Nil(t, err)
+	ignore1, err := actual.getIgnore()
+	assert.Nil(t, err)
+	assert.Equal(t, len(ignore0), len(ignore1))
+	for _, t0 := range ignore0 {
+		assert.True(t, timeSliceContains(ignore1, t0), "ignore %v lost", t0)
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	events := loadICSEvents(t, "testdata/recurring.ics")
+	require.Len(t, events, 4)
+
+	weekly := events[0]
+	assert.Equal(t, "weekly-sync@example.com", weekly.uid)
+	assert.Equal(t, "Work", weekly.typeName)
+	assert.Equal(t, "Weekly sync, team A", weekly.job.Title)
+	assert.Equal(t, "Agenda:\nstatus\nplans", weekly.job.Description)
+	assert.True(t, weekly.job.Start.Equal(newTimeUTC(2019, 9, 2, 1, 0)))
+	assert.True(t, weekly.job.End.Equal(newTimeUTC(2019, 9, 2, 2, 0)))
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO;COUNT=10", weekly.job.RRule)
+	assert.Equal(t, "15", weekly.job.Remind)
+	ignore, err := weekly.job.getIgnore()
+	assert.Nil(t, err)
+	assert.Len(t, ignore, 2)
+	assert.True(t, timeSliceContains(ignore, newTimeUTC(2019, 9, 16, 1, 0)))
+	assert.True(t, timeSliceContains(ignore, newTimeUTC(2019, 9, 23, 1, 0)))
+
+	moved := events[1]
+	assert.Equal(t, "Weekly sync (moved)", moved.job.Title)
+	assert.Equal(t, "", moved.job.RRule)
+	assert.Equal(t, weekly, moved.parent)
+	assert.True(t, moved.job.OriginalStart.Equal(newTimeUTC(2019, 9, 9, 1, 0)))
+	assert.True(t, moved.job.Start.Equal(newTimeUTC(2019, 9, 10, 3, 0)))
+
+	// 被单独修改的那一次不再由重复规则产生
+	weekly.job.ID = 1
+	moved.job.ParentID = 1
+	markOverridden([]*Job{weekly.job}, []*Job{moved.job})
+	jobTimes, err := weekly.job.between(libdate.New(2019, 9, 1), libdate.New(2019, 9, 30))
+	assert.Nil(t, err)
+	assert.Len(t, jobTimes, 2)
+
+	holiday := events[2]
+	assert.True(t, holiday.job.AllDay)
+	assert.Equal(t, newTimeYMDHM(2019, 10, 1, 0, 0), holiday.job.Start)
+	assert.Equal(t, newTimeYMDHM(2019, 10, 3, 23, 59), holiday.job.End)
+	assert.Equal(t, "1;09:00", holiday.job.Remind)
+
+	standup := events[3]
+	assert.Equal(t, "Daily standup with a rather long title that has to be folded when it is written back",
+		standup.job.Title)
+	assert.True(t, standup.job.End.Equal(newTimeUTC(2019, 9, 2, 1, 45)))
+	jobTimes, err = standup.job.between(libdate.New(2019, 9, 1), libdate.New(2019, 9, 30))
+	assert.Nil(t, err)
+	assert.Len(t, jobTimes, 10)
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	events := loadICSEvents(t, "testdata/recurring.ics")
+
+	var buf bytes.Buffer
+	err := writeICS(&buf, events)
+	require.Nil(t, err)
+	for _, line := range bytes.Split(buf.Bytes(), []byte("\r\n")) {
+		assert.True(t, len(line) <= icsMaxLineOctets, "line too long: %q", line)
+	}
+
+	events1, err := parseICS(&buf)
+	require.Nil(t, err)
+	require.Len(t, events1, len(events))
+	for idx, event := range events {
+		assert.Equal(t, event.uid, events1[idx].uid)
+		assert.Equal(t, event.typeName, events1[idx].typeName)
+		assertJobEqual(t, event.job, events1[idx].job)
+	}
+}
+
+func TestICSDuration(t *testing.T) {
+	tests := []struct {
+		str     string
+		minutes int
+	}{
+		{"PT0S", 0},
+		{"-PT15M", -15},
+		{"-PT15H", -15 * 60},
+		{"-P1DT2H30M", -(minutesPerDay + 150)},
+		{"PT9H", 9 * 60},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.str, formatICSDuration(test.minutes))
+		d, err := parseICSDuration(test.str)
+		assert.Nil(t, err)
+		assert.Equal(t, time.Duration(test.minutes)*time.Minute, d)
+	}
+
+	d, err := parseICSDuration("-P1W")
+	assert.Nil(t, err)
+	assert.Equal(t, -7*24*time.Hour, d)
+
+	_, err = parseICSDuration("15M")
+	assert.NotNil(t, err)
+}
+
+func TestOffsetToRemind(t *testing.T) {
+	remind, err := offsetToRemind(false, -15)
+	assert.Nil(t, err)
+	assert.Equal(t, "15", remind)
+
+	_, err = offsetToRemind(false, 15)
+	assert.NotNil(t, err)
+
+	remind, err = offsetToRemind(true, -15*60)
+	assert.Nil(t, err)
+	assert.Equal(t, "1;09:00", remind)
+
+	remind, err = offsetToRemind(true, 9*60)
+	assert.Nil(t, err)
+	assert.Equal(t, "0;09:00", remind)
+
+	remind, err = offsetToRemind(true, -2*minutesPerDay)
+	assert.Nil(t, err)
+	assert.Equal(t, "2;00:00", remind)
+
+	_, err = offsetToRemind(true, -8*minutesPerDay)
+	assert.NotNil(t, err)
+}
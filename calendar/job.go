@@ -33,7 +33,12 @@ type Job struct {
 	RecurID int    `gorm:"-"`
 	Ignore  string // 忽略，JSON
 
+	// 单独修改重复日程中的某一次时，ParentID 为重复日程的 id，OriginalStart 为这一次原来的开始时间
+	ParentID      uint `gorm:"default:0"`
+	OriginalStart time.Time
+
 	remindTime time.Time
+	overridden []time.Time // 重复日程中被单独修改过的那些次的开始时间
 }
 
 type JobJSON struct {
@@ -48,6 +53,9 @@ type JobJSON struct {
 	RecurID     int
 	Ignore      []time.Time
 
+	ParentID      uint
+	OriginalStart time.Time
+
 	remindLaterCount int
 }
 
@@ -71,6 +79,9 @@ func (j *Job) toJobJSON() (*JobJSON, error) {
 		Remind:      j.Remind,
 		RecurID:     j.RecurID,
 		Ignore:      ignore,
+
+		ParentID:      j.ParentID,
+		OriginalStart: j.OriginalStart,
 	}, nil
 }
 
@@ -95,6 +106,9 @@ func (j *JobJSON) toJob() (*Job, error) {
 		Remind:      j.Remind,
 		RecurID:     j.RecurID,
 		Ignore:      ignore,
+
+		ParentID:      j.ParentID,
+		OriginalStart: j.OriginalStart,
 	}
 	job.ID = j.ID
 	return job, nil
@@ -155,6 +169,26 @@ func timeSliceContains(timeSlice []time.Time, t time.Time) bool {
 	return false
 }
 
+// markOverridden 把 overrides 中单独修改过的那一次记录到所属的重复日程中
+func markOverridden(jobs []*Job, overrides []*Job) {
+	jobMap := make(map[uint]*Job)
+	for _, job := range jobs {
+		if job.RRule != "" {
+			job.overridden = nil
+			jobMap[job.ID] = job
+		}
+	}
+	for _, override := range overrides {
+		if override.ParentID == 0 {
+			continue
+		}
+		parent := jobMap[override.ParentID]
+		if parent != nil {
+			parent.overridden = append(parent.overridden, override.OriginalStart)
+		}
+	}
+}
+
 type jobTime struct {
 	start   time.Time
 	recurID int
@@ -249,12 +283,7 @@ func (j *Job) between(startDate, endDate libdate.Date) ([]jobTime, error) {
 		return nil, nil
 	}
 
-	rOpt, err := rrule.StrToROptionInLocation(j.RRule, time.Local)
-	if err != nil {
-		return nil, err
-	}
-	rOpt.Dtstart = j.Start
-	rule, err := rrule.NewRRule(*rOpt)
+	rule, err := j.newRRule()
 	if err != nil {
 		return nil, err
 	}
@@ -280,7 +309,7 @@ func (j *Job) between(startDate, endDate libdate.Date) ([]jobTime, error) {
 		jEndDate := jStartDate.Add(nDays)
 
 		if (dateRange{startDate, endDate}).overlap(dateRange{jStartDate, jEndDate}) {
-			if timeSliceContains(ignore, start) {
+			if timeSliceContains(ignore, start) || timeSliceContains(j.overridden, start) {
 				// ignore this job
 			} else {
 				result = append(result, jobTime{start: start, recurID: count})
@@ -293,6 +322,94 @@ func (j *Job) between(startDate, endDate libdate.Date) ([]jobTime, error) {
 	return result, nil
 }
 
+func (j *Job) newRRule() (*rrule.RRule, error) {
+	rOpt, err := rrule.StrToROptionInLocation(j.RRule, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	rOpt.Dtstart = j.Start
+	return rrule.NewRRule(*rOpt)
+}
+
+// getOccurrenceStart 返回第 recurID 次重复的开始时间
+func (j *Job) getOccurrenceStart(recurID int) (time.Time, error) {
+	if recurID == 0 {
+		return j.Start, nil
+	}
+	if j.RRule == "" || recurID < 0 || recurID >= recurrenceLimit {
+		return time.Time{}, fmt.Errorf("invalid recurID %d", recurID)
+	}
+
+	rule, err := j.newRRule()
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := rule.Iterator()
+	for count := 0; ; count++ {
+		start, ok := next()
+		if !ok {
+			return time.Time{}, fmt.Errorf("invalid recurID %d", recurID)
+		}
+		if count == recurID {
+			return start, nil
+		}
+	}
+}
+
+// hasOccurrence 判断 t 是不是某一次重复的开始时间
+func (j *Job) hasOccurrence(t time.Time) (bool, error) {
+	if j.RRule == "" {
+		return t.Equal(j.Start), nil
+	}
+	rule, err := j.newRRule()
+	if err != nil {
+		return false, err
+	}
+	return len(rule.Between(t, t, true)) > 0, nil
+}
+
+// countOccurrencesBefore 返回在 t 之前开始的重复次数
+func (j *Job) countOccurrencesBefore(t time.Time) (int, error) {
+	if j.RRule == "" {
+		if j.Start.Before(t) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	rule, err := j.newRRule()
+	if err != nil {
+		return 0, err
+	}
+	next := rule.Iterator()
+	count := 0
+	for count < recurrenceLimit {
+		start, ok := next()
+		if !ok || !start.Before(t) {
+			break
+		}
+		count++
+	}
+	return count, nil
+}
+
+// setRRuleEnd 去掉 rule 中的 COUNT 和 UNTIL，count 大于 0 时设置 COUNT，否则 until 不为零时设置 UNTIL。
+func setRRuleEnd(rule string, count int, until time.Time) string {
+	var parts []string
+	for _, part := range strings.Split(rule, ";") {
+		key := strings.ToUpper(strings.SplitN(part, "=", 2)[0])
+		if part == "" || key == "COUNT" || key == "UNTIL" {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(count))
+	} else if !until.IsZero() {
+		parts = append(parts, "UNTIL="+until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
 // 返回提醒设置提前几天
 func getRemindAdvanceDays(remind string) (int, error) {
 	var err error
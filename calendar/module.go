@@ -99,9 +99,21 @@ func (m *Module) start() error {
 		}
 	}
 
+	err = db.AutoMigrate(&SyncRecord{}, &SyncAccountState{}).Error
+	if err != nil {
+		logger.Warning(err)
+	}
+
 	service := loader.GetService()
 	m.scheduler = newScheduler(db, service)
 
+	syncCfg, err := loadSyncConfig(syncConfigFile)
+	if err == nil {
+		m.scheduler.initSync(syncCfg)
+	} else if !os.IsNotExist(err) {
+		logger.Warning("failed to load sync config:", err)
+	}
+
 	err = service.Export(dbusPath, m.scheduler)
 	if err != nil {
 		return err
@@ -113,12 +125,16 @@ func (m *Module) start() error {
 	}
 
 	m.scheduler.startRemindLoop()
+	if syncCfg != nil {
+		m.scheduler.startSyncLoop(syncCfg.getInterval())
+	}
 	return nil
 }
 
 func newModule() *Module {
 	m := new(Module)
 	m.ModuleBase = loader.NewModuleBase("calendar", m, logger)
+	m.SetStartConcurrently(true)
 	return m
 }
 
@@ -0,0 +1,262 @@
+package calendar
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/teambition/rrule-go"
+)
+
+// 单独修改重复日程中的某一次，类似 iCalendar 中带 RECURRENCE-ID 的 VEVENT。
+// 修改后的这一次保存为一个 ParentID 为重复日程 id 的 Job。
+
+func (s *Scheduler) getOverrides(parentID uint) ([]*Job, error) {
+	var overrides []*Job
+	err := s.db.Where("parent_id = ?", parentID).Find(&overrides).Error
+	return overrides, err
+}
+
+func (s *Scheduler) markJobsOverridden(jobs []*Job) error {
+	var overrides []*Job
+	err := s.db.Select("id, parent_id, original_start").
+		Where("parent_id > 0").Find(&overrides).Error
+	if err != nil {
+		return err
+	}
+	markOverridden(jobs, overrides)
+	return nil
+}
+
+// ignoreOccurrence 在重复日程的忽略列表中加入 start
+func ignoreOccurrence(tx *gorm.DB, parentID uint, start time.Time) error {
+	var parent Job
+	db := tx.First(&parent, parentID)
+	if db.RecordNotFound() {
+		return nil
+	}
+	if db.Error != nil {
+		return db.Error
+	}
+
+	ignore, err := parent.getIgnore()
+	if err != nil {
+		return err
+	}
+	if timeSliceContains(ignore, start) {
+		return nil
+	}
+	err = parent.setIgnore(append(ignore, start))
+	if err != nil {
+		return err
+	}
+	return tx.Model(&parent).Update("Ignore", parent.Ignore).Error
+}
+
+// removeStaleOverrides 删除不再对应重复日程中任何一次的单独修改
+func (s *Scheduler) removeStaleOverrides(series *Job) error {
+	overrides, err := s.getOverrides(series.ID)
+	if err != nil {
+		return err
+	}
+	for _, override := range overrides {
+		ok, err := series.hasOccurrence(override.OriginalStart)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		logger.Debugf("remove stale override %d of job %d", override.ID, series.ID)
+		err = s.db.Unscoped().Delete(override).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateOccurrence 修改重复日程中的某一次，job.ID 和 job.RecurID 指定是哪一次，返回这一次的 id。
+func (s *Scheduler) updateOccurrence(job *Job) (uint, error) {
+	err := job.validate()
+	if err != nil {
+		return 0, err
+	}
+
+	var series Job
+	err = s.db.First(&series, job.ID).Error
+	if err != nil {
+		return 0, err
+	}
+	if series.ParentID != 0 || series.RRule == "" {
+		// 已经是单独的一次，或者不重复
+		return job.ID, s.updateJob(job)
+	}
+
+	originalStart, err := series.getOccurrenceStart(job.RecurID)
+	if err != nil {
+		return 0, err
+	}
+
+	overrides, err := s.getOverrides(series.ID)
+	if err != nil {
+		return 0, err
+	}
+	for _, override := range overrides {
+		if override.OriginalStart.Equal(originalStart) {
+			job.ID = override.ID
+			return override.ID, s.updateJob(job)
+		}
+	}
+
+	override := &Job{
+		Type:          job.Type,
+		Title:         job.Title,
+		Description:   job.Description,
+		AllDay:        job.AllDay,
+		Start:         job.Start,
+		End:           job.End,
+		Remind:        job.Remind,
+		ParentID:      series.ID,
+		OriginalStart: originalStart,
+	}
+	err = s.db.Create(override).Error
+	if err != nil {
+		return 0, err
+	}
+	return override.ID, nil
+}
+
+// splitJob 把重复日程从 job 指定的这一次开始拆分，这一次及以后的重复使用 job 的内容，返回新的重复日程的 id。
+func (s *Scheduler) splitJob(job *Job) (uint, error) {
+	err := job.validate()
+	if err != nil {
+		return 0, err
+	}
+
+	var series Job
+	err = s.db.First(&series, job.ID).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var originalStart time.Time
+	if series.ParentID != 0 {
+		originalStart = series.OriginalStart
+		err = s.db.First(&series, series.ParentID).Error
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		originalStart, err = series.getOccurrenceStart(job.RecurID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if series.RRule == "" {
+		return 0, errors.New("job is not recurring")
+	}
+
+	if !originalStart.After(series.Start) {
+		// 从第一次开始拆分就是修改整个重复日程
+		job.ID = series.ID
+		return series.ID, s.updateJob(job)
+	}
+
+	nBefore, err := series.countOccurrencesBefore(originalStart)
+	if err != nil {
+		return 0, err
+	}
+	newRRule := job.RRule
+	if newRRule == series.RRule {
+		rOpt, err := rrule.StrToROptionInLocation(series.RRule, time.Local)
+		if err != nil {
+			return 0, err
+		}
+		if rOpt.Count > 0 {
+			newRRule = setRRuleEnd(newRRule, rOpt.Count-nBefore, time.Time{})
+		}
+	}
+
+	newSeries := &Job{
+		Type:        job.Type,
+		Title:       job.Title,
+		Description: job.Description,
+		AllDay:      job.AllDay,
+		Start:       job.Start,
+		End:         job.End,
+		RRule:       newRRule,
+		Remind:      job.Remind,
+	}
+	err = newSeries.validate()
+	if err != nil {
+		return 0, err
+	}
+
+	// 之后的忽略和单独修改跟随新的重复日程
+	delta := job.Start.Sub(originalStart)
+	ignore, err := series.getIgnore()
+	if err != nil {
+		return 0, err
+	}
+	var oldIgnore, newIgnore []time.Time
+	for _, t := range ignore {
+		if t.Before(originalStart) {
+			oldIgnore = append(oldIgnore, t)
+		} else {
+			newIgnore = append(newIgnore, t.Add(delta))
+		}
+	}
+	err = series.setIgnore(oldIgnore)
+	if err != nil {
+		return 0, err
+	}
+	err = newSeries.setIgnore(newIgnore)
+	if err != nil {
+		return 0, err
+	}
+
+	overrides, err := s.getOverrides(series.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	oldRRule := setRRuleEnd(series.RRule, 0, originalStart.Add(-time.Second))
+	err = s.withTx(func(tx *gorm.DB) error {
+		err := tx.Model(&series).Updates(map[string]interface{}{
+			"RRule":  oldRRule,
+			"Ignore": series.Ignore,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(newSeries).Error
+		if err != nil {
+			return err
+		}
+
+		for _, override := range overrides {
+			if override.OriginalStart.Before(originalStart) {
+				continue
+			}
+			if override.OriginalStart.Equal(originalStart) {
+				// 被 job 代替
+				err = tx.Unscoped().Delete(override).Error
+			} else {
+				err = tx.Model(override).Updates(map[string]interface{}{
+					"ParentID":      newSeries.ID,
+					"OriginalStart": override.OriginalStart.Add(delta),
+				}).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newSeries.ID, nil
+}
@@ -29,6 +29,9 @@ type Scheduler struct {
 	changeChan chan []uint
 	quitChan   chan struct{}
 
+	syncers  []*accountSyncer
+	syncChan chan struct{}
+
 	methods *struct {
 		GetJobs   func() `in:"startYear,startMonth,startDay,endYear,endMonth,endDay" out:"jobs"`
 		GetJob    func() `in:"id" out:"job"`
@@ -36,6 +39,12 @@ type Scheduler struct {
 		DeleteJob func() `in:"id"`
 		UpdateJob func() `in:"jobInfo"`
 		CreateJob func() `in:"jobInfo" out:"id"`
+		ImportICS func() `in:"path" out:"ids"`
+		ExportICS func() `in:"startDate,endDate,path"`
+		Sync      func()
+
+		UpdateOccurrence func() `in:"jobInfo" out:"id"`
+		SplitJob         func() `in:"jobInfo" out:"id"`
 
 		GetTypes   func() `out:"types"`
 		GetType    func() `in:"id" out:"type"`
@@ -60,6 +69,7 @@ func newScheduler(db *gorm.DB, service *dbusutil.Service) *Scheduler {
 		service:           service,
 		changeChan:        make(chan []uint),
 		quitChan:          make(chan struct{}),
+		syncChan:          make(chan struct{}, 1),
 		notifications:     notifications.NewNotifications(sessionBus),
 		notifyJobMap:      make(map[uint32]*JobJSON),
 		remindLaterTimers: make(map[uint]*time.Timer),
@@ -218,6 +228,7 @@ func (s *Scheduler) getJobs(startDate, endDate libdate.Date) ([]dateJobsWrap, er
 	if err != nil {
 		return nil, err
 	}
+	markOverridden(allJobs, allJobs)
 
 	t0 := time.Now()
 	result := getJobsBetween(startDate, endDate, allJobs, true)
@@ -247,6 +258,10 @@ func (s *Scheduler) queryJobs(key string, startTime, endTime time.Time) ([]dateJ
 	if err != nil {
 		return nil, err
 	}
+	err = s.markJobsOverridden(allJobs)
+	if err != nil {
+		return nil, err
+	}
 
 	startDate := libdate.NewAt(startTime)
 	endDate := libdate.NewAt(endTime)
@@ -285,12 +300,26 @@ func filterJobs(jobs []*Job, timeRange TimeRange) []*Job {
 
 func (s *Scheduler) deleteJob(id uint) error {
 	var job Job
-	err := s.db.Select("id").First(&job, id).Error
+	err := s.db.Select("id, parent_id, original_start").First(&job, id).Error
 	if err != nil {
 		return err
 	}
 
-	return s.db.Unscoped().Delete(&job).Error
+	return s.withTx(func(tx *gorm.DB) error {
+		if job.ParentID != 0 {
+			// 删除单独修改过的一次，重复日程中的这一次也不再出现
+			err := ignoreOccurrence(tx, job.ParentID, job.OriginalStart)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := tx.Unscoped().Where("parent_id = ?", job.ID).Delete(&Job{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&job).Error
+	})
 }
 
 func (s *Scheduler) updateJob(job *Job) error {
@@ -303,6 +332,10 @@ func (s *Scheduler) updateJob(job *Job) error {
 	if err != nil {
 		return err
 	}
+	if job0.ParentID != 0 {
+		// 单独修改过的一次不能再重复
+		job.RRule = ""
+	}
 
 	diffMap := make(map[string]interface{})
 
@@ -334,8 +367,18 @@ func (s *Scheduler) updateJob(job *Job) error {
 		diffMap["Ignore"] = job.Ignore
 	}
 
-	if len(diffMap) > 0 {
-		err = s.db.Model(job).Updates(diffMap).Error
+	if len(diffMap) == 0 {
+		return nil
+	}
+	err = s.db.Model(job).Updates(diffMap).Error
+	if err != nil {
+		return err
+	}
+
+	_, startChanged := diffMap["Start"]
+	_, rruleChanged := diffMap["RRule"]
+	if job0.RRule != "" && (startChanged || rruleChanged) {
+		err = s.removeStaleOverrides(job)
 	}
 	return err
 }
@@ -346,6 +389,8 @@ func (s *Scheduler) createJob(job *Job) error {
 		return err
 	}
 	job.ID = 0
+	job.ParentID = 0
+	job.OriginalStart = time.Time{}
 
 	err = s.db.Create(job).Error
 	return err
@@ -523,40 +568,19 @@ func (s *Scheduler) setJobRemind(jj *JobJSON, remind string) error {
 	}
 
 	if job.RRule != "" {
+		// 只修改这一次的提醒
 		newJob := Job{
-			Type:        jj.Type,
-			Title:       jj.Title,
-			Description: jj.Description,
-			AllDay:      jj.AllDay,
-			Start:       jj.Start,
-			End:         jj.End,
-			Remind:      remind,
+			Type:          jj.Type,
+			Title:         jj.Title,
+			Description:   jj.Description,
+			AllDay:        jj.AllDay,
+			Start:         jj.Start,
+			End:           jj.End,
+			Remind:        remind,
+			ParentID:      job.ID,
+			OriginalStart: jj.Start,
 		}
-		err = s.withTx(func(tx *gorm.DB) error {
-			ignore, err := job.getIgnore()
-			if err != nil {
-				return err
-			}
-
-			if !timeSliceContains(ignore, jj.Start) {
-				ignore = append(ignore, jj.Start)
-				err = job.setIgnore(ignore)
-				if err != nil {
-					return err
-				}
-
-				err = tx.Model(&job).Update("Ignore", job.Ignore).Error
-				if err != nil {
-					return err
-				}
-
-			} else {
-				logger.Warning("job.ignore already contains jj.Start")
-			}
-
-			err = tx.Create(&newJob).Error
-			return err
-		})
+		err = s.db.Create(&newJob).Error
 		if err != nil {
 			return err
 		}
@@ -575,8 +599,12 @@ func (s *Scheduler) setJobRemind(jj *JobJSON, remind string) error {
 	return nil
 }
 
-func (s *Scheduler) withTx(fn func(db *gorm.DB) error) (err error) {
-	tx := s.db.Begin()
+func (s *Scheduler) withTx(fn func(db *gorm.DB) error) error {
+	return withTx(s.db, fn)
+}
+
+func withTx(db *gorm.DB, fn func(db *gorm.DB) error) (err error) {
+	tx := db.Begin()
 	defer func() {
 		if p := recover(); p != nil {
 			// a panic occurred, rollback and re-panic
@@ -665,6 +693,7 @@ func (s *Scheduler) DebugRemindJob(id int64) *dbus.Error {
 
 func (s *Scheduler) notifyJobsChange(ids ...uint) {
 	s.changeChan <- ids
+	s.requestSync()
 }
 
 func (s *Scheduler) startRemindLoop() {
@@ -722,6 +751,10 @@ func (s *Scheduler) getRemindJobs(tr TimeRange) ([]*Job, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = s.markJobsOverridden(allJobs)
+	if err != nil {
+		return nil, err
+	}
 	startDate := libdate.NewAt(tr.start)
 	endDate := startDate.Add(8)
 
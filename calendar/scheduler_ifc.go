@@ -1,6 +1,10 @@
 package calendar
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
 	"time"
 
 	libdate "github.com/rickb777/date"
@@ -81,6 +85,44 @@ func (s *Scheduler) UpdateJob(jobStr string) *dbus.Error {
 	return dbusutil.ToError(err)
 }
 
+func (s *Scheduler) UpdateOccurrence(jobStr string) (int64, *dbus.Error) {
+	var jj JobJSON
+	err := fromJson(jobStr, &jj)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+
+	job, err := jj.toJob()
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	id, err := s.updateOccurrence(job)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	s.notifyJobsChange(jj.ID, id)
+	return int64(id), nil
+}
+
+func (s *Scheduler) SplitJob(jobStr string) (int64, *dbus.Error) {
+	var jj JobJSON
+	err := fromJson(jobStr, &jj)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+
+	job, err := jj.toJob()
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	id, err := s.splitJob(job)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	s.notifyJobsChange(jj.ID, id)
+	return int64(id), nil
+}
+
 func (s *Scheduler) CreateJob(jobStr string) (int64, *dbus.Error) {
 	var jj JobJSON
 	err := fromJson(jobStr, &jj)
@@ -100,6 +142,56 @@ func (s *Scheduler) CreateJob(jobStr string) (int64, *dbus.Error) {
 	return int64(job.ID), nil
 }
 
+func (s *Scheduler) ImportICS(path string) ([]int64, *dbus.Error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	defer f.Close()
+
+	ids, err := s.importICS(f)
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	if len(ids) > 0 {
+		s.notifyJobsChange(ids...)
+		s.emitJobsUpdated(ids...)
+	}
+
+	result := make([]int64, len(ids))
+	for idx, id := range ids {
+		result[idx] = int64(id)
+	}
+	return result, nil
+}
+
+func (s *Scheduler) ExportICS(startDate, endDate, path string) *dbus.Error {
+	start, err := parseDate(startDate)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	end, err := parseDate(endDate)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	var buf bytes.Buffer
+	err = s.exportICS(start, end, &buf)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+	return dbusutil.ToError(err)
+}
+
+func (s *Scheduler) Sync() *dbus.Error {
+	if len(s.syncers) == 0 {
+		return dbusutil.ToError(errors.New("no sync account"))
+	}
+	s.requestSync()
+	return nil
+}
+
 func (s *Scheduler) GetTypes() (string, *dbus.Error) {
 	types, err := s.getTypes()
 	if err != nil {
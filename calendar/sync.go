@@ -0,0 +1,627 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+// 日程与远端日历的双向同步
+
+var syncConfigFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/calendar/sync.json")
+
+const (
+	defaultSyncInterval = 15 * time.Minute
+
+	// 冲突时保留较新的修改
+	conflictPolicyNewest = "newest"
+	// 冲突时保留远端的修改
+	conflictPolicyServer = "server"
+	// 冲突时保留本地的修改
+	conflictPolicyLocal = "local"
+)
+
+type SyncConfig struct {
+	Interval int // 同步间隔，单位为分钟
+	Accounts []*SyncAccount
+}
+
+type SyncAccount struct {
+	Name     string
+	Provider string // 同步后端，比如 caldav
+	URL      string
+	Username string
+	Password string
+
+	// 同步到该账户的日程类型，一个类型只能属于一个账户。
+	// 远端新建的日程如果没有匹配的类型，使用第一个类型。
+	Types []uint
+
+	ConflictPolicy string
+}
+
+// SyncRecord 记录一个已同步的日程在远端的位置和版本
+type SyncRecord struct {
+	ID             uint   `gorm:"primary_key"`
+	Account        string `gorm:"index"`
+	JobID          uint
+	UID            string
+	Href           string
+	ETag           string
+	LocalUpdatedAt time.Time // 上次同步时 job 的 UpdatedAt
+}
+
+type SyncAccountState struct {
+	Account  string `gorm:"primary_key"`
+	CTag     string
+	LastSync time.Time
+}
+
+type syncProvider interface {
+	// 返回远端集合的变化标记，为空表示不支持
+	getCTag() (string, error)
+	// 返回远端所有日程，key 为 href，value 为 etag
+	listObjects() (map[string]string, error)
+	hrefForUID(uid string) string
+	getObject(href string) (data []byte, etag string, err error)
+	putObject(href string, data []byte, etag string) (newETag string, err error)
+	deleteObject(href string, etag string) error
+}
+
+type syncProviderFactory func(account *SyncAccount) (syncProvider, error)
+
+var syncProviderFactories = make(map[string]syncProviderFactory)
+
+func registerSyncProvider(name string, factory syncProviderFactory) {
+	syncProviderFactories[name] = factory
+}
+
+func loadSyncConfig(filename string) (*SyncConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cfg SyncConfig
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (cfg *SyncConfig) validate() error {
+	accountNames := make(map[string]bool)
+	typeAccounts := make(map[uint]string)
+	for _, account := range cfg.Accounts {
+		if account.Name == "" {
+			return errors.New("account name is empty")
+		}
+		if accountNames[account.Name] {
+			return fmt.Errorf("duplicate account %q", account.Name)
+		}
+		accountNames[account.Name] = true
+
+		if _, ok := syncProviderFactories[account.Provider]; !ok {
+			return fmt.Errorf("account %q: unknown provider %q", account.Name, account.Provider)
+		}
+		if len(account.Types) == 0 {
+			return fmt.Errorf("account %q: no job types", account.Name)
+		}
+		for _, t := range account.Types {
+			if other, ok := typeAccounts[t]; ok {
+				return fmt.Errorf("job type %d belongs to both account %q and %q", t, other, account.Name)
+			}
+			typeAccounts[t] = account.Name
+		}
+
+		switch account.ConflictPolicy {
+		case "":
+			account.ConflictPolicy = conflictPolicyNewest
+		case conflictPolicyNewest, conflictPolicyServer, conflictPolicyLocal:
+		default:
+			return fmt.Errorf("account %q: invalid conflict policy %q", account.Name, account.ConflictPolicy)
+		}
+	}
+	return nil
+}
+
+func (cfg *SyncConfig) getInterval() time.Duration {
+	if cfg.Interval <= 0 {
+		return defaultSyncInterval
+	}
+	return time.Duration(cfg.Interval) * time.Minute
+}
+
+type accountSyncer struct {
+	db       *gorm.DB
+	account  *SyncAccount
+	provider syncProvider
+
+	typeNames map[uint]string
+	// 重复日程 id 到其单独修改过的各次
+	overrides map[uint][]*Job
+	// 本次同步是否修改了远端
+	remoteModified bool
+}
+
+func newAccountSyncer(db *gorm.DB, account *SyncAccount) (*accountSyncer, error) {
+	factory := syncProviderFactories[account.Provider]
+	if factory == nil {
+		return nil, fmt.Errorf("unknown provider %q", account.Provider)
+	}
+	provider, err := factory(account)
+	if err != nil {
+		return nil, err
+	}
+	return &accountSyncer{
+		db:       db,
+		account:  account,
+		provider: provider,
+	}, nil
+}
+
+// sync 同步一次，返回本地被修改的 job 的 id
+func (as *accountSyncer) sync() ([]uint, error) {
+	name := as.account.Name
+	var state SyncAccountState
+	err := as.db.FirstOrInit(&state, SyncAccountState{Account: name}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ctag, err := as.provider.getCTag()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*SyncRecord
+	err = as.db.Where("account = ?", name).Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	err = as.db.Where("type IN (?)", as.account.Types).Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+	jobMap := make(map[uint]*Job, len(jobs))
+	for _, job := range jobs {
+		if job.ParentID == 0 {
+			jobMap[job.ID] = job
+		}
+	}
+
+	// 单独修改过的各次跟随重复日程一起同步
+	var overrides []*Job
+	err = as.db.Where("parent_id > 0").Find(&overrides).Error
+	if err != nil {
+		return nil, err
+	}
+	as.overrides = make(map[uint][]*Job)
+	for _, override := range overrides {
+		as.overrides[override.ParentID] = append(as.overrides[override.ParentID], override)
+	}
+
+	var types []JobType
+	err = as.db.Find(&types).Error
+	if err != nil {
+		return nil, err
+	}
+	as.typeNames = make(map[uint]string, len(types))
+	for _, t := range types {
+		as.typeNames[t.ID] = t.Name
+	}
+	as.remoteModified = false
+
+	// ctag 没有变化时，远端的日程都没有变化
+	var remote map[string]string
+	remoteListed := ctag == "" || ctag != state.CTag
+	if remoteListed {
+		remote, err = as.provider.listObjects()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []uint
+	recordedJobs := make(map[uint]bool)
+	recordedHrefs := make(map[string]bool)
+	for _, record := range records {
+		recordedHrefs[record.Href] = true
+		job := jobMap[record.JobID]
+		if job != nil {
+			recordedJobs[job.ID] = true
+		}
+
+		etag, exists := record.ETag, true
+		if remoteListed {
+			etag, exists = remote[record.Href]
+		}
+		localChanged := job == nil || as.localUpdatedAt(job).After(record.LocalUpdatedAt)
+		remoteChanged := !exists || etag != record.ETag
+
+		ids, err := as.syncRecord(record, job, localChanged, remoteChanged, exists)
+		if err != nil {
+			logger.Warningf("account %q: failed to sync %s: %v", name, record.Href, err)
+			continue
+		}
+		changed = append(changed, ids...)
+	}
+
+	// 远端新建的日程
+	for href := range remote {
+		if recordedHrefs[href] {
+			continue
+		}
+		record := &SyncRecord{
+			Account: name,
+			Href:    href,
+		}
+		ids, err := as.pull(record, nil)
+		if err != nil {
+			logger.Warningf("account %q: failed to pull %s: %v", name, href, err)
+			continue
+		}
+		changed = append(changed, ids...)
+	}
+
+	// 本地新建的日程
+	for _, job := range jobs {
+		if job.ParentID != 0 || recordedJobs[job.ID] {
+			continue
+		}
+		record := &SyncRecord{
+			Account: name,
+			JobID:   job.ID,
+			UID:     jobUID(job.ID),
+		}
+		err = as.push(record, job)
+		if err != nil {
+			logger.Warningf("account %q: failed to push job %d: %v", name, job.ID, err)
+		}
+	}
+
+	// 修改过远端后，ctag 已经变化，不能确定期间有没有别的客户端修改，下次需要重新列出远端日程。
+	if as.remoteModified {
+		ctag = ""
+	}
+	state.CTag = ctag
+	state.LastSync = time.Now()
+	err = as.db.Save(&state).Error
+	return changed, err
+}
+
+func (as *accountSyncer) syncRecord(record *SyncRecord, job *Job,
+	localChanged, remoteChanged, remoteExists bool) ([]uint, error) {
+
+	policy := as.account.ConflictPolicy
+	switch {
+	case !localChanged && !remoteChanged:
+		return nil, nil
+
+	case !remoteExists:
+		if job != nil && localChanged && policy != conflictPolicyServer {
+			// 远端删除了本地修改过的日程，重新上传
+			record.Href = ""
+			record.ETag = ""
+			return nil, as.push(record, job)
+		}
+		return as.dropLocal(record, job)
+
+	case job == nil:
+		if remoteChanged && policy != conflictPolicyLocal {
+			// 本地删除了远端修改过的日程，重新下载
+			return as.pull(record, nil)
+		}
+		return nil, as.dropRemote(record)
+
+	case localChanged && remoteChanged:
+		return as.resolveConflict(record, job)
+
+	case localChanged:
+		err := as.push(record, job)
+		if err == errPreconditionFailed {
+			return as.resolveConflict(record, job)
+		}
+		return nil, err
+
+	default:
+		return as.pull(record, job)
+	}
+}
+
+func (as *accountSyncer) resolveConflict(record *SyncRecord, job *Job) ([]uint, error) {
+	data, etag, err := as.provider.getObject(record.Href)
+	if err != nil {
+		return nil, err
+	}
+	event, overrides, err := parseRemoteEvent(data)
+	if err != nil {
+		return nil, err
+	}
+
+	useLocal := false
+	switch as.account.ConflictPolicy {
+	case conflictPolicyLocal:
+		useLocal = true
+	case conflictPolicyNewest:
+		useLocal = as.localUpdatedAt(job).After(event.lastModified)
+	}
+	logger.Debugf("account %q: conflict on job %d, use local: %v", as.account.Name, job.ID, useLocal)
+
+	if useLocal {
+		record.ETag = etag
+		return nil, as.push(record, job)
+	}
+	return as.applyRemote(record, job, event, overrides, etag)
+}
+
+// localUpdatedAt 返回重复日程及其单独修改过的各次中最后的修改时间
+func (as *accountSyncer) localUpdatedAt(job *Job) time.Time {
+	updatedAt := job.UpdatedAt
+	for _, override := range as.overrides[job.ID] {
+		if override.UpdatedAt.After(updatedAt) {
+			updatedAt = override.UpdatedAt
+		}
+	}
+	return updatedAt
+}
+
+// push 把本地的 job 上传到远端
+func (as *accountSyncer) push(record *SyncRecord, job *Job) error {
+	if record.UID == "" {
+		record.UID = jobUID(job.ID)
+	}
+	events := []*icsEvent{{
+		uid:      record.UID,
+		typeName: as.typeNames[uint(job.Type)],
+		job:      job,
+	}}
+	for _, override := range as.overrides[job.ID] {
+		events = append(events, &icsEvent{
+			uid:      record.UID,
+			typeName: as.typeNames[uint(override.Type)],
+			job:      override,
+		})
+	}
+	var buf bytes.Buffer
+	err := writeICS(&buf, events)
+	if err != nil {
+		return err
+	}
+
+	if record.Href == "" {
+		record.Href = as.provider.hrefForUID(record.UID)
+	}
+	etag, err := as.provider.putObject(record.Href, buf.Bytes(), record.ETag)
+	if err != nil {
+		return err
+	}
+	as.remoteModified = true
+
+	record.JobID = job.ID
+	record.ETag = etag
+	record.LocalUpdatedAt = as.localUpdatedAt(job)
+	return as.db.Save(record).Error
+}
+
+// pull 下载远端的日程，job 为空时新建
+func (as *accountSyncer) pull(record *SyncRecord, job *Job) ([]uint, error) {
+	data, etag, err := as.provider.getObject(record.Href)
+	if err != nil {
+		return nil, err
+	}
+	event, overrides, err := parseRemoteEvent(data)
+	if err != nil {
+		return nil, err
+	}
+	return as.applyRemote(record, job, event, overrides, etag)
+}
+
+func (as *accountSyncer) applyRemote(record *SyncRecord, job *Job, event *icsEvent,
+	overrides []*icsEvent, etag string) ([]uint, error) {
+
+	newJob := event.job
+	err := newJob.validate()
+	if err != nil {
+		return nil, err
+	}
+	newJob.Type = int(as.getTypeID(event.typeName))
+
+	var ids []uint
+	err = withTx(as.db, func(tx *gorm.DB) error {
+		if job != nil {
+			newJob.ID = job.ID
+			newJob.CreatedAt = job.CreatedAt
+			err := tx.Save(newJob).Error
+			if err != nil {
+				return err
+			}
+		} else {
+			err := tx.Create(newJob).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		ids = append(ids, newJob.ID)
+		updatedAt := newJob.UpdatedAt
+
+		// 单独修改过的各次以远端为准
+		err := tx.Unscoped().Where("parent_id = ?", newJob.ID).Delete(Job{}).Error
+		if err != nil {
+			return err
+		}
+		for _, override := range overrides {
+			overrideJob := override.job
+			err := overrideJob.validate()
+			if err != nil {
+				logger.Warningf("account %q: skip invalid override of %s: %v",
+					as.account.Name, record.Href, err)
+				continue
+			}
+			overrideJob.Type = newJob.Type
+			overrideJob.ParentID = newJob.ID
+			err = tx.Create(overrideJob).Error
+			if err != nil {
+				return err
+			}
+			ids = append(ids, overrideJob.ID)
+			if overrideJob.UpdatedAt.After(updatedAt) {
+				updatedAt = overrideJob.UpdatedAt
+			}
+		}
+
+		if event.uid != "" {
+			record.UID = event.uid
+		}
+		record.JobID = newJob.ID
+		record.ETag = etag
+		record.LocalUpdatedAt = updatedAt
+		return tx.Save(record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (as *accountSyncer) getTypeID(name string) uint {
+	for _, id := range as.account.Types {
+		if name != "" && as.typeNames[id] == name {
+			return id
+		}
+	}
+	return as.account.Types[0]
+}
+
+func (as *accountSyncer) dropLocal(record *SyncRecord, job *Job) ([]uint, error) {
+	var ids []uint
+	err := withTx(as.db, func(tx *gorm.DB) error {
+		if job != nil {
+			err := tx.Unscoped().Where("parent_id = ?", job.ID).Delete(Job{}).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Unscoped().Delete(job).Error
+			if err != nil {
+				return err
+			}
+			ids = append(ids, job.ID)
+		}
+		return tx.Delete(record).Error
+	})
+	return ids, err
+}
+
+func (as *accountSyncer) dropRemote(record *SyncRecord) error {
+	err := as.provider.deleteObject(record.Href, record.ETag)
+	if err != nil {
+		return err
+	}
+	as.remoteModified = true
+	return as.db.Delete(record).Error
+}
+
+// parseRemoteEvent 解析远端的一个日程，返回日程本身和其中单独修改过的各次。
+func parseRemoteEvent(data []byte) (*icsEvent, []*icsEvent, error) {
+	events, err := parseICS(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	resolveICSOverrides(events)
+
+	var master *icsEvent
+	for _, event := range events {
+		if event.parent == nil {
+			master = event
+			break
+		}
+	}
+	if master == nil {
+		return nil, nil, errors.New("no VEVENT")
+	}
+
+	var overrides []*icsEvent
+	for _, event := range events {
+		if event.parent == master {
+			overrides = append(overrides, event)
+		}
+	}
+	return master, overrides, nil
+}
+
+func (s *Scheduler) initSync(cfg *SyncConfig) {
+	for _, account := range cfg.Accounts {
+		as, err := newAccountSyncer(s.db, account)
+		if err != nil {
+			logger.Warningf("failed to init sync account %q: %v", account.Name, err)
+			continue
+		}
+		s.syncers = append(s.syncers, as)
+	}
+}
+
+func (s *Scheduler) startSyncLoop(interval time.Duration) {
+	if len(s.syncers) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		s.syncAll()
+		for {
+			select {
+			case <-ticker.C:
+				s.syncAll()
+			case <-s.syncChan:
+				s.syncAll()
+			case <-s.quitChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) syncAll() {
+	var changed []uint
+	for _, as := range s.syncers {
+		ids, err := as.sync()
+		if err != nil {
+			logger.Warningf("failed to sync account %q: %v", as.account.Name, err)
+		}
+		changed = append(changed, ids...)
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	select {
+	case s.changeChan <- changed:
+	case <-s.quitChan:
+		return
+	}
+	s.emitJobsUpdated(changed...)
+}
+
+func (s *Scheduler) requestSync() {
+	if len(s.syncers) == 0 {
+		return
+	}
+	select {
+	case s.syncChan <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,63 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+var configFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/clipboard.json")
+
+// SyncMode 的各个位
+const (
+	SyncModeNone               uint32 = 0
+	SyncModePrimaryToClipboard uint32 = 1 << 0
+	SyncModeClipboardToPrimary uint32 = 1 << 1
+	SyncModeBoth                      = SyncModePrimaryToClipboard | SyncModeClipboardToPrimary
+)
+
+type config struct {
+	// 是否在 PRIMARY 的所有者退出后保留其内容
+	ManagePrimary bool
+	SyncMode      uint32
+}
+
+func checkSyncMode(mode uint32) error {
+	if mode&^SyncModeBoth != 0 {
+		return fmt.Errorf("invalid sync mode %d", mode)
+	}
+	return nil
+}
+
+func loadConfig(filename string) (*config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = checkSyncMode(cfg.SyncMode)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (cfg *config) save(filename string) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
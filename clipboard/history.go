@@ -0,0 +1,399 @@
+package clipboard
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	historyMaxItems      = 50
+	historyTextMaxRunes  = 256
+	historyIndexFileName = "index.json"
+
+	targetPasswordManagerHint = "x-kde-passwordManagerHint"
+)
+
+// 历史中每个 target 的数据大小上限，超过的 target 不保存到历史中。
+const (
+	defaultTargetMaxSize = 1 << 20
+	imageTargetMaxSize   = 10 << 20
+)
+
+var targetMaxSizes = map[string]int{
+	"image/png":  imageTargetMaxSize,
+	"image/jpeg": imageTargetMaxSize,
+	"image/bmp":  imageTargetMaxSize,
+}
+
+// 按优先级排列的文本和图片 target
+var historyTextTargets = []string{"UTF8_STRING", "text/plain;charset=utf-8",
+	"text/plain", "STRING", "TEXT", "text/uri-list"}
+var historyImageTargets = []string{"image/png", "image/jpeg", "image/bmp"}
+
+var errHistoryItemNotFound = errors.New("history item not found")
+
+func getTargetMaxSize(name string) int {
+	if size, ok := targetMaxSizes[name]; ok {
+		return size
+	}
+	return defaultTargetMaxSize
+}
+
+// historyTarget 保存 target 和类型的名称，atom 的值在不同的 X 会话中不同。
+type historyTarget struct {
+	Name   string
+	Type   string
+	Format uint8
+	Size   int
+}
+
+type historyTargetData struct {
+	historyTarget
+	Data []byte
+}
+
+type historyItem struct {
+	Id      uint64
+	Time    int64
+	Text    string // 文本的开头部分
+	Image   string // 图片的 target，比如 image/png
+	Hash    string
+	Targets []*historyTarget
+}
+
+// HistoryItemInfo 是历史项在 D-Bus 接口中的 JSON 表示
+type HistoryItemInfo struct {
+	Id        uint64
+	Time      int64
+	Text      string
+	Image     string
+	ImageFile string `json:",omitempty"`
+	Targets   []string
+}
+
+type history struct {
+	mu     sync.Mutex
+	dir    string
+	items  []*historyItem // 新的在前
+	nextId uint64
+}
+
+func newHistory(dir string) *history {
+	return &history{
+		dir:    dir,
+		nextId: 1,
+	}
+}
+
+func (h *history) indexFile() string {
+	return filepath.Join(h.dir, historyIndexFileName)
+}
+
+func (h *history) itemDir(id uint64) string {
+	return filepath.Join(h.dir, strconv.FormatUint(id, 10))
+}
+
+func (h *history) targetFile(id uint64, idx int) string {
+	return filepath.Join(h.itemDir(id), strconv.Itoa(idx))
+}
+
+func (h *history) load() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := ioutil.ReadFile(h.indexFile())
+	if err != nil {
+		return err
+	}
+	var items []*historyItem
+	err = json.Unmarshal(data, &items)
+	if err != nil {
+		return err
+	}
+	h.items = items
+	for _, item := range items {
+		if item.Id >= h.nextId {
+			h.nextId = item.Id + 1
+		}
+	}
+	return nil
+}
+
+func (h *history) saveIndex() error {
+	data, err := json.Marshal(h.items)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(h.dir, 0700)
+	if err != nil {
+		return err
+	}
+	tmpFile := h.indexFile() + ".tmp"
+	err = ioutil.WriteFile(tmpFile, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, h.indexFile())
+}
+
+// add 把一次剪贴板内容加入历史，内容相同的旧历史项会被移到最前面，返回是否修改了历史。
+func (h *history) add(targets []*historyTargetData, t int64) (bool, error) {
+	targets = filterHistoryTargets(targets)
+	text, image := getHistoryTextAndImage(targets)
+	if text == nil && image == nil {
+		return false, nil
+	}
+	hash := getHistoryHash(text, image)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for idx, item := range h.items {
+		if item.Hash == hash {
+			if idx == 0 {
+				return false, nil
+			}
+			item.Time = t
+			h.moveToFront(idx)
+			return true, h.saveIndex()
+		}
+	}
+
+	item := &historyItem{
+		Id:   h.nextId,
+		Time: t,
+		Hash: hash,
+	}
+	if text != nil {
+		item.Text = truncateRunes(string(text.Data), historyTextMaxRunes)
+	}
+	if image != nil {
+		item.Image = image.Name
+	}
+
+	err := os.MkdirAll(h.itemDir(item.Id), 0700)
+	if err != nil {
+		return false, err
+	}
+	for idx, td := range targets {
+		err := ioutil.WriteFile(h.targetFile(item.Id, idx), td.Data, 0600)
+		if err != nil {
+			os.RemoveAll(h.itemDir(item.Id))
+			return false, err
+		}
+		target := td.historyTarget
+		target.Size = len(td.Data)
+		item.Targets = append(item.Targets, &target)
+	}
+	h.nextId++
+
+	h.items = append([]*historyItem{item}, h.items...)
+	for len(h.items) > historyMaxItems {
+		last := h.items[len(h.items)-1]
+		h.items = h.items[:len(h.items)-1]
+		err = os.RemoveAll(h.itemDir(last.Id))
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+	return true, h.saveIndex()
+}
+
+func (h *history) moveToFront(idx int) {
+	item := h.items[idx]
+	copy(h.items[1:idx+1], h.items[:idx])
+	h.items[0] = item
+}
+
+func (h *history) getIndex(id uint64) int {
+	for idx, item := range h.items {
+		if item.Id == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// restore 读取历史项的数据，并把它移到最前面。
+func (h *history) restore(id uint64, t int64) ([]*historyTargetData, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	idx := h.getIndex(id)
+	if idx < 0 {
+		return nil, errHistoryItemNotFound
+	}
+	item := h.items[idx]
+	result := make([]*historyTargetData, 0, len(item.Targets))
+	for i, target := range item.Targets {
+		data, err := ioutil.ReadFile(h.targetFile(id, i))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &historyTargetData{
+			historyTarget: *target,
+			Data:          data,
+		})
+	}
+
+	item.Time = t
+	h.moveToFront(idx)
+	return result, h.saveIndex()
+}
+
+func (h *history) list() []*HistoryItemInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := make([]*HistoryItemInfo, len(h.items))
+	for idx, item := range h.items {
+		result[idx] = h.getItemInfo(item)
+	}
+	return result
+}
+
+// getInfo 返回历史项的完整信息，Text 为完整的文本
+func (h *history) getInfo(id uint64) (*HistoryItemInfo, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	idx := h.getIndex(id)
+	if idx < 0 {
+		return nil, errHistoryItemNotFound
+	}
+	item := h.items[idx]
+	info := h.getItemInfo(item)
+	for _, name := range historyTextTargets {
+		i := item.getTargetIndex(name)
+		if i < 0 {
+			continue
+		}
+		data, err := ioutil.ReadFile(h.targetFile(id, i))
+		if err != nil {
+			return nil, err
+		}
+		info.Text = string(data)
+		break
+	}
+	return info, nil
+}
+
+func (h *history) getItemInfo(item *historyItem) *HistoryItemInfo {
+	info := &HistoryItemInfo{
+		Id:    item.Id,
+		Time:  item.Time,
+		Text:  item.Text,
+		Image: item.Image,
+	}
+	for idx, target := range item.Targets {
+		info.Targets = append(info.Targets, target.Name)
+		if target.Name == item.Image {
+			info.ImageFile = h.targetFile(item.Id, idx)
+		}
+	}
+	return info
+}
+
+func (h *history) clear() (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.items) == 0 {
+		return false, nil
+	}
+	for _, item := range h.items {
+		err := os.RemoveAll(h.itemDir(item.Id))
+		if err != nil {
+			return false, err
+		}
+	}
+	h.items = nil
+	return true, h.saveIndex()
+}
+
+func (item *historyItem) getTargetIndex(name string) int {
+	for idx, target := range item.Targets {
+		if target.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// filterHistoryTargets 去掉超过大小上限的 target
+func filterHistoryTargets(targets []*historyTargetData) []*historyTargetData {
+	result := make([]*historyTargetData, 0, len(targets))
+	for _, td := range targets {
+		if len(td.Data) > getTargetMaxSize(td.Name) {
+			logger.Debugf("target %s is too large to save in history, size: %d",
+				td.Name, len(td.Data))
+			continue
+		}
+		result = append(result, td)
+	}
+	return result
+}
+
+func findHistoryTarget(targets []*historyTargetData, name string) *historyTargetData {
+	for _, td := range targets {
+		if td.Name == name {
+			return td
+		}
+	}
+	return nil
+}
+
+func getHistoryTextAndImage(targets []*historyTargetData) (text, image *historyTargetData) {
+	for _, name := range historyTextTargets {
+		td := findHistoryTarget(targets, name)
+		if td != nil && len(td.Data) > 0 && utf8.Valid(td.Data) {
+			text = td
+			break
+		}
+	}
+	for _, name := range historyImageTargets {
+		td := findHistoryTarget(targets, name)
+		if td != nil && len(td.Data) > 0 {
+			image = td
+			break
+		}
+	}
+	return
+}
+
+// getHistoryHash 根据文本和图片计算历史项的摘要，同样的内容在不同程序中的 target 可能不同。
+func getHistoryHash(text, image *historyTargetData) string {
+	h := md5.New()
+	if text != nil {
+		h.Write(text.Data)
+	}
+	if image != nil {
+		h.Write(image.Data)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// isPasswordContent 判断剪贴板内容是不是密码管理器设置的密码，这样的内容不保存到历史中。
+func isPasswordContent(targets []*historyTargetData) bool {
+	td := findHistoryTarget(targets, targetPasswordManagerHint)
+	return td != nil && string(td.Data) == "secret"
+}
+
+func truncateRunes(str string, n int) string {
+	count := 0
+	for idx := range str {
+		if count == n {
+			return str[:idx]
+		}
+		count++
+	}
+	return str
+}
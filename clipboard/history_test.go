@@ -0,0 +1,130 @@
+package clipboard
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTextTargets(text string) []*historyTargetData {
+	return []*historyTargetData{
+		{
+			historyTarget: historyTarget{Name: "UTF8_STRING", Type: "UTF8_STRING", Format: 8},
+			Data:          []byte(text),
+		},
+		{
+			historyTarget: historyTarget{Name: "text/plain", Type: "text/plain", Format: 8},
+			Data:          []byte(text),
+		},
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard-history")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	h := newHistory(dir)
+	changed, err := h.add(newTestTextTargets("hello"), 1)
+	require.Nil(t, err)
+	assert.True(t, changed)
+	changed, err = h.add(newTestTextTargets("world"), 2)
+	require.Nil(t, err)
+	assert.True(t, changed)
+
+	// 和最新的一项相同
+	changed, err = h.add(newTestTextTargets("world"), 3)
+	require.Nil(t, err)
+	assert.False(t, changed)
+
+	// 和旧的一项相同，移到最前面
+	changed, err = h.add(newTestTextTargets("hello"), 4)
+	require.Nil(t, err)
+	assert.True(t, changed)
+	items := h.list()
+	require.Len(t, items, 2)
+	assert.Equal(t, "hello", items[0].Text)
+	assert.EqualValues(t, 4, items[0].Time)
+	assert.Equal(t, []string{"UTF8_STRING", "text/plain"}, items[0].Targets)
+
+	// 重新加载
+	h = newHistory(dir)
+	require.Nil(t, h.load())
+	items = h.list()
+	require.Len(t, items, 2)
+	worldId := items[1].Id
+
+	targets, err := h.restore(worldId, 5)
+	require.Nil(t, err)
+	require.Len(t, targets, 2)
+	assert.Equal(t, "text/plain", targets[1].Name)
+	assert.Equal(t, []byte("world"), targets[1].Data)
+	assert.Equal(t, 5, targets[1].Size)
+	assert.Equal(t, worldId, h.list()[0].Id)
+
+	_, err = h.restore(100, 6)
+	assert.Equal(t, errHistoryItemNotFound, err)
+
+	changed, err = h.clear()
+	require.Nil(t, err)
+	assert.True(t, changed)
+	assert.Len(t, h.list(), 0)
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestHistoryLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard-history")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	h := newHistory(dir)
+	for i := 0; i < historyMaxItems+5; i++ {
+		_, err = h.add(newTestTextTargets(strings.Repeat("a", i+1)), int64(i))
+		require.Nil(t, err)
+	}
+	assert.Len(t, h.list(), historyMaxItems)
+
+	// 超过上限的 target 不保存，图片的上限更大
+	largeText := strings.Repeat("b", defaultTargetMaxSize+1)
+	targets := append(newTestTextTargets(largeText), &historyTargetData{
+		historyTarget: historyTarget{Name: "image/png", Type: "image/png", Format: 8},
+		Data:          []byte(largeText),
+	})
+	changed, err := h.add(targets, 100)
+	require.Nil(t, err)
+	assert.True(t, changed)
+	info, err := h.getInfo(h.list()[0].Id)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"image/png"}, info.Targets)
+	assert.Equal(t, "image/png", info.Image)
+	assert.NotEmpty(t, info.ImageFile)
+	assert.Equal(t, "", info.Text)
+
+	// 只有文本且超过上限时不保存
+	changed, err = h.add(newTestTextTargets(largeText), 101)
+	require.Nil(t, err)
+	assert.False(t, changed)
+}
+
+func TestIsPasswordContent(t *testing.T) {
+	targets := newTestTextTargets("password")
+	assert.False(t, isPasswordContent(targets))
+
+	targets = append(targets, &historyTargetData{
+		historyTarget: historyTarget{Name: targetPasswordManagerHint, Type: "text/plain", Format: 8},
+		Data:          []byte("secret"),
+	})
+	assert.True(t, isPasswordContent(targets))
+}
+
+func TestTruncateRunes(t *testing.T) {
+	assert.Equal(t, "ab", truncateRunes("abc", 2))
+	assert.Equal(t, "abc", truncateRunes("abc", 3))
+	assert.Equal(t, "你好", truncateRunes("你好世界", 2))
+}
@@ -10,12 +10,14 @@ import (
 
 	"github.com/linuxdeepin/go-x11-client"
 	"github.com/linuxdeepin/go-x11-client/ext/xfixes"
+	"pkg.deepin.io/lib/dbusutil"
 	"pkg.deepin.io/lib/log"
 )
 
 var (
 	atomClipboardManager     x.Atom
 	atomClipboard            x.Atom
+	atomPrimary              x.Atom
 	atomSaveTargets          x.Atom
 	atomTargets              x.Atom
 	atomMultiple             x.Atom
@@ -35,6 +37,7 @@ var (
 func initAtoms(xConn *x.Conn) {
 	atomClipboardManager, _ = xConn.GetAtom("CLIPBOARD_MANAGER")
 	atomClipboard, _ = xConn.GetAtom("CLIPBOARD")
+	atomPrimary, _ = xConn.GetAtom("PRIMARY")
 	atomSaveTargets, _ = xConn.GetAtom("SAVE_TARGETS")
 	atomTargets, _ = xConn.GetAtom("TARGETS")
 	atomMultiple, _ = xConn.GetAtom("MULTIPLE")
@@ -62,26 +65,52 @@ func (td *TargetData) needINCR() bool {
 	return len(td.Data) > selectionMaxSize
 }
 
+//go:generate dbusutil-gen -type Manager manager.go
 type Manager struct {
 	xc        XClient
+	service   *dbusutil.Service
 	window    x.Window
 	dataWin   x.Window
 	ec        *eventCaptor
 	timestamp x.Timestamp
 
-	contentMu sync.Mutex
-	content   []*TargetData
+	contentMu      sync.Mutex
+	content        []*TargetData
+	primaryContent []*TargetData
+	// 同一时间只转换一次选区，转换时使用的是 m.window 上同样的属性
+	convertMu sync.Mutex
+
+	history *history
+
+	// 选中文本时 PRIMARY 的所有者会频繁变化，等稳定后再获取内容
+	primaryTimerMu sync.Mutex
+	primaryTimer   *time.Timer
+
+	PropsMu       sync.RWMutex
+	ManagePrimary bool
+	SyncMode      uint32
 
 	methods *struct {
-		RemoveTarget func() `in:"target"`
+		RemoveTarget       func() `in:"target"`
+		ListHistory        func() `out:"items"`
+		GetHistoryItem     func() `in:"id" out:"item"`
+		RestoreHistoryItem func() `in:"id"`
+		ClearHistory       func()
+		SetManagePrimary   func() `in:"enabled"`
+		SetSyncMode        func() `in:"mode"`
+	}
+
+	signals *struct {
+		HistoryChanged struct{}
 	}
 }
 
 func (m *Manager) getTargetData(target x.Atom) *TargetData {
-	m.contentMu.Lock()
-	defer m.contentMu.Unlock()
+	return m.getSelectionTargetData(atomClipboard, target)
+}
 
-	for _, td := range m.content {
+func (m *Manager) getSelectionTargetData(selection, target x.Atom) *TargetData {
+	for _, td := range m.getContent(selection) {
 		if td.Target == target {
 			return td
 		}
@@ -89,6 +118,27 @@ func (m *Manager) getTargetData(target x.Atom) *TargetData {
 	return nil
 }
 
+func (m *Manager) getContent(selection x.Atom) []*TargetData {
+	m.contentMu.Lock()
+	defer m.contentMu.Unlock()
+
+	if selection == atomClipboard {
+		return m.content
+	}
+	return m.primaryContent
+}
+
+func (m *Manager) setContent(selection x.Atom, content []*TargetData) {
+	m.contentMu.Lock()
+	defer m.contentMu.Unlock()
+
+	if selection == atomClipboard {
+		m.content = content
+	} else {
+		m.primaryContent = content
+	}
+}
+
 func (m *Manager) addTargetData(targetData *TargetData) {
 	m.contentMu.Lock()
 	defer m.contentMu.Unlock()
@@ -117,12 +167,14 @@ func (m *Manager) start() error {
 	}
 	logger.Debug("m.window:", m.window)
 
-	err = m.xc.SelectSelectionInputE(m.window, atomClipboard,
-		xfixes.SelectionEventMaskSetSelectionOwner|
-			xfixes.SelectionEventMaskSelectionClientClose|
-			xfixes.SelectionEventMaskSelectionWindowDestroy)
-	if err != nil {
-		logger.Warning(err)
+	for _, selection := range []x.Atom{atomClipboard, atomPrimary} {
+		err = m.xc.SelectSelectionInputE(m.window, selection,
+			xfixes.SelectionEventMaskSetSelectionOwner|
+				xfixes.SelectionEventMaskSelectionClientClose|
+				xfixes.SelectionEventMaskSelectionWindowDestroy)
+		if err != nil {
+			logger.Warning(err)
+		}
 	}
 
 	m.ec = newEventCaptor()
@@ -164,8 +216,8 @@ func (m *Manager) handleEvent(ev x.GenericEvent) {
 
 		if event.Selection == atomClipboardManager {
 			go m.convertClipboardManager(event)
-		} else if event.Selection == atomClipboard {
-			go m.convertClipboard(event)
+		} else if event.Selection == atomClipboard || event.Selection == atomPrimary {
+			go m.convertSelection(event)
 		}
 
 	case x.PropertyNotifyEventCode:
@@ -205,10 +257,17 @@ func (m *Manager) handleEvent(ev x.GenericEvent) {
 					logger.Debug("i have become the owner of CLIPBOARD")
 				} else {
 					logger.Debug("other app have become the owner of CLIPBOARD")
+					go m.handleClipboardOwnerChanged(event.Timestamp)
 				}
+			} else if event.Selection == atomPrimary && event.Owner != m.window {
+				m.handlePrimaryOwnerChanged()
 			}
 
 		case xfixes.SelectionEventSelectionWindowDestroy, xfixes.SelectionEventSelectionClientClose:
+			if event.Selection == atomPrimary {
+				m.handlePrimaryOwnerGone(event.Timestamp)
+				return
+			}
 			err := m.becomeClipboardOwner(event.Timestamp)
 			if err != nil {
 				logger.Warning(err)
@@ -239,13 +298,20 @@ func (m *Manager) becomeClipboardOwner(ts x.Timestamp) error {
 }
 
 func (m *Manager) getClipboardTargets(ts x.Timestamp) ([]x.Atom, error) {
+	return m.getSelectionTargets(atomClipboard, ts)
+}
+
+func (m *Manager) getSelectionTargets(selection x.Atom, ts x.Timestamp) ([]x.Atom, error) {
+	m.convertMu.Lock()
+	defer m.convertMu.Unlock()
+
 	selNotifyEvent, err := m.ec.captureSelectionNotifyEvent(func() error {
-		m.xc.ConvertSelection(m.window, atomClipboard,
+		m.xc.ConvertSelection(m.window, selection,
 			atomTargets, atomTargets, ts)
 		return m.xc.Flush()
 	}, func(event *x.SelectionNotifyEvent) bool {
 		return event.Target == atomTargets &&
-			event.Selection == atomClipboard &&
+			event.Selection == selection &&
 			event.Requestor == m.window
 	})
 	if err != nil {
@@ -253,7 +319,7 @@ func (m *Manager) getClipboardTargets(ts x.Timestamp) ([]x.Atom, error) {
 	}
 
 	if selNotifyEvent.Property == x.None {
-		return nil, errors.New("failed to convert clipboard targets")
+		return nil, errors.New("failed to convert selection targets")
 	}
 
 	propReply, err := m.getProperty(m.window, selNotifyEvent.Property, true)
@@ -315,6 +381,7 @@ func (m *Manager) convertClipboardManager(ev *x.SelectionRequestEvent) {
 		}
 
 		m.saveTargets(targets, ev.Time)
+		m.addHistory(m.getContent(atomClipboard))
 		// add special target
 		m.addTargetData(&TargetData{
 			Target: atomFromClipboardManager,
@@ -352,19 +419,17 @@ func (m *Manager) convertClipboardManager(ev *x.SelectionRequestEvent) {
 	}
 }
 
-// convert CLIPBOARD selection
-func (m *Manager) convertClipboard(ev *x.SelectionRequestEvent) {
+// convert CLIPBOARD or PRIMARY selection
+func (m *Manager) convertSelection(ev *x.SelectionRequestEvent) {
 	targetName, _ := m.xc.GetAtomName(ev.Target)
-	logger.Debugf("convert clipboard target %s %d", targetName, ev.Target)
+	logger.Debugf("convert selection %d target %s %d", ev.Selection, targetName, ev.Target)
 
 	if ev.Target == atomTargets {
 		w := x.NewWriter()
 		w.Write4b(uint32(atomTargets))
-		m.contentMu.Lock()
-		for _, targetData := range m.content {
+		for _, targetData := range m.getContent(ev.Selection) {
 			w.Write4b(uint32(targetData.Target))
 		}
-		m.contentMu.Unlock()
 
 		err := m.xc.ChangePropertyE(x.PropModeReplace, ev.Requestor,
 			ev.Property, x.AtomAtom, 32, w.Bytes())
@@ -374,7 +439,7 @@ func (m *Manager) convertClipboard(ev *x.SelectionRequestEvent) {
 		m.finishSelectionRequest(ev, err == nil)
 
 	} else {
-		targetData := m.getTargetData(ev.Target)
+		targetData := m.getSelectionTargetData(ev.Selection, ev.Target)
 		if targetData == nil {
 			m.finishSelectionRequest(ev, false)
 			return
@@ -479,10 +544,16 @@ func (m *Manager) finishSelectionRequest(ev *x.SelectionRequestEvent, success bo
 }
 
 func (m *Manager) saveTargets(targets []x.Atom, ts x.Timestamp) {
-	m.contentMu.Lock()
-	m.content = nil
-	m.contentMu.Unlock()
+	content := m.convertTargets(atomClipboard, targets, ts)
+	m.setContent(atomClipboard, content)
+}
+
+// convertTargets 从选区的所有者获取 targets 的数据
+func (m *Manager) convertTargets(selection x.Atom, targets []x.Atom, ts x.Timestamp) []*TargetData {
+	m.convertMu.Lock()
+	defer m.convertMu.Unlock()
 
+	var content []*TargetData
 	for _, target := range targets {
 		targetName, err := m.xc.GetAtomName(target)
 		if err != nil {
@@ -495,11 +566,14 @@ func (m *Manager) saveTargets(targets []x.Atom, ts x.Timestamp) {
 		}
 
 		logger.Debug("save target", target, targetName)
-		err = m.saveTarget(target, ts)
+		targetData, err := m.saveTarget(selection, target, ts)
 		if err != nil {
 			logger.Warning(err)
+			continue
 		}
+		content = append(content, targetData)
 	}
+	return content
 }
 
 func shouldIgnoreSaveTarget(target x.Atom, targetName string) bool {
@@ -521,46 +595,42 @@ func shouldIgnoreSaveTarget(target x.Atom, targetName string) bool {
 	return false
 }
 
-func (m *Manager) saveTarget(target x.Atom, ts x.Timestamp) error {
+func (m *Manager) saveTarget(selection, target x.Atom, ts x.Timestamp) (*TargetData, error) {
 	selNotifyEvent, err := m.ec.captureSelectionNotifyEvent(func() error {
-		m.xc.ConvertSelection(m.window, atomClipboard, target, target, ts)
+		m.xc.ConvertSelection(m.window, selection, target, target, ts)
 		return m.xc.Flush()
 	}, func(event *x.SelectionNotifyEvent) bool {
-		return event.Selection == atomClipboard &&
+		return event.Selection == selection &&
 			event.Requestor == m.window &&
 			event.Target == target
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if selNotifyEvent.Property == x.None {
-		return errors.New("failed to convert target")
+		return nil, errors.New("failed to convert target")
 	}
 
 	propReply, err := m.getProperty(m.window, selNotifyEvent.Property, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if propReply.Type == atomIncr {
-		err := m.recvTargetIncr(target, selNotifyEvent.Property)
-		if err != nil {
-			return err
-		}
-	} else {
-		err = m.xc.DeletePropertyE(m.window, selNotifyEvent.Property)
-		if err != nil {
-			return err
-		}
-		logger.Debug("data len:", len(propReply.Value))
-		m.addTargetData(&TargetData{
-			Target: target,
-			Type:   propReply.Type,
-			Format: propReply.Format,
-			Data:   propReply.Value,
-		})
+		return m.recvTargetIncr(target, selNotifyEvent.Property)
 	}
-	return nil
+
+	err = m.xc.DeletePropertyE(m.window, selNotifyEvent.Property)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("data len:", len(propReply.Value))
+	return &TargetData{
+		Target: target,
+		Type:   propReply.Type,
+		Format: propReply.Format,
+		Data:   propReply.Value,
+	}, nil
 }
 
 func (m *Manager) getProperty(win x.Window, propertyAtom x.Atom, delete bool) (*x.GetPropertyReply, error) {
@@ -581,7 +651,7 @@ func (m *Manager) getProperty(win x.Window, propertyAtom x.Atom, delete bool) (*
 	return propReply, nil
 }
 
-func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
+func (m *Manager) recvTargetIncr(target, prop x.Atom) (*TargetData, error) {
 	logger.Debug("start recvTargetIncr", target)
 	var data [][]byte
 	t0 := time.Now()
@@ -600,7 +670,7 @@ func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
 		})
 		if err != nil {
 			logger.Warning(err)
-			return err
+			return nil, err
 		}
 
 		propReply, err := m.xc.GetProperty(false, propNotifyEvent.Window, propNotifyEvent.Atom,
@@ -608,7 +678,7 @@ func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
 			0, 0)
 		if err != nil {
 			logger.Warning(err)
-			return err
+			return nil, err
 		}
 		propReply, err = m.xc.GetProperty(false, propNotifyEvent.Window, propNotifyEvent.Atom,
 			x.GetPropertyTypeAny, 0,
@@ -616,7 +686,7 @@ func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
 		)
 		if err != nil {
 			logger.Warning(err)
-			return err
+			return nil, err
 		}
 
 		if propReply.ValueLen == 0 {
@@ -626,16 +696,15 @@ func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
 			err = m.xc.DeletePropertyE(propNotifyEvent.Window, propNotifyEvent.Atom)
 			if err != nil {
 				logger.Warning(err)
-				return err
+				return nil, err
 			}
 
-			m.addTargetData(&TargetData{
+			return &TargetData{
 				Target: target,
 				Type:   propReply.Type,
 				Format: propReply.Format,
 				Data:   bytes.Join(data, nil),
-			})
-			return nil
+			}, nil
 		}
 		if logger.GetLogLevel() == log.LevelDebug {
 			logger.Debugf("recv data size: %d, md5sum: %s", len(propReply.Value), getBytesMd5sum(propReply.Value))
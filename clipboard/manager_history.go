@@ -0,0 +1,137 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"time"
+
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+func (m *Manager) addHistory(content []*TargetData) {
+	if m.history == nil {
+		return
+	}
+
+	targets := make([]*historyTargetData, 0, len(content))
+	for _, td := range content {
+		if td.Target == atomFromClipboardManager {
+			continue
+		}
+		targetName, err := m.xc.GetAtomName(td.Target)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		typeName, err := m.xc.GetAtomName(td.Type)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		targets = append(targets, &historyTargetData{
+			historyTarget: historyTarget{
+				Name:   targetName,
+				Type:   typeName,
+				Format: td.Format,
+			},
+			Data: td.Data,
+		})
+	}
+
+	if isPasswordContent(targets) {
+		logger.Debug("ignore password content")
+		return
+	}
+
+	changed, err := m.history.add(targets, time.Now().Unix())
+	if err != nil {
+		logger.Warning(err)
+	}
+	if changed {
+		m.emitHistoryChanged()
+	}
+}
+
+func (m *Manager) emitHistoryChanged() {
+	if m.service == nil {
+		return
+	}
+	err := m.service.Emit(m, "HistoryChanged")
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+func (m *Manager) restoreHistoryItem(id uint64) error {
+	targets, err := m.history.restore(id, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	content := make([]*TargetData, 0, len(targets))
+	for _, td := range targets {
+		target, err := m.xc.GetAtom(td.Name)
+		if err != nil {
+			return err
+		}
+		type0, err := m.xc.GetAtom(td.Type)
+		if err != nil {
+			return err
+		}
+		content = append(content, &TargetData{
+			Target: target,
+			Type:   type0,
+			Format: td.Format,
+			Data:   td.Data,
+		})
+	}
+	m.setContent(atomClipboard, content)
+	m.emitHistoryChanged()
+
+	ts, err := m.getTimestamp()
+	if err != nil {
+		return err
+	}
+	err = m.becomeClipboardOwner(ts)
+	if err != nil {
+		return err
+	}
+	m.syncClipboardToPrimary(content, ts)
+	return nil
+}
+
+func (m *Manager) ListHistory() (string, *dbus.Error) {
+	data, err := json.Marshal(m.history.list())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) GetHistoryItem(id uint64) (string, *dbus.Error) {
+	info, err := m.history.getInfo(id)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) RestoreHistoryItem(id uint64) *dbus.Error {
+	err := m.restoreHistoryItem(id)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) ClearHistory() *dbus.Error {
+	changed, err := m.history.clear()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	if changed {
+		m.emitHistoryChanged()
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package clipboard
+
+import (
+	"time"
+
+	"github.com/linuxdeepin/go-x11-client"
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+const primarySaveDelay = 300 * time.Millisecond
+
+func (m *Manager) getSyncMode() uint32 {
+	m.PropsMu.RLock()
+	defer m.PropsMu.RUnlock()
+	return m.SyncMode
+}
+
+func (m *Manager) needSavePrimary() bool {
+	m.PropsMu.RLock()
+	defer m.PropsMu.RUnlock()
+	return m.ManagePrimary || m.SyncMode&SyncModePrimaryToClipboard != 0
+}
+
+// handleClipboardOwnerChanged 在其他程序成为 CLIPBOARD 的所有者后，把剪贴板内容保存到历史中，并按需同步到 PRIMARY。
+func (m *Manager) handleClipboardOwnerChanged(ts x.Timestamp) {
+	targets, err := m.getClipboardTargets(ts)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	content := m.convertTargets(atomClipboard, targets, ts)
+	m.addHistory(content)
+	m.syncClipboardToPrimary(content, ts)
+}
+
+func (m *Manager) syncClipboardToPrimary(content []*TargetData, ts x.Timestamp) {
+	if len(content) == 0 || m.getSyncMode()&SyncModeClipboardToPrimary == 0 {
+		return
+	}
+	m.setContent(atomPrimary, content)
+	err := setSelectionOwner(m.xc, m.window, atomPrimary, ts)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	logger.Debug("sync CLIPBOARD to PRIMARY")
+}
+
+func (m *Manager) handlePrimaryOwnerChanged() {
+	if !m.needSavePrimary() {
+		return
+	}
+
+	m.primaryTimerMu.Lock()
+	defer m.primaryTimerMu.Unlock()
+	if m.primaryTimer == nil {
+		m.primaryTimer = time.AfterFunc(primarySaveDelay, m.savePrimary)
+	} else {
+		m.primaryTimer.Reset(primarySaveDelay)
+	}
+}
+
+// savePrimary 获取并保存 PRIMARY 的内容，并按需同步到 CLIPBOARD。
+func (m *Manager) savePrimary() {
+	owner, err := m.xc.GetSelectionOwner(atomPrimary)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if owner == x.None || owner == m.window {
+		return
+	}
+
+	ts, err := m.getTimestamp()
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	targets, err := m.getSelectionTargets(atomPrimary, ts)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	content := m.convertTargets(atomPrimary, targets, ts)
+	if len(content) == 0 {
+		return
+	}
+	m.setContent(atomPrimary, content)
+
+	if m.getSyncMode()&SyncModePrimaryToClipboard != 0 {
+		m.setContent(atomClipboard, content)
+		err = m.becomeClipboardOwner(ts)
+		if err != nil {
+			logger.Warning(err)
+			return
+		}
+		logger.Debug("sync PRIMARY to CLIPBOARD")
+	}
+}
+
+// handlePrimaryOwnerGone 在 PRIMARY 的所有者退出后接管 PRIMARY，中键粘贴仍然可用。
+func (m *Manager) handlePrimaryOwnerGone(ts x.Timestamp) {
+	m.PropsMu.RLock()
+	managePrimary := m.ManagePrimary
+	m.PropsMu.RUnlock()
+	if !managePrimary || len(m.getContent(atomPrimary)) == 0 {
+		return
+	}
+
+	err := setSelectionOwner(m.xc, m.window, atomPrimary, ts)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	logger.Debug("set primary selection owner to me")
+}
+
+func (m *Manager) saveConfig() error {
+	m.PropsMu.RLock()
+	cfg := &config{
+		ManagePrimary: m.ManagePrimary,
+		SyncMode:      m.SyncMode,
+	}
+	m.PropsMu.RUnlock()
+	return cfg.save(configFile)
+}
+
+func (m *Manager) SetManagePrimary(enabled bool) *dbus.Error {
+	m.PropsMu.Lock()
+	changed := m.setPropManagePrimary(enabled)
+	m.PropsMu.Unlock()
+	if !changed {
+		return nil
+	}
+
+	if !m.needSavePrimary() {
+		m.setContent(atomPrimary, nil)
+	}
+	err := m.saveConfig()
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) SetSyncMode(mode uint32) *dbus.Error {
+	err := checkSyncMode(mode)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.PropsMu.Lock()
+	changed := m.setPropSyncMode(mode)
+	m.PropsMu.Unlock()
+	if !changed {
+		return nil
+	}
+
+	err = m.saveConfig()
+	return dbusutil.ToError(err)
+}
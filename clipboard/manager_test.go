@@ -24,6 +24,7 @@ func initAtomsForTest() {
 	atomTimestamp = base + 11
 	atomTimestampProp = base + 12
 	atomNull = base + 13
+	atomPrimary = base + 14
 }
 
 func TestManager_finishSelectionRequest(t *testing.T) {
@@ -215,3 +216,34 @@ func TestManagerAddGetTargetData(t *testing.T) {
 	assert.Equal(t, td1, m.getTargetData(1))
 	assert.Len(t, m.content, 1)
 }
+
+func TestManagerSelectionContent(t *testing.T) {
+	initAtomsForTest()
+	m := &Manager{}
+	td0 := &TargetData{
+		Target: 1,
+		Type:   x.AtomString,
+		Data:   []byte("clipboard"),
+	}
+	td1 := &TargetData{
+		Target: 1,
+		Type:   x.AtomString,
+		Data:   []byte("primary"),
+	}
+	m.setContent(atomClipboard, []*TargetData{td0})
+	m.setContent(atomPrimary, []*TargetData{td1})
+	assert.Equal(t, td0, m.getTargetData(1))
+	assert.Equal(t, td1, m.getSelectionTargetData(atomPrimary, 1))
+	assert.Nil(t, m.getSelectionTargetData(atomPrimary, 2))
+
+	m.setContent(atomPrimary, nil)
+	assert.Nil(t, m.getSelectionTargetData(atomPrimary, 1))
+	assert.Len(t, m.getContent(atomClipboard), 1)
+}
+
+func Test_checkSyncMode(t *testing.T) {
+	assert.Nil(t, checkSyncMode(SyncModeNone))
+	assert.Nil(t, checkSyncMode(SyncModePrimaryToClipboard))
+	assert.Nil(t, checkSyncMode(SyncModeBoth))
+	assert.NotNil(t, checkSyncMode(4))
+}
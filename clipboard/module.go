@@ -1,14 +1,20 @@
 package clipboard
 
 import (
+	"os"
+	"path/filepath"
+
 	"github.com/linuxdeepin/go-x11-client"
 	"github.com/linuxdeepin/go-x11-client/ext/xfixes"
 	"pkg.deepin.io/dde/daemon/loader"
 	"pkg.deepin.io/lib/log"
+	"pkg.deepin.io/lib/xdg/basedir"
 )
 
 const dbusServiceName = "com.deepin.daemon.ClipboardManager"
 
+var historyDir = filepath.Join(basedir.GetUserCacheDir(), "deepin/dde-daemon/clipboard-history")
+
 var logger *log.Logger
 
 func init() {
@@ -45,17 +51,33 @@ func (*Module) Start() error {
 		logger.Warning(err)
 	}
 
-	m := &Manager{}
+	service := loader.GetService()
+	m := &Manager{
+		service: service,
+		history: newHistory(historyDir),
+	}
 	m.xc = &xClient{
 		conn: xConn,
 	}
 
+	err = m.history.load()
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("failed to load clipboard history:", err)
+	}
+
+	cfg, err := loadConfig(configFile)
+	if err == nil {
+		m.ManagePrimary = cfg.ManagePrimary
+		m.SyncMode = cfg.SyncMode
+	} else if !os.IsNotExist(err) {
+		logger.Warning("failed to load config:", err)
+	}
+
 	err = m.start()
 	if err != nil {
 		return err
 	}
 
-	service := loader.GetService()
 	err = service.Export("/com/deepin/daemon/ClipboardManager", m)
 	if err != nil {
 		return err
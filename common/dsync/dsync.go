@@ -37,6 +37,7 @@ func NewConfig(name string, core Interface, sessionSigLoop *dbusutil.SignalLoop,
 		path:    path,
 		logger:  logger,
 	}
+	registerConfig(c)
 
 	sessionBus := sessionSigLoop.Conn()
 	c.dbusDaemon = ofdbus.NewDBus(sessionBus)
@@ -68,6 +69,7 @@ func (c *Config) Register() error {
 }
 
 func (c *Config) Destroy() {
+	unregisterConfig(c)
 	c.dbusDaemon.RemoveHandler(proxy.RemoveAllHandlers)
 }
 
@@ -75,13 +77,16 @@ func (*Config) GetInterfaceName() string {
 	return "com.deepin.sync.Config"
 }
 
-func (c *Config) Get() ([]byte, *dbus.Error) {
+func (c *Config) getData() ([]byte, error) {
 	v, err := c.core.Get()
 	if err != nil {
-		return nil, dbusutil.ToError(err)
+		return nil, err
 	}
+	return json.Marshal(v)
+}
 
-	data, err := json.Marshal(v)
+func (c *Config) Get() ([]byte, *dbus.Error) {
+	data, err := c.getData()
 	if err != nil {
 		return nil, dbusutil.ToError(err)
 	}
@@ -0,0 +1,279 @@
+package dsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// 不依赖 com.deepin.sync.Daemon 的本地实现，把所有注册的配置导出到归档文件中，或者从归档文件中导入。
+
+const (
+	LocalSyncPath      = "/com/deepin/daemon/LocalSync"
+	localSyncInterface = "com.deepin.daemon.LocalSync"
+
+	archiveVersion = 1
+)
+
+const (
+	DiffStatusChanged     = "changed"
+	DiffStatusOnlyLocal   = "only-local"
+	DiffStatusOnlyArchive = "only-archive"
+)
+
+var (
+	configsMu sync.Mutex
+	configs   = make(map[string]*Config)
+)
+
+func registerConfig(c *Config) {
+	configsMu.Lock()
+	configs[c.name] = c
+	configsMu.Unlock()
+}
+
+func unregisterConfig(c *Config) {
+	configsMu.Lock()
+	if configs[c.name] == c {
+		delete(configs, c.name)
+	}
+	configsMu.Unlock()
+}
+
+func getConfigs() map[string]*Config {
+	configsMu.Lock()
+	defer configsMu.Unlock()
+
+	result := make(map[string]*Config, len(configs))
+	for name, c := range configs {
+		result[name] = c
+	}
+	return result
+}
+
+// Archive 是导出的归档文件的内容，Modules 中的数据就是各个模块 Get 返回的数据。
+type Archive struct {
+	Version int
+	Time    time.Time
+	Modules map[string]json.RawMessage
+}
+
+// ModuleDiff 表示本地配置和归档中一个模块的差异
+type ModuleDiff struct {
+	Name   string
+	Status string
+	// 有差异的顶层字段，数据不是 JSON 对象时为空
+	Keys []string `json:",omitempty"`
+}
+
+type LocalSync struct {
+	methods *struct {
+		ListModules func() `out:"names"`
+		Export      func() `in:"path"`
+		Import      func() `in:"path,names"`
+		Diff        func() `in:"path" out:"diff"`
+	}
+}
+
+func NewLocalSync() *LocalSync {
+	return &LocalSync{}
+}
+
+func (*LocalSync) GetInterfaceName() string {
+	return localSyncInterface
+}
+
+func snapshot() (map[string]json.RawMessage, error) {
+	result := make(map[string]json.RawMessage)
+	for name, c := range getConfigs() {
+		data, err := c.getData()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get config of %s: %v", name, err)
+		}
+		result[name] = data
+	}
+	return result, nil
+}
+
+func readArchive(filename string) (*Archive, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var archive Archive
+	err = json.Unmarshal(data, &archive)
+	if err != nil {
+		return nil, err
+	}
+	if archive.Version <= 0 || archive.Version > archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", archive.Version)
+	}
+	return &archive, nil
+}
+
+func writeArchive(filename string, archive *Archive) error {
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 网络等模块的配置中可能有密码
+	return ioutil.WriteFile(filename, data, 0600)
+}
+
+func (*LocalSync) ListModules() ([]string, *dbus.Error) {
+	var names []string
+	for name := range getConfigs() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Export 把所有模块的配置导出到 path
+func (*LocalSync) Export(path string) *dbus.Error {
+	modules, err := snapshot()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = writeArchive(path, &Archive{
+		Version: archiveVersion,
+		Time:    time.Now(),
+		Modules: modules,
+	})
+	return dbusutil.ToError(err)
+}
+
+// Import 从 path 导入 names 指定的模块的配置，names 为空时导入所有模块。
+func (*LocalSync) Import(path string, names []string) *dbus.Error {
+	archive, err := readArchive(path)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	if len(names) == 0 {
+		for name := range archive.Modules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	localConfigs := getConfigs()
+	var errs []string
+	for _, name := range names {
+		data, ok := archive.Modules[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: not in archive", name))
+			continue
+		}
+		c := localConfigs[name]
+		if c == nil {
+			errs = append(errs, fmt.Sprintf("%s: module not running", name))
+			continue
+		}
+		err = c.core.Set(data)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return dbusutil.ToError(fmt.Errorf("failed to import: %s", strings.Join(errs, "; ")))
+	}
+	return nil
+}
+
+// Diff 比较本地配置和 path 中的配置，返回有差异的模块的 JSON 列表。
+func (*LocalSync) Diff(path string) (string, *dbus.Error) {
+	archive, err := readArchive(path)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	modules, err := snapshot()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	diff, err := diffModules(modules, archive.Modules)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func diffModules(local, archive map[string]json.RawMessage) ([]*ModuleDiff, error) {
+	var names []string
+	for name := range local {
+		names = append(names, name)
+	}
+	for name := range archive {
+		if _, ok := local[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := make([]*ModuleDiff, 0)
+	for _, name := range names {
+		localData, inLocal := local[name]
+		archiveData, inArchive := archive[name]
+		switch {
+		case !inArchive:
+			result = append(result, &ModuleDiff{Name: name, Status: DiffStatusOnlyLocal})
+		case !inLocal:
+			result = append(result, &ModuleDiff{Name: name, Status: DiffStatusOnlyArchive})
+		default:
+			equal, keys, err := diffJSON(localData, archiveData)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			if !equal {
+				result = append(result, &ModuleDiff{Name: name, Status: DiffStatusChanged, Keys: keys})
+			}
+		}
+	}
+	return result, nil
+}
+
+// diffJSON 比较两个 JSON 数据，都是对象时返回有差异的顶层字段。
+func diffJSON(a, b json.RawMessage) (bool, []string, error) {
+	var va, vb interface{}
+	err := json.Unmarshal(a, &va)
+	if err != nil {
+		return false, nil, err
+	}
+	err = json.Unmarshal(b, &vb)
+	if err != nil {
+		return false, nil, err
+	}
+	if reflect.DeepEqual(va, vb) {
+		return true, nil, nil
+	}
+
+	ma, okA := va.(map[string]interface{})
+	mb, okB := vb.(map[string]interface{})
+	if !okA || !okB {
+		return false, nil, nil
+	}
+	var keys []string
+	for key, value := range ma {
+		if !reflect.DeepEqual(value, mb[key]) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range mb {
+		if _, ok := ma[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return false, keys, nil
+}
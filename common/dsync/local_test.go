@@ -0,0 +1,49 @@
+package dsync
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_diffModules(t *testing.T) {
+	local := map[string]json.RawMessage{
+		"audio":      json.RawMessage(`{"a":1,"b":"x"}`),
+		"dock":       json.RawMessage(`{"pos":1}`),
+		"appearance": json.RawMessage(`{"theme":"light","font":"Noto"}`),
+	}
+	archive := map[string]json.RawMessage{
+		"audio":      json.RawMessage(`{"b":"x","a":1}`),
+		"network":    json.RawMessage(`{}`),
+		"appearance": json.RawMessage(`{"theme":"dark","size":12,"font":"Noto"}`),
+	}
+
+	diff, err := diffModules(local, archive)
+	assert.Nil(t, err)
+	assert.Equal(t, []*ModuleDiff{
+		{Name: "appearance", Status: DiffStatusChanged, Keys: []string{"size", "theme"}},
+		{Name: "dock", Status: DiffStatusOnlyLocal},
+		{Name: "network", Status: DiffStatusOnlyArchive},
+	}, diff)
+
+	diff, err = diffModules(local, local)
+	assert.Nil(t, err)
+	assert.Empty(t, diff)
+
+	_, err = diffModules(local, map[string]json.RawMessage{
+		"dock": json.RawMessage(`{`),
+	})
+	assert.NotNil(t, err)
+}
+
+func Test_diffJSON(t *testing.T) {
+	equal, keys, err := diffJSON(json.RawMessage(`[1,2]`), json.RawMessage(`[2,1]`))
+	assert.Nil(t, err)
+	assert.False(t, equal)
+	assert.Nil(t, keys)
+
+	equal, _, err = diffJSON(json.RawMessage(`"a"`), json.RawMessage(`"a"`))
+	assert.Nil(t, err)
+	assert.True(t, equal)
+}
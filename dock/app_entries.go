@@ -72,6 +72,17 @@ func (entries *AppEntries) GetByInnerId(id string) *AppEntry {
 	return nil
 }
 
+func (entries *AppEntries) getById(id string) *AppEntry {
+	entries.mu.RLock()
+	defer entries.mu.RUnlock()
+	for _, entry := range entries.items {
+		if entry.Id == id {
+			return entry
+		}
+	}
+	return nil
+}
+
 func (entries *AppEntries) Append(entry *AppEntry) {
 	entries.Insert(entry, -1)
 }
@@ -146,6 +157,27 @@ func (entries *AppEntries) Move(index, newIndex int) error {
 	return fmt.Errorf("index out of bounds, index: %v, newIndex: %v, len: %v", index, newIndex, entriesLength)
 }
 
+// sortDocked 按照 dockedApps 的顺序重新排列驻留的应用，其他应用的位置不变。
+func (entries *AppEntries) sortDocked(dockedApps []string) {
+	entries.mu.Lock()
+	defer entries.mu.Unlock()
+
+	keys := make([]string, len(entries.items))
+	for i, entry := range entries.items {
+		entry.PropsMu.RLock()
+		if entry.appInfo != nil && entry.IsDocked {
+			keys[i] = zipDesktopPath(entry.appInfo.GetFileName())
+		}
+		entry.PropsMu.RUnlock()
+	}
+
+	items := make([]*AppEntry, len(entries.items))
+	for i, idx := range reorderSlots(keys, dockedApps) {
+		items[i] = entries.items[idx]
+	}
+	entries.items = items
+}
+
 func (entries *AppEntries) FilterDocked() (dockedEntries []*AppEntry) {
 	entries.mu.RLock()
 
@@ -48,6 +48,12 @@ type AppEntry struct {
 	IsDocked      bool
 	// dbusutil-gen: equal=method:Equal
 	WindowInfos windowInfosType
+	// 应用通过 com.canonical.Unity.LauncherEntry 设置的计数，进度和紧急状态
+	Count           int64
+	CountVisible    bool
+	Progress        float64
+	ProgressVisible bool
+	Urgent          bool
 
 	service          *dbusutil.Service
 	manager          *Manager
@@ -78,6 +84,13 @@ func newAppEntry(dockManager *Manager, innerId string, appInfo *AppInfo) *AppEnt
 	entry.setAppInfo(appInfo)
 	entry.Name = entry.getName()
 	entry.Icon = entry.getIcon()
+	if state := dockManager.getLauncherEntryState(entry.getLauncherEntryAppId()); state != nil {
+		entry.Count = state.count
+		entry.CountVisible = state.countVisible
+		entry.Progress = state.progress
+		entry.ProgressVisible = state.progressVisible
+		entry.Urgent = state.urgent
+	}
 	return entry
 }
 
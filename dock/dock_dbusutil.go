@@ -109,3 +109,68 @@ func (v *AppEntry) setPropWindowInfos(value windowInfosType) (changed bool) {
 func (v *AppEntry) emitPropChangedWindowInfos(value windowInfosType) error {
 	return v.service.EmitPropertyChanged(v, "WindowInfos", value)
 }
+
+func (v *AppEntry) setPropCount(value int64) (changed bool) {
+	if v.Count != value {
+		v.Count = value
+		v.emitPropChangedCount(value)
+		return true
+	}
+	return false
+}
+
+func (v *AppEntry) emitPropChangedCount(value int64) error {
+	return v.service.EmitPropertyChanged(v, "Count", value)
+}
+
+func (v *AppEntry) setPropCountVisible(value bool) (changed bool) {
+	if v.CountVisible != value {
+		v.CountVisible = value
+		v.emitPropChangedCountVisible(value)
+		return true
+	}
+	return false
+}
+
+func (v *AppEntry) emitPropChangedCountVisible(value bool) error {
+	return v.service.EmitPropertyChanged(v, "CountVisible", value)
+}
+
+func (v *AppEntry) setPropProgress(value float64) (changed bool) {
+	if v.Progress != value {
+		v.Progress = value
+		v.emitPropChangedProgress(value)
+		return true
+	}
+	return false
+}
+
+func (v *AppEntry) emitPropChangedProgress(value float64) error {
+	return v.service.EmitPropertyChanged(v, "Progress", value)
+}
+
+func (v *AppEntry) setPropProgressVisible(value bool) (changed bool) {
+	if v.ProgressVisible != value {
+		v.ProgressVisible = value
+		v.emitPropChangedProgressVisible(value)
+		return true
+	}
+	return false
+}
+
+func (v *AppEntry) emitPropChangedProgressVisible(value bool) error {
+	return v.service.EmitPropertyChanged(v, "ProgressVisible", value)
+}
+
+func (v *AppEntry) setPropUrgent(value bool) (changed bool) {
+	if v.Urgent != value {
+		v.Urgent = value
+		v.emitPropChangedUrgent(value)
+		return true
+	}
+	return false
+}
+
+func (v *AppEntry) emitPropChangedUrgent(value bool) error {
+	return v.service.EmitPropertyChanged(v, "Urgent", value)
+}
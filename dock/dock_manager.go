@@ -28,11 +28,14 @@ import (
 	"time"
 
 	libApps "github.com/linuxdeepin/go-dbus-factory/com.deepin.daemon.apps"
+	"github.com/linuxdeepin/go-dbus-factory/com.deepin.daemon.display"
 	"github.com/linuxdeepin/go-dbus-factory/com.deepin.dde.daemon.launcher"
 	libDDELauncher "github.com/linuxdeepin/go-dbus-factory/com.deepin.dde.launcher"
 	"github.com/linuxdeepin/go-dbus-factory/com.deepin.sessionmanager"
+	"github.com/linuxdeepin/go-dbus-factory/com.deepin.system.power"
 	"github.com/linuxdeepin/go-dbus-factory/com.deepin.wm"
 	"github.com/linuxdeepin/go-dbus-factory/com.deepin.wmswitcher"
+	ofdbus "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.dbus"
 	"github.com/linuxdeepin/go-x11-client"
 	"pkg.deepin.io/dde/daemon/common/dsync"
 	"pkg.deepin.io/gir/gio-2.0"
@@ -40,6 +43,7 @@ import (
 	"pkg.deepin.io/lib/dbusutil"
 	"pkg.deepin.io/lib/dbusutil/gsprop"
 	"pkg.deepin.io/lib/dbusutil/proxy"
+	"pkg.deepin.io/lib/fsnotify"
 	"pkg.deepin.io/lib/strv"
 )
 
@@ -58,6 +62,8 @@ type Manager struct {
 	Opacity             gsprop.Double
 	HideState           HideStateType
 	FrontendWindowRect  *Rect
+	// 当前的任务栏方案，为空表示没有使用方案
+	CurrentProfile string
 
 	service            *dbusutil.Service
 	sessionSigLoop     *dbusutil.SignalLoop
@@ -87,6 +93,22 @@ type Manager struct {
 	entryCount         uint
 	identifyWindowFuns []*IdentifyWindowFunc
 	windowPatterns     WindowPatterns
+	windowPatternsMu   sync.RWMutex
+
+	windowPatternsWatcher *fsnotify.Watcher
+
+	profiles   *dockProfiles
+	profilesMu sync.Mutex
+
+	entryGrouping   *entryGrouping
+	entryGroupingMu sync.Mutex
+
+	launcherEntries   map[string]*launcherEntryState
+	launcherEntriesMu sync.Mutex
+
+	windowMRU     windowMRU
+	windowMRUMu   sync.Mutex
+	mruCycleTimer *time.Timer
 
 	tempUndockedFiles strv.Strv
 
@@ -98,6 +120,11 @@ type Manager struct {
 	startManager *sessionmanager.StartManager
 	wmSwitcher   *wmswitcher.WMSwitcher
 	waylandWM    *kwayland.WindowManager
+	dbusDaemon   *ofdbus.DBus
+	display      *display.Display
+	power        *power.Power
+
+	systemSigLoop *dbusutil.SignalLoop
 
 	wmName string
 
@@ -113,6 +140,10 @@ type Manager struct {
 		}
 
 		PluginSettingsSynced struct{}
+
+		ProfileSwitched struct {
+			name string
+		}
 	}
 
 	methods *struct {
@@ -131,11 +162,24 @@ type Manager struct {
 		MoveEntry                 func() `in:"index,newIndex"`
 		IsOnDock                  func() `in:"desktopFile" out:"value"`
 		QueryWindowIdentifyMethod func() `in:"win" out:"identifyMethod"`
+		ExplainWindowIdentify     func() `in:"win" out:"explanation"`
 		GetDockedAppsDesktopFiles func() `out:"desktopFiles"`
 		SetPluginSettings         func() `in:"jsonStr"`
 		GetPluginSettings         func() `out:"jsonStr"`
 		MergePluginSettings       func() `in:"jsonStr"`
 		RemovePluginSettings      func() `in:"key1,key2List"`
+		CreateProfile             func() `in:"name"`
+		DeleteProfile             func() `in:"name"`
+		SwitchProfile             func() `in:"name"`
+		ListProfiles              func() `out:"profiles"`
+		SetProfileTrigger         func() `in:"name,monitorCount,powerSource"`
+		MergeEntries              func() `in:"innerId,targetInnerId"`
+		SplitEntry                func() `in:"innerId"`
+		ResetEntryGrouping        func() `in:"innerId"`
+		GetEntryGrouping          func() `out:"grouping"`
+		GetWindowsMRU             func() `in:"entryId" out:"windows"`
+		ActivateNextWindow        func() `in:"entryId"`
+		ActivatePrevWindow        func() `in:"entryId"`
 		DebugRegisterWW           func() `in:"winId"`
 		DebugSetActiveWindow      func() `in:"winId"`
 	}
@@ -184,16 +228,33 @@ func (m *Manager) destroy() {
 		m.smartHideModeTimer = nil
 	}
 
+	m.windowMRUMu.Lock()
+	if m.mruCycleTimer != nil {
+		m.mruCycleTimer.Stop()
+		m.mruCycleTimer = nil
+	}
+	m.windowMRUMu.Unlock()
+
 	if m.settings != nil {
 		m.settings.Unref()
 		m.settings = nil
 	}
 
 	m.launcher.RemoveHandler(proxy.RemoveAllHandlers)
+	m.dbusDaemon.RemoveHandler(proxy.RemoveAllHandlers)
 	m.ddeLauncher.RemoveHandler(proxy.RemoveAllHandlers)
 	m.sessionSigLoop.Stop()
+	m.destroyProfiles()
 	m.syncConfig.Destroy()
 
+	if m.windowPatternsWatcher != nil {
+		err := m.windowPatternsWatcher.Close()
+		if err != nil {
+			logger.Warning(err)
+		}
+		m.windowPatternsWatcher = nil
+	}
+
 	err := m.service.StopExport(m)
 	if err != nil {
 		logger.Warning(err)
@@ -144,6 +144,33 @@ func (m *Manager) saveDockedApps() {
 	m.DockedApps.Set(list)
 }
 
+// setDockedApps 驻留 dockedApps 中新增的应用，取消驻留不在其中的应用
+func (m *Manager) setDockedApps(dockedApps []string) {
+	added, removed := diffStrSlice(m.DockedApps.Get(), dockedApps)
+
+	for _, value := range added {
+		desktopFile := unzipDesktopPath(value)
+		_, err := os.Stat(desktopFile)
+		if err == nil {
+			_, err = m.requestDock(desktopFile, -1)
+			if err != nil {
+				logger.Warning(err)
+			}
+		}
+	}
+
+	for _, value := range removed {
+		desktopFile := unzipDesktopPath(value)
+
+		_, err := m.requestUndock(desktopFile)
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+
+	m.DockedApps.Set(dockedApps)
+}
+
 func needScratchDesktop(appInfo *AppInfo) bool {
 	if appInfo == nil {
 		logger.Debug("needScratchDesktop: yes, appInfo is nil")
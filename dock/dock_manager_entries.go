@@ -93,7 +93,7 @@ func (m *Manager) attachOrDetachWindow(winInfo WindowInfo) {
 
 		if winInfo.getEntryInnerId() == "" {
 			entryInnerId, appInfo := m.identifyWindow(winInfo)
-			winInfo.setEntryInnerId(entryInnerId)
+			winInfo.setEntryInnerId(m.getEntryInnerId(entryInnerId, win))
 			winInfo.setAppInfo(appInfo)
 			m.markAppLaunched(appInfo)
 		} else {
@@ -207,9 +207,10 @@ func (m *Manager) init() error {
 	m.listenSettingsChanged()
 
 	m.windowInfoMap = make(map[x.Window]*XWindowInfo)
-	m.windowPatterns, err = loadWindowPatterns(windowPatternsFile)
+	m.loadWindowPatterns()
+	err = m.watchWindowPatterns()
 	if err != nil {
-		logger.Warning("loadWindowPatterns failed:", err)
+		logger.Warning("failed to watch window patterns:", err)
 	}
 
 	sessionBus := m.service.Conn()
@@ -231,9 +232,13 @@ func (m *Manager) init() error {
 	m.listenLauncherSignal()
 	m.listenWMSwitcherSignal()
 	m.listenWaylandWMSignals()
+	m.launcherEntries = make(map[string]*launcherEntryState)
+	m.listenLauncherEntrySignals()
 
 	m.registerIdentifyWindowFuncs()
+	m.loadEntryGrouping()
 	m.initEntries()
+	m.initProfiles(sessionBus, systemBus)
 	m.pluginSettings = newPluginSettingsStorage(m)
 
 	m.syncConfig = dsync.NewConfig("dock", &syncConfig{m: m}, m.sessionSigLoop,
@@ -70,6 +70,7 @@ func (m *Manager) unregisterWindow(win x.Window) {
 	m.windowInfoMapMutex.Lock()
 	delete(m.windowInfoMap, win)
 	m.windowInfoMapMutex.Unlock()
+	m.removeWindowMRU(win)
 }
 
 func (m *Manager) handleClientListChanged() {
@@ -134,6 +135,7 @@ func (m *Manager) handleActiveWindowChanged(activeWindow WindowInfo) {
 	m.activeWindowMu.Unlock()
 
 	activeWinXid := activeWindow.getXid()
+	m.handleWindowMRUActive(activeWinXid)
 
 	m.Entries.mu.RLock()
 	for _, entry := range m.Entries.items {
@@ -0,0 +1,399 @@
+package dock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/linuxdeepin/go-dbus-factory/com.deepin.daemon.display"
+	"github.com/linuxdeepin/go-dbus-factory/com.deepin.system.power"
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/dbusutil/proxy"
+)
+
+// 任务栏方案保存驻留的应用及其顺序，位置，显示模式和隐藏模式，可以手动切换，
+// 也可以在显示器数量或者电源变化时自动切换到满足条件的方案。
+
+const (
+	powerSourceAC      = "ac"
+	powerSourceBattery = "battery"
+)
+
+// DockProfile 是一个任务栏方案
+type DockProfile struct {
+	Name string
+	// 驻留的应用，顺序就是在任务栏上的顺序
+	DockedApps  []string
+	Position    string
+	DisplayMode string
+	HideMode    string
+	// 自动切换的条件，为空时只能手动切换
+	Trigger *ProfileTrigger `json:",omitempty"`
+}
+
+// ProfileTrigger 是自动切换方案的条件，所有设置的条件都满足时切换。
+type ProfileTrigger struct {
+	// 显示器数量，0 表示不限制
+	MonitorCount int `json:",omitempty"`
+	// ac 或 battery，为空表示不限制
+	PowerSource string `json:",omitempty"`
+}
+
+func (t *ProfileTrigger) isEmpty() bool {
+	return t == nil || (t.MonitorCount == 0 && t.PowerSource == "")
+}
+
+// conditionNum 返回设置的条件数量，条件越多越优先
+func (t *ProfileTrigger) conditionNum() int {
+	var n int
+	if t.MonitorCount > 0 {
+		n++
+	}
+	if t.PowerSource != "" {
+		n++
+	}
+	return n
+}
+
+type profileContext struct {
+	monitorCount int
+	powerSource  string
+}
+
+func (t *ProfileTrigger) match(ctx *profileContext) bool {
+	if t.isEmpty() {
+		return false
+	}
+	if t.MonitorCount > 0 && t.MonitorCount != ctx.monitorCount {
+		return false
+	}
+	if t.PowerSource != "" && t.PowerSource != ctx.powerSource {
+		return false
+	}
+	return true
+}
+
+type dockProfiles struct {
+	Current  string
+	Profiles []*DockProfile
+}
+
+func (p *dockProfiles) get(name string) *DockProfile {
+	for _, profile := range p.Profiles {
+		if profile.Name == name {
+			return profile
+		}
+	}
+	return nil
+}
+
+func (p *dockProfiles) remove(name string) bool {
+	for i, profile := range p.Profiles {
+		if profile.Name == name {
+			p.Profiles = append(p.Profiles[:i], p.Profiles[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// match 返回条件满足 ctx 的方案，多个方案满足时选择条件最多的，相同时选择靠前的。
+func (p *dockProfiles) match(ctx *profileContext) *DockProfile {
+	var result *DockProfile
+	for _, profile := range p.Profiles {
+		if !profile.Trigger.match(ctx) {
+			continue
+		}
+		if result == nil || profile.Trigger.conditionNum() > result.Trigger.conditionNum() {
+			result = profile
+		}
+	}
+	return result
+}
+
+func loadDockProfiles(filename string) (*dockProfiles, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var profiles dockProfiles
+	err = json.Unmarshal(data, &profiles)
+	if err != nil {
+		return nil, err
+	}
+	return &profiles, nil
+}
+
+func (p *dockProfiles) save(filename string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func checkProfileName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("profile name is empty")
+	}
+	return nil
+}
+
+func checkProfileTrigger(trigger *ProfileTrigger) error {
+	if trigger.MonitorCount < 0 {
+		return fmt.Errorf("invalid monitor count %d", trigger.MonitorCount)
+	}
+	switch trigger.PowerSource {
+	case "", powerSourceAC, powerSourceBattery:
+		return nil
+	default:
+		return fmt.Errorf("invalid power source %q", trigger.PowerSource)
+	}
+}
+
+func (m *Manager) initProfiles(sessionBus, systemBus *dbus.Conn) {
+	profiles, err := loadDockProfiles(profilesFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load dock profiles:", err)
+		}
+		profiles = &dockProfiles{}
+	}
+	if profiles.Current != "" && profiles.get(profiles.Current) == nil {
+		profiles.Current = ""
+	}
+	m.profiles = profiles
+	m.CurrentProfile = profiles.Current
+
+	m.systemSigLoop = dbusutil.NewSignalLoop(systemBus, 10)
+	m.systemSigLoop.Start()
+
+	m.display = display.NewDisplay(sessionBus)
+	m.display.InitSignalExt(m.sessionSigLoop, true)
+	err = m.display.Monitors().ConnectChanged(func(hasValue bool, value []dbus.ObjectPath) {
+		if !hasValue {
+			return
+		}
+		logger.Debug("monitor count changed to", len(value))
+		m.checkProfileTriggers()
+	})
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	m.power = power.NewPower(systemBus)
+	m.power.InitSignalExt(m.systemSigLoop, true)
+	err = m.power.OnBattery().ConnectChanged(func(hasValue bool, value bool) {
+		if !hasValue {
+			return
+		}
+		logger.Debug("OnBattery changed to", value)
+		m.checkProfileTriggers()
+	})
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	// 注销期间显示器或者电源可能已经变化
+	go m.checkProfileTriggers()
+}
+
+func (m *Manager) destroyProfiles() {
+	m.display.RemoveHandler(proxy.RemoveAllHandlers)
+	m.power.RemoveHandler(proxy.RemoveAllHandlers)
+	m.systemSigLoop.Stop()
+}
+
+func (m *Manager) getProfileContext() (*profileContext, error) {
+	monitors, err := m.display.Monitors().Get(0)
+	if err != nil {
+		return nil, err
+	}
+	onBattery, err := m.power.OnBattery().Get(0)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &profileContext{
+		monitorCount: len(monitors),
+		powerSource:  powerSourceAC,
+	}
+	if onBattery {
+		ctx.powerSource = powerSourceBattery
+	}
+	return ctx, nil
+}
+
+// checkProfileTriggers 在显示器数量或者电源变化时切换到满足条件的方案
+func (m *Manager) checkProfileTriggers() {
+	ctx, err := m.getProfileContext()
+	if err != nil {
+		logger.Warning("failed to get profile context:", err)
+		return
+	}
+
+	m.profilesMu.Lock()
+	defer m.profilesMu.Unlock()
+	profile := m.profiles.match(ctx)
+	if profile == nil || profile.Name == m.profiles.Current {
+		return
+	}
+	logger.Infof("switch to dock profile %q, context: %+v", profile.Name, *ctx)
+	err = m.switchProfile(profile)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+// snapshotProfile 把当前的设置保存到 profile 中
+func (m *Manager) snapshotProfile(profile *DockProfile) {
+	profile.DockedApps = m.DockedApps.Get()
+	profile.Position = m.Position.GetString()
+	profile.DisplayMode = m.DisplayMode.GetString()
+	profile.HideMode = m.HideMode.GetString()
+}
+
+func (m *Manager) applyProfile(profile *DockProfile) {
+	if profile.Position != "" {
+		m.Position.SetString(profile.Position)
+	}
+	if profile.DisplayMode != "" {
+		m.DisplayMode.SetString(profile.DisplayMode)
+	}
+	if profile.HideMode != "" {
+		m.HideMode.SetString(profile.HideMode)
+	}
+
+	m.setDockedApps(profile.DockedApps)
+	m.Entries.sortDocked(profile.DockedApps)
+	m.saveDockedApps()
+
+	entries, _ := m.Entries.GetValue()
+	err := m.service.EmitPropertyChanged(m, "Entries", entries)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+// switchProfile 保存当前方案的设置，然后应用 profile，调用者需要持有 profilesMu。
+func (m *Manager) switchProfile(profile *DockProfile) error {
+	if current := m.profiles.get(m.profiles.Current); current != nil {
+		m.snapshotProfile(current)
+	}
+	m.applyProfile(profile)
+	m.profiles.Current = profile.Name
+	m.setPropCurrentProfile(profile.Name)
+
+	err := m.service.Emit(m, "ProfileSwitched", profile.Name)
+	if err != nil {
+		logger.Warning(err)
+	}
+	return m.profiles.save(profilesFile)
+}
+
+func (m *Manager) setPropCurrentProfile(name string) {
+	m.PropsMu.Lock()
+	if m.CurrentProfile != name {
+		m.CurrentProfile = name
+		m.service.EmitPropertyChanged(m, "CurrentProfile", name)
+	}
+	m.PropsMu.Unlock()
+}
+
+// CreateProfile 用当前的设置创建方案 name
+func (m *Manager) CreateProfile(name string) *dbus.Error {
+	err := checkProfileName(name)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.profilesMu.Lock()
+	defer m.profilesMu.Unlock()
+	if m.profiles.get(name) != nil {
+		return dbusutil.ToError(fmt.Errorf("profile %q already exists", name))
+	}
+	profile := &DockProfile{Name: name}
+	m.snapshotProfile(profile)
+	m.profiles.Profiles = append(m.profiles.Profiles, profile)
+	return dbusutil.ToError(m.profiles.save(profilesFile))
+}
+
+func (m *Manager) DeleteProfile(name string) *dbus.Error {
+	m.profilesMu.Lock()
+	defer m.profilesMu.Unlock()
+	if !m.profiles.remove(name) {
+		return dbusutil.ToError(fmt.Errorf("profile %q not found", name))
+	}
+	if m.profiles.Current == name {
+		m.profiles.Current = ""
+		m.setPropCurrentProfile("")
+	}
+	return dbusutil.ToError(m.profiles.save(profilesFile))
+}
+
+// SwitchProfile 切换到方案 name，切换前当前的设置会保存到当前方案中。
+func (m *Manager) SwitchProfile(name string) *dbus.Error {
+	m.profilesMu.Lock()
+	defer m.profilesMu.Unlock()
+	profile := m.profiles.get(name)
+	if profile == nil {
+		return dbusutil.ToError(fmt.Errorf("profile %q not found", name))
+	}
+	if name == m.profiles.Current {
+		return nil
+	}
+	return dbusutil.ToError(m.switchProfile(profile))
+}
+
+// ListProfiles 返回所有方案的 JSON，当前方案的设置是最新的。
+func (m *Manager) ListProfiles() (string, *dbus.Error) {
+	m.profilesMu.Lock()
+	defer m.profilesMu.Unlock()
+	if current := m.profiles.get(m.profiles.Current); current != nil {
+		m.snapshotProfile(current)
+	}
+	profiles := m.profiles.Profiles
+	if profiles == nil {
+		profiles = []*DockProfile{}
+	}
+	data, err := json.Marshal(profiles)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetProfileTrigger 设置方案 name 自动切换的条件，monitorCount 为 0 且 powerSource 为空时取消自动切换。
+func (m *Manager) SetProfileTrigger(name string, monitorCount int32, powerSource string) *dbus.Error {
+	trigger := &ProfileTrigger{
+		MonitorCount: int(monitorCount),
+		PowerSource:  powerSource,
+	}
+	err := checkProfileTrigger(trigger)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.profilesMu.Lock()
+	profile := m.profiles.get(name)
+	if profile == nil {
+		m.profilesMu.Unlock()
+		return dbusutil.ToError(fmt.Errorf("profile %q not found", name))
+	}
+	if trigger.isEmpty() {
+		trigger = nil
+	}
+	profile.Trigger = trigger
+	err = m.profiles.save(profilesFile)
+	m.profilesMu.Unlock()
+	return dbusutil.ToError(err)
+}
@@ -0,0 +1,72 @@
+package dock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_dockProfilesMatch(t *testing.T) {
+	Convey("dockProfiles match", t, func(c C) {
+		profiles := &dockProfiles{
+			Profiles: []*DockProfile{
+				{Name: "manual"},
+				{Name: "docked", Trigger: &ProfileTrigger{MonitorCount: 2}},
+				{Name: "presentation", Trigger: &ProfileTrigger{MonitorCount: 2, PowerSource: powerSourceBattery}},
+				{Name: "mobile", Trigger: &ProfileTrigger{PowerSource: powerSourceBattery}},
+			},
+		}
+
+		c.So(profiles.match(&profileContext{1, powerSourceAC}), ShouldBeNil)
+		c.So(profiles.match(&profileContext{2, powerSourceAC}).Name, ShouldEqual, "docked")
+		// 条件多的优先
+		c.So(profiles.match(&profileContext{2, powerSourceBattery}).Name, ShouldEqual, "presentation")
+		c.So(profiles.match(&profileContext{1, powerSourceBattery}).Name, ShouldEqual, "mobile")
+
+		c.So(profiles.remove("docked"), ShouldBeTrue)
+		c.So(profiles.remove("docked"), ShouldBeFalse)
+		c.So(profiles.get("docked"), ShouldBeNil)
+		c.So(profiles.match(&profileContext{2, powerSourceAC}), ShouldBeNil)
+	})
+}
+
+func Test_checkProfileTrigger(t *testing.T) {
+	Convey("checkProfileTrigger", t, func(c C) {
+		c.So(checkProfileTrigger(&ProfileTrigger{}), ShouldBeNil)
+		c.So(checkProfileTrigger(&ProfileTrigger{MonitorCount: 1, PowerSource: powerSourceAC}), ShouldBeNil)
+		c.So(checkProfileTrigger(&ProfileTrigger{MonitorCount: -1}), ShouldNotBeNil)
+		c.So(checkProfileTrigger(&ProfileTrigger{PowerSource: "usb"}), ShouldNotBeNil)
+		c.So(checkProfileName(" "), ShouldNotBeNil)
+	})
+}
+
+func Test_dockProfilesSave(t *testing.T) {
+	Convey("dockProfiles save and load", t, func(c C) {
+		dir, err := ioutil.TempDir("", "dock-profiles")
+		c.So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		filename := filepath.Join(dir, "dock/profiles.json")
+		profiles := &dockProfiles{
+			Current: "work",
+			Profiles: []*DockProfile{
+				{
+					Name:        "work",
+					DockedApps:  []string{"/S@dde-file-manager", "/S@deepin-terminal"},
+					Position:    "bottom",
+					DisplayMode: "efficient",
+					HideMode:    "keep-showing",
+					Trigger:     &ProfileTrigger{MonitorCount: 2},
+				},
+			},
+		}
+		c.So(profiles.save(filename), ShouldBeNil)
+
+		loaded, err := loadDockProfiles(filename)
+		c.So(err, ShouldBeNil)
+		c.So(loaded, ShouldResemble, profiles)
+	})
+}
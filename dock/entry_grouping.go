@@ -0,0 +1,205 @@
+package dock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/strv"
+)
+
+// 用户可以把识别为一个 innerId 的窗口合并到另一个 innerId 的 entry 中，
+// 或者把一个 innerId 的窗口拆分开，每个窗口一个 entry。
+
+const entrySplitSep = "#"
+
+type entryGrouping struct {
+	// 窗口的 innerId => 合并到的 entry 的 innerId
+	Merge map[string]string
+	// 拆分的 innerId
+	Split []string
+}
+
+// apply 返回识别为 innerId 的窗口 win 应该属于的 entry 的 innerId，合并只进行一次，避免循环。
+func (g *entryGrouping) apply(innerId string, win x.Window) string {
+	if innerId == "" {
+		return ""
+	}
+	// Pid 方法返回的可能是拆分后的 entry 的 innerId
+	if idx := strings.Index(innerId, entrySplitSep); idx != -1 {
+		innerId = innerId[:idx]
+	}
+	if target, ok := g.Merge[innerId]; ok {
+		innerId = target
+	}
+	if strv.Strv(g.Split).Contains(innerId) {
+		return fmt.Sprintf("%s%s%d", innerId, entrySplitSep, win)
+	}
+	return innerId
+}
+
+// affectsEntry 判断 innerId 为 entryInnerId 的 entry 是否受 innerIds 的规则影响
+func affectsEntry(entryInnerId string, innerIds []string) bool {
+	for _, innerId := range innerIds {
+		if entryInnerId == innerId || strings.HasPrefix(entryInnerId, innerId+entrySplitSep) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *entryGrouping) merge(innerId, target string) error {
+	if innerId == "" || target == "" {
+		return fmt.Errorf("innerId is empty")
+	}
+	if innerId == target {
+		return fmt.Errorf("can not merge %q into itself", innerId)
+	}
+	if g.Merge == nil {
+		g.Merge = make(map[string]string)
+	}
+	g.Merge[innerId] = target
+	return nil
+}
+
+func (g *entryGrouping) split(innerId string) error {
+	if innerId == "" {
+		return fmt.Errorf("innerId is empty")
+	}
+	if !strv.Strv(g.Split).Contains(innerId) {
+		g.Split = append(g.Split, innerId)
+	}
+	return nil
+}
+
+// reset 删除 innerId 的合并和拆分规则，返回受影响的 innerId。
+func (g *entryGrouping) reset(innerId string) []string {
+	affected := []string{innerId}
+	if target, ok := g.Merge[innerId]; ok {
+		affected = append(affected, target)
+		delete(g.Merge, innerId)
+	}
+	g.Split, _ = strv.Strv(g.Split).Delete(innerId)
+	return affected
+}
+
+func loadEntryGrouping(filename string) (*entryGrouping, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var g entryGrouping
+	err = json.Unmarshal(data, &g)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (g *entryGrouping) save(filename string) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func (m *Manager) loadEntryGrouping() {
+	g, err := loadEntryGrouping(entryGroupingFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load entry grouping:", err)
+		}
+		g = &entryGrouping{}
+	}
+	m.entryGrouping = g
+}
+
+func (m *Manager) getEntryInnerId(innerId string, win x.Window) string {
+	m.entryGroupingMu.Lock()
+	defer m.entryGroupingMu.Unlock()
+	return m.entryGrouping.apply(innerId, win)
+}
+
+// regroupWindows 重新识别受 innerIds 的规则影响的窗口
+func (m *Manager) regroupWindows(innerIds []string) {
+	var winInfos []WindowInfo
+	m.Entries.mu.RLock()
+	for _, entry := range m.Entries.items {
+		if !affectsEntry(entry.innerId, innerIds) {
+			continue
+		}
+		entry.PropsMu.RLock()
+		winInfos = append(winInfos, entry.getWindowInfoSlice()...)
+		entry.PropsMu.RUnlock()
+	}
+	m.Entries.mu.RUnlock()
+
+	for _, winInfo := range winInfos {
+		m.detachWindow(winInfo)
+		winInfo.setEntryInnerId("")
+		m.attachOrDetachWindow(winInfo)
+	}
+}
+
+func (m *Manager) changeEntryGrouping(fn func(g *entryGrouping) ([]string, error)) error {
+	m.entryGroupingMu.Lock()
+	affected, err := fn(m.entryGrouping)
+	if err == nil {
+		err = m.entryGrouping.save(entryGroupingFile)
+	}
+	m.entryGroupingMu.Unlock()
+	if err != nil {
+		return err
+	}
+	m.regroupWindows(affected)
+	return nil
+}
+
+// MergeEntries 把识别为 innerId 的窗口合并到 innerId 为 targetInnerId 的 entry 中
+func (m *Manager) MergeEntries(innerId, targetInnerId string) *dbus.Error {
+	err := m.changeEntryGrouping(func(g *entryGrouping) ([]string, error) {
+		affected := []string{innerId, targetInnerId}
+		if old, ok := g.Merge[innerId]; ok {
+			affected = append(affected, old)
+		}
+		return affected, g.merge(innerId, targetInnerId)
+	})
+	return dbusutil.ToError(err)
+}
+
+// SplitEntry 把 innerId 的 entry 中的窗口拆分开，每个窗口一个 entry。
+func (m *Manager) SplitEntry(innerId string) *dbus.Error {
+	err := m.changeEntryGrouping(func(g *entryGrouping) ([]string, error) {
+		return []string{innerId}, g.split(innerId)
+	})
+	return dbusutil.ToError(err)
+}
+
+// ResetEntryGrouping 取消 innerId 的合并和拆分
+func (m *Manager) ResetEntryGrouping(innerId string) *dbus.Error {
+	err := m.changeEntryGrouping(func(g *entryGrouping) ([]string, error) {
+		return g.reset(innerId), nil
+	})
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) GetEntryGrouping() (string, *dbus.Error) {
+	m.entryGroupingMu.Lock()
+	data, err := json.Marshal(m.entryGrouping)
+	m.entryGroupingMu.Unlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
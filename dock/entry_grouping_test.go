@@ -0,0 +1,36 @@
+package dock
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_entryGrouping(t *testing.T) {
+	Convey("entryGrouping", t, func(c C) {
+		g := &entryGrouping{}
+		c.So(g.apply("w:chat", 1), ShouldEqual, "w:chat")
+
+		c.So(g.merge("w:chat", "d:chat"), ShouldBeNil)
+		c.So(g.merge("d:chat", "d:chat"), ShouldNotBeNil)
+		c.So(g.apply("w:chat", 1), ShouldEqual, "d:chat")
+
+		c.So(g.split("d:chat"), ShouldBeNil)
+		c.So(g.split("d:chat"), ShouldBeNil)
+		c.So(g.Split, ShouldHaveLength, 1)
+		c.So(g.apply("w:chat", 1), ShouldEqual, "d:chat#1")
+		c.So(g.apply("d:chat", 2), ShouldEqual, "d:chat#2")
+		// Pid 方法返回拆分后的 innerId
+		c.So(g.apply("d:chat#1", 3), ShouldEqual, "d:chat#3")
+
+		c.So(affectsEntry("d:chat#1", []string{"d:chat"}), ShouldBeTrue)
+		c.So(affectsEntry("d:chat", []string{"d:chat"}), ShouldBeTrue)
+		c.So(affectsEntry("d:chat2", []string{"d:chat"}), ShouldBeFalse)
+
+		c.So(g.reset("w:chat"), ShouldResemble, []string{"w:chat", "d:chat"})
+		c.So(g.apply("w:chat", 1), ShouldEqual, "w:chat")
+		c.So(g.reset("d:chat"), ShouldResemble, []string{"d:chat"})
+		c.So(g.Split, ShouldBeEmpty)
+		c.So(g.apply("d:chat", 2), ShouldEqual, "d:chat")
+	})
+}
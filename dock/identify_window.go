@@ -100,18 +100,7 @@ func (m *Manager) identifyWindowX(winInfo *XWindowInfo) (innerId string, appInfo
 			// success
 			logger.Debugf("identifyWindow by %s success, innerId: %q, appInfo: %v",
 				name, innerId, appInfo)
-			// NOTE: if name == "Pid", appInfo may be nil
-			if appInfo != nil {
-				fixedAppInfo := fixAutostartAppInfo(appInfo)
-				if fixedAppInfo != nil {
-					appInfo = fixedAppInfo
-					appInfo.identifyMethod = name + "+FixAutostart"
-					innerId = fixedAppInfo.innerId
-				} else {
-					appInfo.identifyMethod = name
-				}
-			}
-			return
+			return fixIdentifyResult(name, innerId, appInfo)
 		}
 	}
 	// fail
@@ -119,6 +108,22 @@ func (m *Manager) identifyWindowX(winInfo *XWindowInfo) (innerId string, appInfo
 	return winInfo.innerId, nil
 }
 
+// fixIdentifyResult 修正自启动目录中的应用，并记录识别方法
+func fixIdentifyResult(name, innerId string, appInfo *AppInfo) (string, *AppInfo) {
+	// NOTE: if name == "Pid", appInfo may be nil
+	if appInfo != nil {
+		fixedAppInfo := fixAutostartAppInfo(appInfo)
+		if fixedAppInfo != nil {
+			appInfo = fixedAppInfo
+			appInfo.identifyMethod = name + "+FixAutostart"
+			innerId = fixedAppInfo.innerId
+		} else {
+			appInfo.identifyMethod = name
+		}
+	}
+	return innerId, appInfo
+}
+
 func fixAutostartAppInfo(appInfo *AppInfo) *AppInfo {
 	file := appInfo.GetFileName()
 	if isInAutostartDir(file) {
@@ -291,7 +296,7 @@ func identifyWindowByPidEnv(m *Manager, winInfo *XWindowInfo) (string, *AppInfo)
 }
 
 func identifyWindowByRule(m *Manager, winInfo *XWindowInfo) (string, *AppInfo) {
-	ret := m.windowPatterns.Match(winInfo)
+	ret := m.getWindowPatterns().Match(winInfo)
 	if ret == "" {
 		return "", nil
 	}
@@ -0,0 +1,215 @@
+package dock
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// WindowIdentifyExplanation 是 ExplainWindowIdentify 返回的窗口识别过程
+type WindowIdentifyExplanation struct {
+	Window uint32
+	Inputs *WindowIdentifyInputs
+	// 按顺序尝试的所有识别方法，第一个成功的被采用
+	Steps []*WindowIdentifyStep
+	// 最终采用的识别方法，都失败时为 Failed
+	Method      string
+	InnerId     string
+	DesktopFile string
+}
+
+// WindowIdentifyInputs 是识别窗口时用到的窗口和进程的信息
+type WindowIdentifyInputs struct {
+	// 根据窗口属性计算的 innerId，为空时不会进行识别
+	InnerId string
+	Pid     uint
+	Exe     string
+	Cmdline []string
+	// 只包括 GIO_LAUNCHED_DESKTOP_FILE 等识别方法和规则用到的环境变量
+	Env             map[string]string
+	WmClassInstance string
+	WmClassClass    string
+	WmName          string
+	WmRole          string
+	GtkAppId        string
+	FlatpakAppId    string
+	// KWayland 窗口的 appId
+	AppId string `json:",omitempty"`
+}
+
+type WindowIdentifyStep struct {
+	Method      string
+	Success     bool
+	Selected    bool
+	InnerId     string `json:",omitempty"`
+	DesktopFile string `json:",omitempty"`
+	// Rule 方法匹配的规则
+	MatchedRule *MatchedWindowRule `json:",omitempty"`
+}
+
+type MatchedWindowRule struct {
+	Source string
+	Index  int
+	Rules  []WindowRule
+	Result string
+}
+
+var explainEnvNames = []string{
+	"GIO_LAUNCHED_DESKTOP_FILE",
+	"GIO_LAUNCHED_DESKTOP_FILE_PID",
+}
+
+// envNames 返回规则中用到的环境变量
+func (patterns WindowPatterns) envNames() []string {
+	var result []string
+	for i := range patterns {
+		for _, rule := range patterns[i].ParsedRules {
+			if strings.HasPrefix(rule.Key, ruleKeyEnvPrefix) {
+				result = append(result, rule.Key[len(ruleKeyEnvPrefix):])
+			}
+		}
+	}
+	return result
+}
+
+func (m *Manager) getIdentifyInputs(winInfo *XWindowInfo) *WindowIdentifyInputs {
+	inputs := &WindowIdentifyInputs{
+		InnerId:      winInfo.innerId,
+		Pid:          winInfo.pid,
+		WmName:       winInfo.wmName,
+		WmRole:       winInfo.wmRole,
+		GtkAppId:     winInfo.gtkAppId,
+		FlatpakAppId: winInfo.flatpakAppID,
+	}
+	if winInfo.wmClass != nil {
+		inputs.WmClassInstance = winInfo.wmClass.Instance
+		inputs.WmClassClass = winInfo.wmClass.Class
+	}
+
+	if process := winInfo.process; process != nil {
+		inputs.Exe = process.exe
+		inputs.Cmdline = process.cmdline
+		inputs.Env = make(map[string]string)
+		for _, names := range [][]string{explainEnvNames, m.getWindowPatterns().envNames()} {
+			for _, name := range names {
+				if value := process.environ.Get(name); value != "" {
+					inputs.Env[name] = value
+				}
+			}
+		}
+	}
+	return inputs
+}
+
+func (m *Manager) explainWindowIdentifyX(winInfo *XWindowInfo) *WindowIdentifyExplanation {
+	result := &WindowIdentifyExplanation{
+		Window: uint32(winInfo.xid),
+		Inputs: m.getIdentifyInputs(winInfo),
+		Method: "Failed",
+	}
+	if winInfo.innerId == "" {
+		return result
+	}
+
+	var selected *WindowIdentifyStep
+	var selectedAppInfo *AppInfo
+	for _, item := range m.identifyWindowFuns {
+		step := &WindowIdentifyStep{Method: item.Name}
+		innerId, appInfo := item.Fn(m, winInfo)
+		if innerId != "" {
+			step.Success = true
+			step.InnerId = innerId
+			if appInfo != nil {
+				step.DesktopFile = appInfo.GetFileName()
+			}
+			if selected == nil {
+				selected = step
+				selectedAppInfo = appInfo
+			}
+		}
+
+		if item.Name == "Rule" {
+			pattern := m.getWindowPatterns().MatchPattern(winInfo)
+			if pattern != nil {
+				step.MatchedRule = &MatchedWindowRule{
+					Source: pattern.source,
+					Index:  pattern.index,
+					Rules:  pattern.Rules,
+					Result: pattern.Result,
+				}
+			}
+		}
+		result.Steps = append(result.Steps, step)
+	}
+
+	if selected == nil {
+		result.InnerId = winInfo.innerId
+		return result
+	}
+	selected.Selected = true
+	result.Method = selected.Method
+	result.InnerId = selected.InnerId
+	result.DesktopFile = selected.DesktopFile
+	// 和 fixIdentifyResult 相同，但是不修改 appInfo，Pid 方法返回的是 entry 的 appInfo。
+	if selectedAppInfo != nil {
+		fixedAppInfo := fixAutostartAppInfo(selectedAppInfo)
+		if fixedAppInfo != nil {
+			result.Method += "+FixAutostart"
+			result.InnerId = fixedAppInfo.innerId
+			result.DesktopFile = fixedAppInfo.GetFileName()
+		}
+	}
+	return result
+}
+
+func (m *Manager) explainWindowIdentifyK(winInfo *KWindowInfo) *WindowIdentifyExplanation {
+	result := &WindowIdentifyExplanation{
+		Window: uint32(winInfo.xid),
+		Inputs: &WindowIdentifyInputs{
+			InnerId: winInfo.innerId,
+			Pid:     winInfo.pid,
+			AppId:   winInfo.appId,
+		},
+		Method: "Failed",
+	}
+	step := &WindowIdentifyStep{Method: "AppId"}
+	result.Steps = append(result.Steps, step)
+
+	innerId, appInfo := m.identifyWindowK(winInfo)
+	if appInfo == nil {
+		return result
+	}
+	step.Success = true
+	step.Selected = true
+	step.InnerId = innerId
+	step.DesktopFile = appInfo.GetFileName()
+	result.Method = step.Method
+	if appInfo.identifyMethod != "" {
+		result.Method = appInfo.identifyMethod
+	}
+	result.InnerId = innerId
+	result.DesktopFile = step.DesktopFile
+	return result
+}
+
+// ExplainWindowIdentify 重新识别窗口 win，返回尝试的所有识别方法，用到的输入和结果的 JSON。
+func (m *Manager) ExplainWindowIdentify(win uint32) (string, *dbus.Error) {
+	var explanation *WindowIdentifyExplanation
+	if winInfo := m.findXWindowInfo(x.Window(win)); winInfo != nil {
+		explanation = m.explainWindowIdentifyX(winInfo)
+	} else if winInfo, ok := m.findWindowByXidK(x.Window(win)).(*KWindowInfo); ok {
+		explanation = m.explainWindowIdentifyK(winInfo)
+	} else {
+		return "", dbusutil.ToError(fmt.Errorf("window %d not found", win))
+	}
+
+	data, err := json.Marshal(explanation)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -36,6 +36,10 @@ type WindowPattern struct {
 	Rules       []WindowRule `json:"rules"`
 	Result      string       `json:"ret"`
 	ParsedRules []*WindowRuleParsed
+
+	// 规则所在的文件和在文件中的序号
+	source string
+	index  int
 }
 
 type WindowRule [2]string
@@ -67,6 +71,8 @@ func loadWindowPatterns(file string) (WindowPatterns, error) {
 	// parse pattterns
 	for i := range patterns {
 		pattern := &patterns[i]
+		pattern.source = file
+		pattern.index = i
 		rules := pattern.Rules
 		// parse rules in pattern
 		pattern.ParsedRules = make([]*WindowRuleParsed, len(rules))
@@ -79,7 +85,36 @@ func loadWindowPatterns(file string) (WindowPatterns, error) {
 	return patterns, nil
 }
 
+// loadWindowPatternsFromDir 按文件名顺序加载 dir 中所有的 .json 规则文件
+func loadWindowPatternsFromDir(dir string) WindowPatterns {
+	fileInfoList, _ := ioutil.ReadDir(dir)
+	var result WindowPatterns
+	for _, fileInfo := range fileInfoList {
+		if fileInfo.IsDir() || !strings.HasSuffix(fileInfo.Name(), ".json") {
+			continue
+		}
+
+		file := filepath.Join(dir, fileInfo.Name())
+		patterns, err := loadWindowPatterns(file)
+		if err != nil {
+			logger.Warningf("failed to load window patterns from %q: %v", file, err)
+			continue
+		}
+		result = append(result, patterns...)
+	}
+	return result
+}
+
 func (patterns WindowPatterns) Match(winInfo *XWindowInfo) string {
+	pattern := patterns.MatchPattern(winInfo)
+	if pattern == nil {
+		return ""
+	}
+	return pattern.Result
+}
+
+// MatchPattern 返回第一个匹配窗口的规则，没有匹配的返回 nil。
+func (patterns WindowPatterns) MatchPattern(winInfo *XWindowInfo) *WindowPattern {
 	for i := range patterns {
 		pattern := &patterns[i]
 		rules := pattern.ParsedRules
@@ -99,13 +134,15 @@ func (patterns WindowPatterns) Match(winInfo *XWindowInfo) string {
 		if patternOk {
 			// pattern match success
 			logger.Debugf("pattern match success")
-			return pattern.Result
+			return pattern
 		}
 	}
 	// fail
-	return ""
+	return nil
 }
 
+const ruleKeyEnvPrefix = "env."
+
 func parseRuleKey(winInfo *XWindowInfo, key string) string {
 	switch key {
 	case "hasPid":
@@ -145,9 +182,8 @@ func parseRuleKey(winInfo *XWindowInfo, key string) string {
 		return winInfo.wmRole
 
 	default:
-		const envPrefix = "env."
-		if strings.HasPrefix(key, envPrefix) {
-			envName := key[len(envPrefix):]
+		if strings.HasPrefix(key, ruleKeyEnvPrefix) {
+			envName := key[len(ruleKeyEnvPrefix):]
 			if winInfo.process != nil {
 				return winInfo.process.environ.Get(envName)
 			}
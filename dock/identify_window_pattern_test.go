@@ -0,0 +1,67 @@
+package dock
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/linuxdeepin/go-x11-client/util/wm/icccm"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_loadWindowPatternsFromDir(t *testing.T) {
+	Convey("loadWindowPatternsFromDir", t, func(c C) {
+		dir := "testdata/window_patterns.d"
+		patterns := loadWindowPatternsFromDir(dir)
+		c.So(patterns, ShouldHaveLength, 3)
+		c.So(patterns[0].Result, ShouldEqual, "id=company-chat")
+		c.So(patterns[0].source, ShouldEqual, filepath.Join(dir, "10-electron.json"))
+		c.So(patterns[1].index, ShouldEqual, 1)
+		c.So(patterns[2].Result, ShouldEqual, "id=build-tool")
+		c.So(patterns[2].source, ShouldEqual, filepath.Join(dir, "20-java.json"))
+		c.So(patterns[2].index, ShouldEqual, 0)
+		c.So(patterns.envNames(), ShouldResemble, []string{"CHAT_PROFILE"})
+
+		c.So(loadWindowPatternsFromDir("testdata/not-exist"), ShouldBeEmpty)
+	})
+}
+
+func Test_WindowPatternsMatchPattern(t *testing.T) {
+	Convey("WindowPatterns MatchPattern", t, func(c C) {
+		userPatterns := loadWindowPatternsFromDir("testdata/window_patterns.d")
+		systemPatterns := WindowPatterns{
+			{
+				Rules:  []WindowRule{{"wmc", "=:sun-awt-X11-XFramePeer"}},
+				Result: "id=java",
+			},
+		}
+		for i := range systemPatterns {
+			pattern := &systemPatterns[i]
+			for j := range pattern.Rules {
+				pattern.ParsedRules = append(pattern.ParsedRules, pattern.Rules[j].Parse())
+			}
+		}
+		patterns := append(userPatterns, systemPatterns...)
+
+		winInfo := &XWindowInfo{
+			wmClass: &icccm.WMClass{Instance: "build-tool", Class: "sun-awt-X11-XFramePeer"},
+		}
+		// 用户的规则优先
+		pattern := patterns.MatchPattern(winInfo)
+		c.So(pattern, ShouldNotBeNil)
+		c.So(pattern.Result, ShouldEqual, "id=build-tool")
+
+		winInfo.wmClass.Instance = "other"
+		c.So(patterns.Match(winInfo), ShouldEqual, "id=java")
+
+		winInfo.wmClass = nil
+		winInfo.process = &ProcessInfo{
+			exe:    "/usr/lib/electron/electron",
+			args:   []string{"/opt/chat/resources/app.asar"},
+			hasPid: true,
+		}
+		c.So(patterns.Match(winInfo), ShouldEqual, "id=company-chat")
+
+		winInfo.process.args = nil
+		c.So(patterns.MatchPattern(winInfo), ShouldBeNil)
+	})
+}
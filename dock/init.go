@@ -39,6 +39,11 @@ var (
 	scratchDir  string
 	dockManager *Manager
 
+	// 用户的窗口识别规则目录
+	userWindowPatternsDir string
+	profilesFile          string
+	entryGroupingFile     string
+
 	globalXConn *x.Conn
 
 	atomNetShowingDesktop       x.Atom
@@ -79,6 +84,9 @@ func initDir() {
 	homeDir = basedir.GetUserHomeDir()
 	scratchDir = filepath.Join(basedir.GetUserConfigDir(), "dock/scratch")
 	logger.Debugf("scratch dir: %q", scratchDir)
+	userWindowPatternsDir = filepath.Join(basedir.GetUserConfigDir(), "dock/window_patterns.d")
+	profilesFile = filepath.Join(basedir.GetUserConfigDir(), "dock/profiles.json")
+	entryGroupingFile = filepath.Join(basedir.GetUserConfigDir(), "dock/entry_grouping.json")
 }
 
 func initAtom() {
@@ -0,0 +1,198 @@
+package dock
+
+import (
+	"path/filepath"
+	"strings"
+
+	ofdbus "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.dbus"
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// 实现 Unity 的 LauncherEntry 协议，应用发送 com.canonical.Unity.LauncherEntry.Update 信号
+// 设置任务栏上图标的计数，进度和紧急状态，应用退出时清除。
+
+const (
+	launcherEntryInterface = "com.canonical.Unity.LauncherEntry"
+	launcherEntryUpdate    = "Update"
+	launcherEntryUriPrefix = "application://"
+)
+
+type launcherEntryState struct {
+	count           int64
+	countVisible    bool
+	progress        float64
+	progressVisible bool
+	urgent          bool
+	// 最后一次发送 Update 的连接
+	sender string
+}
+
+// update 更新 props 中的属性，Update 信号只包含变化的属性。
+func (s *launcherEntryState) update(props map[string]dbus.Variant) {
+	for key, variant := range props {
+		value := variant.Value()
+		switch key {
+		case "count":
+			if count, ok := toInt64(value); ok {
+				s.count = count
+			}
+		case "count-visible":
+			if v, ok := value.(bool); ok {
+				s.countVisible = v
+			}
+		case "progress":
+			if v, ok := value.(float64); ok {
+				s.progress = v
+			}
+		case "progress-visible":
+			if v, ok := value.(bool); ok {
+				s.progressVisible = v
+			}
+		case "urgent":
+			if v, ok := value.(bool); ok {
+				s.urgent = v
+			}
+		}
+	}
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// getLauncherEntryAppId 把 application://firefox.desktop 转换为 firefox
+func getLauncherEntryAppId(uri string) string {
+	if !strings.HasPrefix(uri, launcherEntryUriPrefix) {
+		return ""
+	}
+	return trimDesktopExt(uri[len(launcherEntryUriPrefix):])
+}
+
+func (entry *AppEntry) getLauncherEntryAppId() string {
+	if entry.appInfo == nil {
+		return ""
+	}
+	return trimDesktopExt(filepath.Base(entry.appInfo.GetFileName()))
+}
+
+func (entry *AppEntry) setLauncherEntryState(state *launcherEntryState) {
+	entry.setPropCount(state.count)
+	entry.setPropCountVisible(state.countVisible)
+	entry.setPropProgress(state.progress)
+	entry.setPropProgressVisible(state.progressVisible)
+	entry.setPropUrgent(state.urgent)
+}
+
+func (m *Manager) listenLauncherEntrySignals() {
+	err := dbusutil.NewMatchRuleBuilder().
+		Type("signal").
+		Interface(launcherEntryInterface).
+		Member(launcherEntryUpdate).Build().
+		AddTo(m.sessionSigLoop.Conn())
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	m.sessionSigLoop.AddHandler(&dbusutil.SignalRule{
+		Name: launcherEntryInterface + "." + launcherEntryUpdate,
+	}, m.handleLauncherEntryUpdate)
+
+	m.dbusDaemon = ofdbus.NewDBus(m.sessionSigLoop.Conn())
+	m.dbusDaemon.InitSignalExt(m.sessionSigLoop, true)
+	_, err = m.dbusDaemon.ConnectNameOwnerChanged(func(name, oldOwner, newOwner string) {
+		if newOwner == "" && strings.HasPrefix(name, ":") {
+			m.removeLauncherEntrySender(name)
+		}
+	})
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+func (m *Manager) handleLauncherEntryUpdate(sig *dbus.Signal) {
+	if len(sig.Body) != 2 {
+		return
+	}
+	uri, ok := sig.Body[0].(string)
+	if !ok {
+		return
+	}
+	props, ok := sig.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return
+	}
+	appId := getLauncherEntryAppId(uri)
+	if appId == "" {
+		logger.Debugf("invalid launcher entry uri %q", uri)
+		return
+	}
+	logger.Debugf("launcher entry update %q: %v", appId, props)
+
+	m.launcherEntriesMu.Lock()
+	state := m.launcherEntries[appId]
+	if state == nil {
+		state = &launcherEntryState{}
+		m.launcherEntries[appId] = state
+	}
+	state.update(props)
+	state.sender = sig.Sender
+	stateCopy := *state
+	m.launcherEntriesMu.Unlock()
+
+	m.updateEntriesLauncherState(appId, &stateCopy)
+}
+
+// removeLauncherEntrySender 在应用退出时清除它设置的状态
+func (m *Manager) removeLauncherEntrySender(sender string) {
+	var appIds []string
+	m.launcherEntriesMu.Lock()
+	for appId, state := range m.launcherEntries {
+		if state.sender == sender {
+			delete(m.launcherEntries, appId)
+			appIds = append(appIds, appId)
+		}
+	}
+	m.launcherEntriesMu.Unlock()
+
+	for _, appId := range appIds {
+		m.updateEntriesLauncherState(appId, &launcherEntryState{})
+	}
+}
+
+func (m *Manager) getLauncherEntryState(appId string) *launcherEntryState {
+	if appId == "" {
+		return nil
+	}
+	m.launcherEntriesMu.Lock()
+	defer m.launcherEntriesMu.Unlock()
+	state := m.launcherEntries[appId]
+	if state == nil {
+		return nil
+	}
+	stateCopy := *state
+	return &stateCopy
+}
+
+func (m *Manager) updateEntriesLauncherState(appId string, state *launcherEntryState) {
+	m.Entries.mu.RLock()
+	defer m.Entries.mu.RUnlock()
+	for _, entry := range m.Entries.items {
+		entry.PropsMu.Lock()
+		if entry.getLauncherEntryAppId() == appId {
+			entry.setLauncherEntryState(state)
+		}
+		entry.PropsMu.Unlock()
+	}
+}
@@ -0,0 +1,42 @@
+package dock
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"pkg.deepin.io/lib/dbus1"
+)
+
+func Test_getLauncherEntryAppId(t *testing.T) {
+	Convey("getLauncherEntryAppId", t, func(c C) {
+		c.So(getLauncherEntryAppId("application://firefox.desktop"), ShouldEqual, "firefox")
+		c.So(getLauncherEntryAppId("application://org.gnome.Nautilus"), ShouldEqual, "org.gnome.Nautilus")
+		c.So(getLauncherEntryAppId("firefox.desktop"), ShouldEqual, "")
+	})
+}
+
+func Test_launcherEntryStateUpdate(t *testing.T) {
+	Convey("launcherEntryState update", t, func(c C) {
+		var s launcherEntryState
+		s.update(map[string]dbus.Variant{
+			"count":         dbus.MakeVariant(int64(3)),
+			"count-visible": dbus.MakeVariant(true),
+			"progress":      dbus.MakeVariant(0.5),
+		})
+		c.So(s.count, ShouldEqual, 3)
+		c.So(s.countVisible, ShouldBeTrue)
+		c.So(s.progress, ShouldEqual, 0.5)
+		c.So(s.progressVisible, ShouldBeFalse)
+
+		// 只更新包含的属性
+		s.update(map[string]dbus.Variant{
+			"count":  dbus.MakeVariant(int32(5)),
+			"urgent": dbus.MakeVariant(true),
+			"other":  dbus.MakeVariant("x"),
+		})
+		c.So(s.count, ShouldEqual, 5)
+		c.So(s.countVisible, ShouldBeTrue)
+		c.So(s.progress, ShouldEqual, 0.5)
+		c.So(s.urgent, ShouldBeTrue)
+	})
+}
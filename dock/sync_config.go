@@ -2,7 +2,6 @@ package dock
 
 import (
 	"encoding/json"
-	"os"
 )
 
 type syncConfig struct {
@@ -42,30 +41,7 @@ func (sc *syncConfig) setPluginSettings(settings pluginSettings) {
 }
 
 func (sc *syncConfig) setDockedApps(dockedApps []string) {
-	m := sc.m
-	added, removed := diffStrSlice(m.DockedApps.Get(), dockedApps)
-
-	for _, value := range added {
-		desktopFile := unzipDesktopPath(value)
-		_, err := os.Stat(desktopFile)
-		if err == nil {
-			_, err = m.requestDock(desktopFile, -1)
-			if err != nil {
-				logger.Warning(err)
-			}
-		}
-	}
-
-	for _, value := range removed {
-		desktopFile := unzipDesktopPath(value)
-
-		_, err := m.requestUndock(desktopFile)
-		if err != nil {
-			logger.Warning(err)
-		}
-	}
-
-	m.DockedApps.Set(dockedApps)
+	sc.m.setDockedApps(dockedApps)
 }
 
 func (sc *syncConfig) Set(data []byte) error {
@@ -26,6 +26,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -136,3 +137,42 @@ func toLocalPath(in string) string {
 	}
 	return in
 }
+
+// reorderSlots 返回按照 order 重新排列 keys 后每个位置上的原序号，
+// keys 中为空的位置不变，其余位置按照在 order 中的顺序排列，不在 order 中的排在最后。
+func reorderSlots(keys, order []string) []int {
+	rank := make(map[string]int, len(order))
+	for i, key := range order {
+		if _, ok := rank[key]; !ok {
+			rank[key] = i
+		}
+	}
+	getRank := func(idx int) int {
+		r, ok := rank[keys[idx]]
+		if !ok {
+			return len(order)
+		}
+		return r
+	}
+
+	var slots []int
+	for i, key := range keys {
+		if key != "" {
+			slots = append(slots, i)
+		}
+	}
+	sorted := make([]int, len(slots))
+	copy(sorted, slots)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return getRank(sorted[a]) < getRank(sorted[b])
+	})
+
+	result := make([]int, len(keys))
+	for i := range result {
+		result[i] = i
+	}
+	for i, slot := range slots {
+		result[slot] = sorted[i]
+	}
+	return result
+}
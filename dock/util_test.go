@@ -57,3 +57,14 @@ func Test_strSliceContains(t *testing.T) {
 	})
 
 }
+
+func Test_reorderSlots(t *testing.T) {
+	Convey("reorderSlots", t, func(c C) {
+		keys := []string{"a", "", "b", "c", ""}
+		c.So(reorderSlots(keys, []string{"c", "a", "b"}), ShouldResemble, []int{3, 1, 0, 2, 4})
+		// 不在 order 中的排在最后
+		c.So(reorderSlots(keys, []string{"b"}), ShouldResemble, []int{2, 1, 0, 3, 4})
+		c.So(reorderSlots(keys, nil), ShouldResemble, []int{0, 1, 2, 3, 4})
+		c.So(reorderSlots(nil, []string{"a"}), ShouldBeEmpty)
+	})
+}
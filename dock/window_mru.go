@@ -0,0 +1,237 @@
+package dock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// 记录窗口获得焦点的顺序（MRU），entry 中窗口的顺序就是它们在全局顺序中的先后。
+
+// 连续切换窗口的间隔超过这个时间时结束切换
+const mruCycleTimeout = 1500 * time.Millisecond
+
+// windowMRU 是窗口获得焦点的顺序，最近的在前。
+type windowMRU struct {
+	windows []x.Window
+	cycle   *mruCycle
+}
+
+// mruCycle 是连续切换一个 entry 中窗口的过程，切换期间顺序不变，结束时才把最后切换到的窗口移到最前。
+type mruCycle struct {
+	entryId string
+	windows []x.Window
+	index   int
+}
+
+func (h *windowMRU) indexOf(win x.Window) int {
+	for i, w := range h.windows {
+		if w == win {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *windowMRU) touch(win x.Window) {
+	h.remove(win)
+	h.windows = append([]x.Window{win}, h.windows...)
+}
+
+func (h *windowMRU) remove(win x.Window) {
+	if idx := h.indexOf(win); idx != -1 {
+		h.windows = append(h.windows[:idx], h.windows[idx+1:]...)
+	}
+	if c := h.cycle; c != nil {
+		for _, w := range c.windows {
+			if w == win {
+				h.cycle = nil
+				break
+			}
+		}
+	}
+}
+
+// sort 按照获得焦点的顺序排列 wins，没有获得过焦点的按照 id 排在最后。
+func (h *windowMRU) sort(wins []x.Window) []x.Window {
+	result := make([]x.Window, len(wins))
+	copy(result, wins)
+	rank := func(win x.Window) int {
+		idx := h.indexOf(win)
+		if idx == -1 {
+			return len(h.windows)
+		}
+		return idx
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ri, rj := rank(result[i]), rank(result[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return result[i] < result[j]
+	})
+	return result
+}
+
+// handleActive 记录活动窗口，切换过程中切换到的窗口不改变顺序。
+func (h *windowMRU) handleActive(win x.Window) {
+	if c := h.cycle; c != nil {
+		if c.windows[c.index] == win {
+			return
+		}
+		h.endCycle()
+	}
+	h.touch(win)
+}
+
+func (h *windowMRU) endCycle() {
+	c := h.cycle
+	if c == nil {
+		return
+	}
+	h.cycle = nil
+	h.touch(c.windows[c.index])
+}
+
+// step 返回在 entry 的窗口 wins 中向后（step 为 1）或者向前（step 为 -1）切换到的窗口，
+// active 是当前的活动窗口，不属于 entry 时切换到 entry 中最近获得焦点的窗口。
+func (h *windowMRU) step(entryId string, wins []x.Window, active x.Window, step int) (x.Window, bool) {
+	c := h.cycle
+	if c == nil || c.entryId != entryId {
+		h.endCycle()
+		sorted := h.sort(wins)
+		if len(sorted) == 0 {
+			return 0, false
+		}
+		c = &mruCycle{
+			entryId: entryId,
+			windows: sorted,
+		}
+		h.cycle = c
+		if sorted[0] != active {
+			return sorted[0], true
+		}
+	}
+
+	n := len(c.windows)
+	c.index = ((c.index+step)%n + n) % n
+	return c.windows[c.index], true
+}
+
+func (m *Manager) handleWindowMRUActive(win x.Window) {
+	m.windowMRUMu.Lock()
+	m.windowMRU.handleActive(win)
+	m.windowMRUMu.Unlock()
+}
+
+func (m *Manager) removeWindowMRU(win x.Window) {
+	m.windowMRUMu.Lock()
+	m.windowMRU.remove(win)
+	m.windowMRUMu.Unlock()
+}
+
+func (m *Manager) getEntryOrActive(entryId string) (*AppEntry, error) {
+	if entryId != "" {
+		entry := m.Entries.getById(entryId)
+		if entry == nil {
+			return nil, fmt.Errorf("entry %q not found", entryId)
+		}
+		return entry, nil
+	}
+
+	activeWin := m.getActiveWindow()
+	if activeWin == nil {
+		return nil, errors.New("no active window")
+	}
+	entry := m.Entries.getByWindowId(activeWin.getXid())
+	if entry == nil {
+		return nil, errors.New("active window is not on dock")
+	}
+	return entry, nil
+}
+
+func (m *Manager) activateWindowMRU(entryId string, step int) error {
+	entry, err := m.getEntryOrActive(entryId)
+	if err != nil {
+		return err
+	}
+
+	entry.PropsMu.RLock()
+	windows := make(map[x.Window]WindowInfo, len(entry.windows))
+	wins := make([]x.Window, 0, len(entry.windows))
+	for win, winInfo := range entry.windows {
+		windows[win] = winInfo
+		wins = append(wins, win)
+	}
+	entry.PropsMu.RUnlock()
+
+	var active x.Window
+	if activeWin := m.getActiveWindow(); activeWin != nil {
+		active = activeWin.getXid()
+	}
+
+	m.windowMRUMu.Lock()
+	win, ok := m.windowMRU.step(entry.Id, wins, active, step)
+	if ok {
+		if m.mruCycleTimer == nil {
+			m.mruCycleTimer = time.AfterFunc(mruCycleTimeout, func() {
+				m.windowMRUMu.Lock()
+				m.windowMRU.endCycle()
+				m.windowMRUMu.Unlock()
+			})
+		} else {
+			m.mruCycleTimer.Reset(mruCycleTimeout)
+		}
+	}
+	m.windowMRUMu.Unlock()
+	if !ok {
+		return errors.New("entry has no window")
+	}
+	return windows[win].activate()
+}
+
+// GetWindowsMRU 返回 entry 中按照获得焦点的顺序排列的窗口，entryId 为空时返回所有窗口。
+func (m *Manager) GetWindowsMRU(entryId string) ([]uint32, *dbus.Error) {
+	var wins []x.Window
+	if entryId == "" {
+		m.windowMRUMu.Lock()
+		wins = append(wins, m.windowMRU.windows...)
+		m.windowMRUMu.Unlock()
+	} else {
+		entry := m.Entries.getById(entryId)
+		if entry == nil {
+			return nil, dbusutil.ToError(fmt.Errorf("entry %q not found", entryId))
+		}
+		entry.PropsMu.RLock()
+		for win := range entry.windows {
+			wins = append(wins, win)
+		}
+		entry.PropsMu.RUnlock()
+
+		m.windowMRUMu.Lock()
+		wins = m.windowMRU.sort(wins)
+		m.windowMRUMu.Unlock()
+	}
+
+	result := make([]uint32, len(wins))
+	for i, win := range wins {
+		result[i] = uint32(win)
+	}
+	return result, nil
+}
+
+// ActivateNextWindow 切换到 entry 中下一个最近获得焦点的窗口，entryId 为空时使用活动窗口所在的 entry。
+// 连续调用时依次切换所有窗口。
+func (m *Manager) ActivateNextWindow(entryId string) *dbus.Error {
+	return dbusutil.ToError(m.activateWindowMRU(entryId, 1))
+}
+
+// ActivatePrevWindow 和 ActivateNextWindow 的方向相反
+func (m *Manager) ActivatePrevWindow(entryId string) *dbus.Error {
+	return dbusutil.ToError(m.activateWindowMRU(entryId, -1))
+}
@@ -0,0 +1,61 @@
+package dock
+
+import (
+	"testing"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_windowMRU(t *testing.T) {
+	Convey("windowMRU", t, func(c C) {
+		var h windowMRU
+		h.handleActive(1)
+		h.handleActive(2)
+		h.handleActive(3)
+		h.handleActive(1)
+		c.So(h.windows, ShouldResemble, []x.Window{1, 3, 2})
+		c.So(h.sort([]x.Window{5, 2, 4, 3}), ShouldResemble, []x.Window{3, 2, 4, 5})
+
+		h.remove(3)
+		c.So(h.windows, ShouldResemble, []x.Window{1, 2})
+	})
+
+	Convey("windowMRU step", t, func(c C) {
+		var h windowMRU
+		h.handleActive(3)
+		h.handleActive(2)
+		h.handleActive(1)
+		wins := []x.Window{1, 2, 3}
+
+		// 当前窗口是 1，依次切换到 2，3，1
+		win, ok := h.step("e1", wins, 1, 1)
+		c.So(ok, ShouldBeTrue)
+		c.So(win, ShouldEqual, 2)
+		h.handleActive(win)
+		win, _ = h.step("e1", wins, win, 1)
+		c.So(win, ShouldEqual, 3)
+		h.handleActive(win)
+		c.So(h.windows, ShouldResemble, []x.Window{1, 2, 3})
+
+		win, _ = h.step("e1", wins, win, -1)
+		c.So(win, ShouldEqual, 2)
+		h.endCycle()
+		c.So(h.windows, ShouldResemble, []x.Window{2, 1, 3})
+
+		// 切换期间激活了其他窗口
+		win, _ = h.step("e1", wins, 2, 1)
+		c.So(win, ShouldEqual, 1)
+		h.handleActive(3)
+		c.So(h.cycle, ShouldBeNil)
+		c.So(h.windows, ShouldResemble, []x.Window{3, 1, 2})
+
+		// 当前窗口不属于 entry 时切换到最近的窗口
+		win, _ = h.step("e2", []x.Window{5, 2}, 3, 1)
+		c.So(win, ShouldEqual, 2)
+		h.endCycle()
+
+		_, ok = h.step("e3", nil, 3, 1)
+		c.So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,86 @@
+package dock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pkg.deepin.io/lib/fsnotify"
+)
+
+// 用户的窗口识别规则放在 userWindowPatternsDir 中，和系统的规则格式相同，
+// 优先于系统的规则匹配，修改后自动重新加载，对之后识别的窗口生效。
+
+const windowPatternsReloadDelay = 500 * time.Millisecond
+
+func (m *Manager) loadWindowPatterns() {
+	patterns := loadWindowPatternsFromDir(userWindowPatternsDir)
+	userCount := len(patterns)
+	systemPatterns, err := loadWindowPatterns(windowPatternsFile)
+	if err != nil {
+		logger.Warning("loadWindowPatterns failed:", err)
+	}
+	patterns = append(patterns, systemPatterns...)
+	logger.Debugf("load window patterns, user: %d, system: %d", userCount, len(systemPatterns))
+
+	m.windowPatternsMu.Lock()
+	m.windowPatterns = patterns
+	m.windowPatternsMu.Unlock()
+}
+
+func (m *Manager) getWindowPatterns() WindowPatterns {
+	m.windowPatternsMu.RLock()
+	defer m.windowPatternsMu.RUnlock()
+	return m.windowPatterns
+}
+
+func (m *Manager) watchWindowPatterns() error {
+	err := os.MkdirAll(userWindowPatternsDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	err = watcher.Watch(userWindowPatternsDir)
+	if err != nil {
+		_ = watcher.Close()
+		return err
+	}
+	m.windowPatternsWatcher = watcher
+	go m.windowPatternsEventLoop(watcher)
+	return nil
+}
+
+func (m *Manager) windowPatternsEventLoop(watcher *fsnotify.Watcher) {
+	var timer *time.Timer
+	for {
+		select {
+		case ev, ok := <-watcher.Event:
+			if !ok {
+				return
+			}
+			if filepath.Dir(ev.Name) != userWindowPatternsDir ||
+				!strings.HasSuffix(ev.Name, ".json") {
+				continue
+			}
+			logger.Debug("window patterns changed:", ev)
+
+			// 编辑器保存文件时会产生多个事件，合并成一次重新加载
+			if timer == nil {
+				timer = time.AfterFunc(windowPatternsReloadDelay, m.loadWindowPatterns)
+			} else {
+				timer.Reset(windowPatternsReloadDelay)
+			}
+
+		case err, ok := <-watcher.Error:
+			if !ok {
+				return
+			}
+			logger.Warning("window patterns watcher error:", err)
+		}
+	}
+}
@@ -141,6 +141,7 @@ func (m *Manager) unregisterWindowWayland(objPath dbus.ObjectPath) {
 
 	winInfo.winObj.RemoveAllHandlers()
 	m.detachWindow(winInfo)
+	m.removeWindowMRU(winInfo.xid)
 
 	err := globalXConn.FreeID(uint32(winInfo.xid))
 	if err != nil {
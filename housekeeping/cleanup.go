@@ -0,0 +1,202 @@
+package housekeeping
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+// 可以清理的类别
+const (
+	CategoryThumbnails = "thumbnails"
+	CategoryTrash      = "trash"
+	CategoryCache      = "cache"
+	CategoryLogs       = "logs"
+	CategoryPackages   = "packages"
+)
+
+var allCategories = []string{
+	CategoryThumbnails,
+	CategoryTrash,
+	CategoryCache,
+	CategoryLogs,
+	CategoryPackages,
+}
+
+// 提醒中的“清理”按钮只清理这些类别，回收站和 ~/.cache 中可能有用户或者正在运行的程序需要的文件，
+// 只通过 Clean 方法在用户查看 GetCleanupCandidates 的结果后清理
+var quickCleanCategories = []string{
+	CategoryThumbnails,
+	CategoryLogs,
+	CategoryPackages,
+}
+
+// CleanupCandidate 是一个类别可以清理的大小
+type CleanupCandidate struct {
+	Category  string
+	Size      uint64
+	FileCount int
+}
+
+type cleaner struct {
+	homeDir        string
+	cacheDir       string
+	dataDir        string
+	aptArchivesDir string
+	// 软件包缓存需要 root 权限才能删除，交给 lastore 清理，清理完成后返回
+	cleanPackages func() error
+}
+
+func newCleaner() *cleaner {
+	return &cleaner{
+		homeDir:        basedir.GetUserHomeDir(),
+		cacheDir:       basedir.GetUserCacheDir(),
+		dataDir:        basedir.GetUserDataDir(),
+		aptArchivesDir: "/var/cache/apt/archives",
+		cleanPackages:  cleanAptArchives,
+	}
+}
+
+// cache 类别中不清理的目录，thumbnails 单独清理，deepin 中有 dde-daemon 自己的数据
+var cacheExcludes = []string{"thumbnails", "deepin"}
+
+// getPaths 返回类别 category 要清理的文件和目录
+func (c *cleaner) getPaths(category string) ([]string, error) {
+	switch category {
+	case CategoryThumbnails:
+		return globAll(filepath.Join(c.cacheDir, "thumbnails/*"))
+
+	case CategoryTrash:
+		trashDir := filepath.Join(c.dataDir, "Trash")
+		return globAll(filepath.Join(trashDir, "files/*"),
+			filepath.Join(trashDir, "info/*"),
+			filepath.Join(trashDir, "expunged/*"))
+
+	case CategoryCache:
+		paths, err := globAll(filepath.Join(c.cacheDir, "*"))
+		if err != nil {
+			return nil, err
+		}
+		var result []string
+		for _, path := range paths {
+			if isStrInList(filepath.Base(path), cacheExcludes) {
+				continue
+			}
+			result = append(result, path)
+		}
+		return result, nil
+
+	case CategoryLogs:
+		return globAll(filepath.Join(c.homeDir, ".xsession-errors.old"),
+			filepath.Join(c.dataDir, "xorg/*.old"),
+			filepath.Join(c.dataDir, "xorg/*.log.[0-9]*"))
+
+	case CategoryPackages:
+		return globAll(filepath.Join(c.aptArchivesDir, "*.deb"))
+	}
+	return nil, fmt.Errorf("unknown category %q", category)
+}
+
+func globAll(patterns ...string) ([]string, error) {
+	var result []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, matches...)
+	}
+	return result, nil
+}
+
+func isStrInList(item string, list []string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// getDiskUsage 返回 paths 中文件的总大小和数量，不跟随符号链接。
+func getDiskUsage(paths []string) (uint64, int) {
+	var size uint64
+	var count int
+	for _, path := range paths {
+		_ = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				// 忽略没有权限等错误
+				return nil
+			}
+			if !info.IsDir() {
+				size += uint64(info.Size())
+				count++
+			}
+			return nil
+		})
+	}
+	return size, count
+}
+
+// getCandidates 返回 categories 中各个类别可以清理的大小
+func (c *cleaner) getCandidates(categories []string) []*CleanupCandidate {
+	var result []*CleanupCandidate
+	for _, category := range categories {
+		paths, err := c.getPaths(category)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		size, count := getDiskUsage(paths)
+		result = append(result, &CleanupCandidate{
+			Category:  category,
+			Size:      size,
+			FileCount: count,
+		})
+	}
+	return result
+}
+
+// clean 清理 categories 中的类别，返回释放的大小
+func (c *cleaner) clean(categories []string) (uint64, error) {
+	var freed uint64
+	var errs []string
+	for _, category := range categories {
+		paths, err := c.getPaths(category)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		size, _ := getDiskUsage(paths)
+
+		if category == CategoryPackages {
+			if len(paths) == 0 {
+				continue
+			}
+			// 等待 lastore 的任务结束后和其他类别一样计算剩下的大小
+			err = c.cleanPackages()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", category, err))
+			}
+		} else {
+			for _, path := range paths {
+				err = os.RemoveAll(path)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("%s: %v", category, err))
+				}
+			}
+		}
+		left, _ := getDiskUsage(paths)
+		if left < size {
+			freed += size - left
+		}
+	}
+
+	if len(errs) > 0 {
+		return freed, fmt.Errorf("failed to clean: %s", strings.Join(errs, "; "))
+	}
+	return freed, nil
+}
@@ -0,0 +1,114 @@
+package housekeeping
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFile(t *testing.T, filename string, size int) {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(filename, make([]byte, size), 0644)
+	assert.Nil(t, err)
+}
+
+func newTestCleaner(t *testing.T) (*cleaner, string) {
+	dir, err := ioutil.TempDir("", "housekeeping")
+	assert.Nil(t, err)
+
+	c := &cleaner{
+		homeDir:        dir,
+		cacheDir:       filepath.Join(dir, ".cache"),
+		dataDir:        filepath.Join(dir, ".local/share"),
+		aptArchivesDir: filepath.Join(dir, "archives"),
+	}
+	writeTestFile(t, filepath.Join(c.cacheDir, "thumbnails/normal/a.png"), 100)
+	writeTestFile(t, filepath.Join(c.cacheDir, "thumbnails/large/b.png"), 200)
+	writeTestFile(t, filepath.Join(c.cacheDir, "app/data"), 1000)
+	writeTestFile(t, filepath.Join(c.cacheDir, "deepin/dde-daemon/history"), 10)
+	writeTestFile(t, filepath.Join(c.dataDir, "Trash/files/doc.txt"), 50)
+	writeTestFile(t, filepath.Join(c.dataDir, "Trash/info/doc.txt.trashinfo"), 5)
+	writeTestFile(t, filepath.Join(c.dataDir, "xorg/Xorg.0.log"), 30)
+	writeTestFile(t, filepath.Join(c.dataDir, "xorg/Xorg.0.log.old"), 40)
+	writeTestFile(t, filepath.Join(dir, ".xsession-errors.old"), 60)
+	writeTestFile(t, filepath.Join(c.aptArchivesDir, "a.deb"), 500)
+	writeTestFile(t, filepath.Join(c.aptArchivesDir, "lock"), 0)
+	return c, dir
+}
+
+func TestCleanerGetCandidates(t *testing.T) {
+	c, dir := newTestCleaner(t)
+	defer os.RemoveAll(dir)
+
+	candidates := c.getCandidates(allCategories)
+	assert.Equal(t, []*CleanupCandidate{
+		{Category: CategoryThumbnails, Size: 300, FileCount: 2},
+		{Category: CategoryTrash, Size: 55, FileCount: 2},
+		{Category: CategoryCache, Size: 1000, FileCount: 1},
+		{Category: CategoryLogs, Size: 100, FileCount: 2},
+		{Category: CategoryPackages, Size: 500, FileCount: 1},
+	}, candidates)
+
+	candidates = c.getCandidates(quickCleanCategories)
+	assert.Equal(t, []*CleanupCandidate{
+		{Category: CategoryThumbnails, Size: 300, FileCount: 2},
+		{Category: CategoryLogs, Size: 100, FileCount: 2},
+		{Category: CategoryPackages, Size: 500, FileCount: 1},
+	}, candidates)
+}
+
+func TestCleanerClean(t *testing.T) {
+	c, dir := newTestCleaner(t)
+	defer os.RemoveAll(dir)
+
+	var packagesCleaned bool
+	c.cleanPackages = func() error {
+		packagesCleaned = true
+		return os.Remove(filepath.Join(c.aptArchivesDir, "a.deb"))
+	}
+
+	freed, err := c.clean([]string{CategoryThumbnails, CategoryCache, CategoryPackages})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1800), freed)
+	assert.True(t, packagesCleaned)
+
+	_, err = os.Stat(filepath.Join(c.cacheDir, "thumbnails"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(c.cacheDir, "thumbnails/normal"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(c.cacheDir, "deepin/dde-daemon/history"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(c.dataDir, "Trash/files/doc.txt"))
+	assert.Nil(t, err)
+
+	writeTestFile(t, filepath.Join(c.aptArchivesDir, "a.deb"), 500)
+	c.cleanPackages = func() error {
+		return errors.New("lastore failed")
+	}
+	freed, err = c.clean([]string{CategoryTrash, CategoryLogs, CategoryPackages})
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(155), freed)
+	_, err = os.Stat(filepath.Join(c.dataDir, "xorg/Xorg.0.log"))
+	assert.Nil(t, err)
+
+	_, err = c.clean([]string{"unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestCleanerCleanPackagesLeft(t *testing.T) {
+	c, dir := newTestCleaner(t)
+	defer os.RemoveAll(dir)
+
+	// lastore 的任务成功了但是没有删除文件时不计入释放的大小
+	c.cleanPackages = func() error {
+		return nil
+	}
+	freed, err := c.clean([]string{CategoryPackages})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), freed)
+}
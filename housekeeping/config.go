@@ -0,0 +1,102 @@
+package housekeeping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+var configFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/housekeeping.json")
+
+const (
+	// 500MB
+	fsMinLeftSpace = 1024 * 1024 * 500
+)
+
+// mountThreshold 是一个挂载点的可用空间阈值，可用空间小于 MinAvailSize 或者可用比例小于 MinAvailPercent 时提醒。
+type mountThreshold struct {
+	// 挂载点上的任意路径，可以用 ~ 开头表示家目录
+	Path            string
+	MinAvailSize    uint64  // 字节，为 0 表示不检查
+	MinAvailPercent float64 // 0 到 100，为 0 表示不检查
+}
+
+type config struct {
+	Mounts []*mountThreshold
+}
+
+func getDefaultConfig() *config {
+	return &config{
+		Mounts: []*mountThreshold{
+			{Path: "~", MinAvailSize: fsMinLeftSpace},
+		},
+	}
+}
+
+func (t *mountThreshold) getPath() string {
+	if t.Path == "~" || strings.HasPrefix(t.Path, "~/") {
+		return basedir.GetUserHomeDir() + t.Path[1:]
+	}
+	return t.Path
+}
+
+// isBelow 判断可用空间是否低于阈值
+func (t *mountThreshold) isBelow(total, avail uint64) bool {
+	if t.MinAvailSize > 0 && avail < t.MinAvailSize {
+		return true
+	}
+	if t.MinAvailPercent > 0 && total > 0 &&
+		float64(avail)*100/float64(total) < t.MinAvailPercent {
+		return true
+	}
+	return false
+}
+
+func (cfg *config) check() error {
+	if len(cfg.Mounts) == 0 {
+		return errors.New("field Mounts is empty")
+	}
+	for _, t := range cfg.Mounts {
+		if !filepath.IsAbs(t.getPath()) {
+			return fmt.Errorf("path %q is not absolute", t.Path)
+		}
+		if t.MinAvailPercent < 0 || t.MinAvailPercent > 100 {
+			return fmt.Errorf("invalid MinAvailPercent %v of %q", t.MinAvailPercent, t.Path)
+		}
+	}
+	return nil
+}
+
+func loadConfig(filename string) (*config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.check()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadConfigSafe(filename string) *config {
+	cfg, err := loadConfig(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warningf("failed to load config %q: %v", filename, err)
+		}
+		return getDefaultConfig()
+	}
+	return cfg
+}
@@ -0,0 +1,55 @@
+package housekeeping
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountThresholdIsBelow(t *testing.T) {
+	threshold := &mountThreshold{MinAvailSize: 100}
+	assert.True(t, threshold.isBelow(1000, 99))
+	assert.False(t, threshold.isBelow(1000, 100))
+
+	threshold = &mountThreshold{MinAvailPercent: 10}
+	assert.True(t, threshold.isBelow(1000, 99))
+	assert.False(t, threshold.isBelow(1000, 100))
+	assert.False(t, threshold.isBelow(0, 0))
+
+	threshold = &mountThreshold{}
+	assert.False(t, threshold.isBelow(1000, 0))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "housekeeping")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "housekeeping.json")
+	err = ioutil.WriteFile(filename, []byte(`{"Mounts":[{"Path":"~","MinAvailSize":1024},{"Path":"/data","MinAvailPercent":5}]}`), 0644)
+	assert.Nil(t, err)
+	cfg, err := loadConfig(filename)
+	assert.Nil(t, err)
+	assert.Len(t, cfg.Mounts, 2)
+	assert.Equal(t, float64(5), cfg.Mounts[1].MinAvailPercent)
+
+	for _, data := range []string{`{}`, `{"Mounts":[{"Path":"data"}]}`, `{"Mounts":[{"Path":"/","MinAvailPercent":101}]}`} {
+		err = ioutil.WriteFile(filename, []byte(data), 0644)
+		assert.Nil(t, err)
+		_, err = loadConfig(filename)
+		assert.NotNil(t, err, data)
+	}
+
+	cfg = loadConfigSafe(filepath.Join(dir, "nonexistent.json"))
+	assert.Equal(t, getDefaultConfig(), cfg)
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512B", formatSize(512))
+	assert.Equal(t, "1.5KB", formatSize(1536))
+	assert.Equal(t, "500.0MB", formatSize(fsMinLeftSpace))
+	assert.Equal(t, "2.0GB", formatSize(2<<30))
+}
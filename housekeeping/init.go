@@ -20,20 +20,8 @@
 package housekeeping
 
 import (
-	"os"
-	"time"
-
-	"github.com/linuxdeepin/go-dbus-factory/org.freedesktop.notifications"
 	"pkg.deepin.io/dde/daemon/loader"
-	"pkg.deepin.io/lib/dbus1"
-	. "pkg.deepin.io/lib/gettext"
 	"pkg.deepin.io/lib/log"
-	"pkg.deepin.io/lib/utils"
-)
-
-const (
-	// 500MB
-	fsMinLeftSpace = 1024 * 1024 * 500
 )
 
 func init() {
@@ -42,13 +30,13 @@ func init() {
 
 type Daemon struct {
 	*loader.ModuleBase
-	ticker   *time.Ticker
-	stopChan chan struct{}
+	manager *Manager
 }
 
 func NewDaemon(logger *log.Logger) *Daemon {
 	daemon := new(Daemon)
 	daemon.ModuleBase = loader.NewModuleBase("housekeeping", daemon, logger)
+	daemon.SetStartConcurrently(true)
 	return daemon
 }
 
@@ -61,65 +49,42 @@ var (
 )
 
 func (d *Daemon) Start() error {
-	if d.stopChan != nil {
+	if d.manager != nil {
 		return nil
 	}
 
-	d.ticker = time.NewTicker(time.Minute * 1)
-	d.stopChan = make(chan struct{})
-	go func() {
-		for {
-			select {
-			case _, ok := <-d.ticker.C:
-				if !ok {
-					logger.Error("Invalid ticker event")
-					return
-				}
+	service := loader.GetService()
+	d.manager = newManager(service)
+	err := service.Export(dbusPath, d.manager)
+	if err != nil {
+		return err
+	}
+
+	err = service.RequestName(dbusServiceName)
+	if err != nil {
+		return err
+	}
 
-				fs, err := utils.QueryFilesytemInfo(os.Getenv("HOME"))
-				if err != nil {
-					logger.Error("Failed to get filesystem info:", err)
-					break
-				}
-				logger.Debug("Home filesystem info(total, free, avail):",
-					fs.TotalSize, fs.FreeSize, fs.AvailSize)
-				if fs.AvailSize > fsMinLeftSpace {
-					break
-				}
-				err = sendNotify("dialog-warning", "",
-					Tr("Insufficient disk space, please clean up in time!"))
-				if err != nil {
-					logger.Warning(err)
-				}
-			case <-d.stopChan:
-				logger.Debug("Stop housekeeping")
-				if d.ticker != nil {
-					d.ticker.Stop()
-					d.ticker = nil
-				}
-				return
-			}
-		}
-	}()
+	go d.manager.loop()
 	return nil
 }
 
 func (d *Daemon) Stop() error {
-	if d.stopChan != nil {
-		close(d.stopChan)
-		d.stopChan = nil
+	if d.manager == nil {
+		return nil
 	}
-	return nil
-}
 
-func sendNotify(icon, summary, body string) error {
-	sessionConn, err := dbus.SessionBus()
+	service := loader.GetService()
+	err := service.ReleaseName(dbusServiceName)
 	if err != nil {
-		return err
+		logger.Warning(err)
 	}
-	notifier := notifications.NewNotifications(sessionConn)
-	_, err = notifier.Notify(0, "dde-control-center", 0,
-		icon, summary, body,
-		nil, nil, -1)
-	return err
+	err = service.StopExport(d.manager)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	d.manager.destroy()
+	d.manager = nil
+	return nil
 }
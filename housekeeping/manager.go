@@ -0,0 +1,257 @@
+package housekeeping
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/linuxdeepin/go-dbus-factory/com.deepin.lastore"
+	"github.com/linuxdeepin/go-dbus-factory/org.freedesktop.notifications"
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+	. "pkg.deepin.io/lib/gettext"
+	"pkg.deepin.io/lib/utils"
+)
+
+const (
+	dbusServiceName = "com.deepin.daemon.Housekeeping"
+	dbusPath        = "/com/deepin/daemon/Housekeeping"
+	dbusInterface   = dbusServiceName
+
+	notifyActKeyCleanup = "cleanup"
+	checkInterval       = time.Minute
+
+	cleanArchivesTimeout = 10 * time.Minute
+)
+
+// lastore 中任务的状态
+const (
+	jobStatusSucceed = "succeed"
+	jobStatusFailed  = "failed"
+	jobStatusEnd     = "end"
+)
+
+type Manager struct {
+	service       *dbusutil.Service
+	cfg           *config
+	cleaner       *cleaner
+	notifications *notifications.Notifications
+	sigLoop       *dbusutil.SignalLoop
+	quit          chan struct{}
+
+	mu sync.Mutex
+	// 已经提醒过的挂载点，空间恢复后删除，避免每次检查都提醒
+	notifiedMounts map[string]bool
+	notifyId       uint32
+	cleaning       bool
+
+	methods *struct {
+		GetCleanupCandidates func() `out:"candidates"`
+		Clean                func() `in:"categories" out:"freed"`
+	}
+}
+
+func newManager(service *dbusutil.Service) *Manager {
+	sessionBus := service.Conn()
+	m := &Manager{
+		service:        service,
+		cfg:            loadConfigSafe(configFile),
+		cleaner:        newCleaner(),
+		notifications:  notifications.NewNotifications(sessionBus),
+		sigLoop:        dbusutil.NewSignalLoop(sessionBus, 10),
+		quit:           make(chan struct{}),
+		notifiedMounts: make(map[string]bool),
+	}
+	m.sigLoop.Start()
+	m.notifications.InitSignalExt(m.sigLoop, true)
+	_, err := m.notifications.ConnectActionInvoked(m.handleActionInvoked)
+	if err != nil {
+		logger.Warning(err)
+	}
+	return m
+}
+
+func (m *Manager) destroy() {
+	close(m.quit)
+	m.notifications.RemoveAllHandlers()
+	m.sigLoop.Stop()
+}
+
+func (*Manager) GetInterfaceName() string {
+	return dbusInterface
+}
+
+func (m *Manager) loop() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.checkMounts()
+		case <-m.quit:
+			logger.Debug("Stop housekeeping")
+			return
+		}
+	}
+}
+
+// checkMounts 检查各个挂载点的可用空间，低于阈值时扫描可以清理的文件并提醒。
+func (m *Manager) checkMounts() {
+	var lowMounts []string
+	for _, t := range m.cfg.Mounts {
+		path := t.getPath()
+		fs, err := utils.QueryFilesytemInfo(path)
+		if err != nil {
+			logger.Warningf("Failed to get filesystem info of %q: %v", path, err)
+			continue
+		}
+		logger.Debugf("filesystem info of %q (total, free, avail): %v %v %v",
+			path, fs.TotalSize, fs.FreeSize, fs.AvailSize)
+
+		m.mu.Lock()
+		if !t.isBelow(uint64(fs.TotalSize), uint64(fs.AvailSize)) {
+			delete(m.notifiedMounts, path)
+		} else if !m.notifiedMounts[path] {
+			m.notifiedMounts[path] = true
+			lowMounts = append(lowMounts, path)
+		}
+		m.mu.Unlock()
+	}
+
+	if len(lowMounts) == 0 {
+		return
+	}
+	logger.Info("insufficient disk space:", lowMounts)
+	m.notifyLowSpace()
+}
+
+func (m *Manager) notifyLowSpace() {
+	var reclaimable uint64
+	for _, candidate := range m.cleaner.getCandidates(quickCleanCategories) {
+		reclaimable += candidate.Size
+	}
+
+	body := Tr("Insufficient disk space, please clean up in time!")
+	var actions []string
+	if reclaimable > 0 {
+		body += " " + fmt.Sprintf(Tr("%s can be cleaned up."), formatSize(reclaimable))
+		actions = []string{notifyActKeyCleanup, Tr("Clean Up")}
+	}
+
+	id, err := m.notifications.Notify(0, "dde-control-center", 0,
+		"dialog-warning", "", body, actions, nil, -1)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	m.mu.Lock()
+	m.notifyId = id
+	m.mu.Unlock()
+}
+
+func (m *Manager) handleActionInvoked(id uint32, actionKey string) {
+	m.mu.Lock()
+	notifyId := m.notifyId
+	m.mu.Unlock()
+	if id != notifyId || actionKey != notifyActKeyCleanup {
+		return
+	}
+
+	go func() {
+		freed, err := m.clean(quickCleanCategories)
+		if err != nil {
+			logger.Warning(err)
+		}
+		body := fmt.Sprintf(Tr("%s of disk space has been freed up"), formatSize(freed))
+		_, err = m.notifications.Notify(0, "dde-control-center", 0,
+			"dialog-information", "", body, nil, nil, -1)
+		if err != nil {
+			logger.Warning(err)
+		}
+	}()
+}
+
+func (m *Manager) clean(categories []string) (uint64, error) {
+	m.mu.Lock()
+	if m.cleaning {
+		m.mu.Unlock()
+		return 0, fmt.Errorf("cleaning is in progress")
+	}
+	m.cleaning = true
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.cleaning = false
+		m.mu.Unlock()
+	}()
+	return m.cleaner.clean(categories)
+}
+
+func (m *Manager) GetCleanupCandidates() (string, *dbus.Error) {
+	data, err := json.Marshal(m.cleaner.getCandidates(allCategories))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) Clean(categories []string) (uint64, *dbus.Error) {
+	for _, category := range categories {
+		if !isStrInList(category, allCategories) {
+			return 0, dbusutil.ToError(fmt.Errorf("unknown category %q", category))
+		}
+	}
+	freed, err := m.clean(categories)
+	return freed, dbusutil.ToError(err)
+}
+
+// cleanAptArchives 调用 lastore 的 CleanArchives，等待清理的任务结束
+func cleanAptArchives() error {
+	systemBus, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	jobPath, err := lastore.NewLastore(systemBus).CleanArchives(0)
+	if err != nil {
+		return err
+	}
+	job, err := lastore.NewJob(systemBus, jobPath)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(cleanArchivesTimeout)
+	for time.Now().Before(deadline) {
+		status, err := job.Status().Get(0)
+		if err != nil {
+			// 任务结束后 lastore 会移除任务对象
+			logger.Debugf("failed to get status of %s: %v", jobPath, err)
+			return nil
+		}
+		switch status {
+		case jobStatusSucceed, jobStatusEnd:
+			return nil
+		case jobStatusFailed:
+			return fmt.Errorf("lastore job %s failed", jobPath)
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("wait for lastore job %s timeout", jobPath)
+}
+
+func formatSize(size uint64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f%s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1fTB", value)
+}
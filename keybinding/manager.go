@@ -116,6 +116,13 @@ type Manager struct {
 			pressed   bool
 			keystroke string
 		}
+
+		// 按下按键序列的一部分后提示之后可以按的键，prefix 为空时隐藏提示
+		KeySequenceHint struct {
+			prefix string
+			keys   []string
+			names  []string
+		}
 	}
 
 	methods *struct {
@@ -134,6 +141,18 @@ type Manager struct {
 		GetCapsLockState          func() `out:"state"`
 		SetCapsLockState          func() `in:"state"`
 
+		// 只在部分应用中生效的自定义快捷键
+		AddCustomShortcutWithScope    func() `in:"name,action,keystroke,scope" out:"id,type"`
+		ModifyCustomShortcutWithScope func() `in:"id,name,cmd,keystroke,scope"`
+
+		// 调用 D-Bus 方法、按键宏和组合动作
+		GetCustomShortcutActions func() `in:"id" out:"actions"`
+		SetCustomShortcutActions func() `in:"id,actions"`
+
+		// 导出和导入快捷键方案
+		ExportShortcuts func() `in:"types" out:"data"`
+		ImportShortcuts func() `in:"data,strategy" out:"report"`
+
 		// deprecated
 		Add            func() `in:"name,action,keystroke" out:"ret0,ret1"`
 		Query          func() `in:"id,type" out:"shortcut"`
@@ -309,16 +328,22 @@ func (m *Manager) destroy() {
 	}
 }
 
+// 按键宏和组合动作最多嵌套的层数
+const maxActionDepth = 3
+
 func (m *Manager) handleKeyEvent(ev *shortcuts.KeyEvent) {
 	const minKeyEventInterval = 200 * time.Millisecond
-	now := time.Now()
-	duration := now.Sub(m.lastKeyEventTime)
-	logger.Debug("duration:", duration)
-	if 0 < duration && duration < minKeyEventInterval {
-		logger.Debug("handleKeyEvent ignore key event")
-		return
+	// 按键宏和组合动作产生的事件不受时间间隔限制
+	if ev.Depth == 0 {
+		now := time.Now()
+		duration := now.Sub(m.lastKeyEventTime)
+		logger.Debug("duration:", duration)
+		if 0 < duration && duration < minKeyEventInterval {
+			logger.Debug("handleKeyEvent ignore key event")
+			return
+		}
+		m.lastKeyEventTime = now
 	}
-	m.lastKeyEventTime = now
 
 	logger.Debugf("handleKeyEvent ev: %#v", ev)
 	action := ev.Shortcut.GetAction()
@@ -343,6 +368,19 @@ func (m *Manager) emitShortcutSignal(signalName string, shortcut shortcuts.Short
 	}
 }
 
+func (m *Manager) emitSignalKeySequenceHint(prefix string, keys, names []string) {
+	if keys == nil {
+		keys = []string{}
+	}
+	if names == nil {
+		names = []string{}
+	}
+	err := m.service.Emit(m, "KeySequenceHint", prefix, keys, names)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
 func (m *Manager) enableListenGSettingsChanged(val bool) {
 	m.enableListenGSettings = val
 }
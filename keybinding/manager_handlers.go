@@ -230,6 +230,68 @@ func (m *Manager) initHandlers() {
 		}
 	}
 
+	m.handlers[ActionTypeDBusCall] = func(ev *KeyEvent) {
+		action := ev.Shortcut.GetAction()
+		arg, ok := action.Arg.(*ActionDBusCallArg)
+		if !ok {
+			logger.Warning(ErrTypeAssertionFail)
+			return
+		}
+
+		go func() {
+			conn := m.sessionConn()
+			if arg.Bus == "system" {
+				conn = m.systemConn()
+			}
+			err := conn.Object(arg.Dest, arg.Path).Call(arg.Method, 0, arg.Args...).Err
+			if err != nil {
+				logger.Warningf("failed to call %s %s: %v", arg.Dest, arg.Method, err)
+			}
+		}()
+	}
+
+	// 按键宏和组合动作在新的 goroutine 中依次触发，避免在事件回调中再次调用事件回调
+	m.handlers[ActionTypeKeyMacro] = func(ev *KeyEvent) {
+		if ev.Depth >= maxActionDepth {
+			logger.Warning("key macro nested too deep:", ev.Shortcut.GetId())
+			return
+		}
+		action := ev.Shortcut.GetAction()
+		arg, ok := action.Arg.(*ActionKeyMacroArg)
+		if !ok {
+			logger.Warning(ErrTypeAssertionFail)
+			return
+		}
+
+		go func() {
+			for _, ks := range arg.Keystrokes {
+				err := m.shortcutManager.EmitKeystroke(ks, ev.Depth+1)
+				if err != nil {
+					logger.Warningf("failed to emit keystroke %s: %v", ks, err)
+				}
+			}
+		}()
+	}
+
+	m.handlers[ActionTypeChain] = func(ev *KeyEvent) {
+		if ev.Depth >= maxActionDepth {
+			logger.Warning("chained action nested too deep:", ev.Shortcut.GetId())
+			return
+		}
+		action := ev.Shortcut.GetAction()
+		actions, ok := action.Arg.([]*Action)
+		if !ok {
+			logger.Warning(ErrTypeAssertionFail)
+			return
+		}
+
+		go func() {
+			for _, a := range actions {
+				m.shortcutManager.EmitAction(a, ev.Depth+1)
+			}
+		}()
+	}
+
 	m.shortcutManager.SetAllModKeysReleasedCallback(func() {
 		switch m.switchKbdLayoutState {
 		case SKLStateWait:
@@ -242,6 +304,8 @@ func (m *Manager) initHandlers() {
 		}
 		m.switchKbdLayoutState = SKLStateNone
 	})
+
+	m.shortcutManager.SetKeySequenceHintCallback(m.emitSignalKeySequenceHint)
 }
 
 func (m *Manager) sklWait() {
@@ -153,8 +153,27 @@ func (m *Manager) ListShortcutsByType(type0 int32) (string, *dbus.Error) {
 
 func (m *Manager) AddCustomShortcut(name, action, keystroke string) (id string,
 	type0 int32, busErr *dbus.Error) {
+	return m.addCustomShortcut(name, action, keystroke, nil)
+}
+
+// AddCustomShortcutWithScope 添加只在部分应用中生效的自定义快捷键
+//
+// scope: JSON 格式的 {"Include": [应用], "Exclude": [应用]}，应用是窗口 WM_CLASS 的 instance 或 class，或者 desktop id，
+// 为空时在所有应用中生效
+func (m *Manager) AddCustomShortcutWithScope(name, action, keystroke, scope string) (id string,
+	type0 int32, busErr *dbus.Error) {
+	shortcutScope, err := shortcuts.ParseShortcutScope(scope)
+	if err != nil {
+		busErr = dbusutil.ToError(err)
+		return
+	}
+	return m.addCustomShortcut(name, action, keystroke, shortcutScope)
+}
+
+func (m *Manager) addCustomShortcut(name, action, keystroke string, scope *shortcuts.ShortcutScope) (id string,
+	type0 int32, busErr *dbus.Error) {
 
-	logger.Debugf("Add custom key: %q %q %q", name, action, keystroke)
+	logger.Debugf("Add custom key: %q %q %q %+v", name, action, keystroke, scope)
 	ks, err := shortcuts.ParseKeystroke(keystroke)
 	if err != nil {
 		busErr = dbusutil.ToError(err)
@@ -172,7 +191,7 @@ func (m *Manager) AddCustomShortcut(name, action, keystroke string) (id string,
 		return
 	}
 
-	shortcut, err := m.customShortcutManager.Add(name, action, []*shortcuts.Keystroke{ks})
+	shortcut, err := m.customShortcutManager.Add(name, action, []*shortcuts.Keystroke{ks}, scope)
 	if err != nil {
 		busErr = dbusutil.ToError(err)
 		return
@@ -240,6 +259,24 @@ func (m *Manager) LookupConflictingShortcut(keystroke string) (string, *dbus.Err
 // keystroke: new keystroke
 func (m *Manager) ModifyCustomShortcut(id, name, cmd, keystroke string) *dbus.Error {
 	logger.Debugf("ModifyCustomShortcut id: %q, name: %q, cmd: %q, keystroke: %q", id, name, cmd, keystroke)
+	return m.modifyCustomShortcut(id, name, cmd, keystroke, nil, false)
+}
+
+// ModifyCustomShortcutWithScope 和 ModifyCustomShortcut 相同，同时修改生效的应用
+//
+// scope: 同 AddCustomShortcutWithScope
+func (m *Manager) ModifyCustomShortcutWithScope(id, name, cmd, keystroke, scope string) *dbus.Error {
+	logger.Debugf("ModifyCustomShortcutWithScope id: %q, name: %q, cmd: %q, keystroke: %q, scope: %q",
+		id, name, cmd, keystroke, scope)
+	shortcutScope, err := shortcuts.ParseShortcutScope(scope)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	return m.modifyCustomShortcut(id, name, cmd, keystroke, shortcutScope, true)
+}
+
+func (m *Manager) modifyCustomShortcut(id, name, cmd, keystroke string,
+	scope *shortcuts.ShortcutScope, modifyScope bool) *dbus.Error {
 	const ty = shortcuts.ShortcutTypeCustom
 	// get the shortcut
 	shortcut := m.shortcutManager.GetByIdType(id, ty)
@@ -271,6 +308,9 @@ func (m *Manager) ModifyCustomShortcut(id, name, cmd, keystroke string) *dbus.Er
 	// modify then save
 	customShortcut.SetName(name)
 	customShortcut.Cmd = cmd
+	if modifyScope {
+		m.shortcutManager.ModifyShortcutScope(customShortcut, scope)
+	}
 	m.shortcutManager.ModifyShortcutKeystrokes(shortcut, keystrokes)
 	err := customShortcut.Save()
 	if err != nil {
@@ -280,6 +320,61 @@ func (m *Manager) ModifyCustomShortcut(id, name, cmd, keystroke string) *dbus.Er
 	return nil
 }
 
+func (m *Manager) getCustomShortcut(id string) (*shortcuts.CustomShortcut, error) {
+	const ty = shortcuts.ShortcutTypeCustom
+	shortcut := m.shortcutManager.GetByIdType(id, ty)
+	if shortcut == nil {
+		return nil, ErrShortcutNotFound{id, ty}
+	}
+	customShortcut, ok := shortcut.(*shortcuts.CustomShortcut)
+	if !ok {
+		return nil, errTypeAssertionFail
+	}
+	return customShortcut, nil
+}
+
+// GetCustomShortcutActions 返回自定义快捷键的动作列表，JSON 格式，没有设置时返回空字符串
+func (m *Manager) GetCustomShortcutActions(id string) (string, *dbus.Error) {
+	customShortcut, err := m.getCustomShortcut(id)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	actions := customShortcut.GetActions()
+	if len(actions) == 0 {
+		return "", nil
+	}
+	str, err := util.MarshalJSON(actions)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return str, nil
+}
+
+// SetCustomShortcutActions 设置自定义快捷键的动作列表，多个动作按顺序执行
+//
+// actions: JSON 数组，每项的 Type 为 exec、dbus 或 macro，
+// 如 [{"Type":"dbus","Dest":"com.deepin.dde.osd","Path":"/","Method":"com.deepin.dde.osd.ShowOSD","Args":[{"Type":"s","Value":"CapsLockOn"}]}]，
+// 为空字符串时恢复为执行命令。
+func (m *Manager) SetCustomShortcutActions(id, actions string) *dbus.Error {
+	logger.Debugf("SetCustomShortcutActions id: %q, actions: %q", id, actions)
+	customShortcut, err := m.getCustomShortcut(id)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	customActions, err := shortcuts.ParseCustomActions(actions)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	customShortcut.SetActions(customActions)
+	err = customShortcut.Save()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.emitShortcutSignal(shortcutSignalChanged, customShortcut)
+	return nil
+}
+
 func (m *Manager) AddShortcutKeystroke(id string, type0 int32, keystroke string) *dbus.Error {
 	logger.Debug("AddShortcutKeystroke", id, type0, keystroke)
 	shortcut := m.shortcutManager.GetByIdType(id, type0)
@@ -297,6 +392,11 @@ func (m *Manager) AddShortcutKeystroke(id string, type0 int32, keystroke string)
 	}
 	logger.Debug("keystroke:", ks.DebugString())
 
+	if type0 == shortcuts.ShortcutTypeWM && ks.IsSequence() {
+		return dbusutil.ToError(errors.New(
+			"keystroke of shortcut which type is wm can not be a key sequence"))
+	}
+
 	if type0 == shortcuts.ShortcutTypeWM && ks.Mods == 0 {
 		keyLower := strings.ToLower(ks.Keystr)
 		if keyLower == "super_l" || keyLower == "super_r" {
@@ -0,0 +1,677 @@
+package keybinding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"pkg.deepin.io/dde/daemon/keybinding/shortcuts"
+	"pkg.deepin.io/dde/daemon/keybinding/util"
+	dbus "pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// 导出和导入快捷键方案，用于在多台机器上使用相同的快捷键。
+
+const shortcutsExportVersion = 1
+
+// 导入时处理按键冲突的方式
+const (
+	// 只检查，不修改
+	importStrategyDryRun = "dry-run"
+	// 从冲突的快捷键中删除这个按键
+	importStrategyOverwrite = "overwrite"
+	// 跳过有冲突的快捷键
+	importStrategySkip = "skip"
+)
+
+// 导入每个快捷键的结果
+const (
+	importStatusAdded     = "added"
+	importStatusModified  = "modified"
+	importStatusUnchanged = "unchanged"
+	importStatusSkipped   = "skipped"
+	// 只在 dry-run 时使用，表示有冲突
+	importStatusConflict = "conflict"
+	importStatusFailed   = "failed"
+)
+
+var exportableShortcutTypes = []int32{
+	shortcuts.ShortcutTypeSystem,
+	shortcuts.ShortcutTypeCustom,
+	shortcuts.ShortcutTypeMedia,
+	shortcuts.ShortcutTypeWM,
+}
+
+type exportedShortcuts struct {
+	Version   int
+	Shortcuts []*exportedShortcut
+}
+
+type exportedShortcut struct {
+	Id         string
+	Type       int32
+	Name       string
+	Keystrokes []string
+
+	// 自定义快捷键
+	Cmd     string                    `json:",omitempty"`
+	Scope   *shortcuts.ShortcutScope  `json:",omitempty"`
+	Actions []*shortcuts.CustomAction `json:",omitempty"`
+}
+
+type importConflict struct {
+	Keystroke string
+	Id        string
+	Type      int32
+	Name      string
+	// 冲突的快捷键也在导入的方案中
+	Imported bool `json:",omitempty"`
+}
+
+type importResult struct {
+	Id        string
+	Type      int32
+	Name      string
+	Status    string
+	Error     string            `json:",omitempty"`
+	Conflicts []*importConflict `json:",omitempty"`
+}
+
+type importReport struct {
+	Strategy  string
+	Added     int
+	Modified  int
+	Unchanged int
+	Skipped   int
+	Conflict  int
+	Failed    int
+	Results   []*importResult
+}
+
+func (r *importReport) count() {
+	for _, result := range r.Results {
+		switch result.Status {
+		case importStatusAdded:
+			r.Added++
+		case importStatusModified:
+			r.Modified++
+		case importStatusUnchanged:
+			r.Unchanged++
+		case importStatusSkipped:
+			r.Skipped++
+		case importStatusConflict:
+			r.Conflict++
+		case importStatusFailed:
+			r.Failed++
+		}
+	}
+}
+
+func isExportableShortcutType(type0 int32) bool {
+	for _, t := range exportableShortcutTypes {
+		if t == type0 {
+			return true
+		}
+	}
+	return false
+}
+
+func parseExportedShortcuts(data, strategy string) (*exportedShortcuts, error) {
+	switch strategy {
+	case importStrategyDryRun, importStrategyOverwrite, importStrategySkip:
+	default:
+		return nil, fmt.Errorf("invalid strategy %q", strategy)
+	}
+
+	var exported exportedShortcuts
+	err := json.Unmarshal([]byte(data), &exported)
+	if err != nil {
+		return nil, err
+	}
+	if exported.Version < 1 || exported.Version > shortcutsExportVersion {
+		return nil, fmt.Errorf("unsupported version %d", exported.Version)
+	}
+	return &exported, nil
+}
+
+func exportShortcut(shortcut shortcuts.Shortcut) *exportedShortcut {
+	keystrokes := shortcut.GetKeystrokes()
+	item := &exportedShortcut{
+		Id:         shortcut.GetId(),
+		Type:       shortcut.GetType(),
+		Name:       shortcut.GetName(),
+		Keystrokes: make([]string, len(keystrokes)),
+	}
+	for i, ks := range keystrokes {
+		item.Keystrokes[i] = ks.String()
+	}
+	if customShortcut, ok := shortcut.(*shortcuts.CustomShortcut); ok {
+		item.Cmd = customShortcut.Cmd
+		item.Scope = customShortcut.GetScope()
+		item.Actions = customShortcut.GetActions()
+	}
+	return item
+}
+
+// ExportShortcuts 导出快捷键方案，JSON 格式，可以用 ImportShortcuts 导入
+//
+// types: 导出的快捷键类型，为空时导出系统、自定义、多媒体和窗口管理器的快捷键
+func (m *Manager) ExportShortcuts(types []int32) (string, *dbus.Error) {
+	logger.Debug("ExportShortcuts types:", types)
+	if len(types) == 0 {
+		types = exportableShortcutTypes
+	}
+	exported := &exportedShortcuts{
+		Version:   shortcutsExportVersion,
+		Shortcuts: []*exportedShortcut{},
+	}
+	for _, type0 := range types {
+		if !isExportableShortcutType(type0) {
+			return "", dbusutil.ToError(ErrInvalidShortcutType{type0})
+		}
+		list := m.shortcutManager.ListByType(type0)
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].GetId() < list[j].GetId()
+		})
+		for _, shortcut := range list {
+			exported.Shortcuts = append(exported.Shortcuts, exportShortcut(shortcut))
+		}
+	}
+
+	ret, err := util.MarshalJSON(exported)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return ret, nil
+}
+
+// ImportShortcuts 导入 ExportShortcuts 导出的快捷键方案，返回 JSON 格式的导入报告，
+// 包括每个快捷键的结果和冲突的按键。
+//
+// strategy: 处理按键冲突的方式，dry-run 只检查不修改，overwrite 从冲突的快捷键中删除这个按键，
+// skip 跳过有冲突的快捷键。导入的方案中的按键互相冲突时总是跳过后面的快捷键。
+func (m *Manager) ImportShortcuts(data, strategy string) (string, *dbus.Error) {
+	logger.Debug("ImportShortcuts strategy:", strategy)
+	exported, err := parseExportedShortcuts(data, strategy)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+
+	im := &shortcutImporter{
+		shortcutManager:       m.shortcutManager,
+		customShortcutManager: m.customShortcutManager,
+		emitShortcutSignal:    m.emitShortcutSignal,
+	}
+	report := im.importShortcuts(exported.Shortcuts, strategy)
+	ret, err := util.MarshalJSON(report)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return ret, nil
+}
+
+// importShortcutManager 是导入时用到的 shortcuts.ShortcutManager 的方法
+type importShortcutManager interface {
+	Add(shortcut shortcuts.Shortcut)
+	GetByIdType(id string, type0 int32) shortcuts.Shortcut
+	ListByType(type0 int32) []shortcuts.Shortcut
+	FindConflictingKeystroke(ks *shortcuts.Keystroke) (*shortcuts.Keystroke, error)
+	FindConflictingKeystrokes(ks *shortcuts.Keystroke) ([]*shortcuts.Keystroke, error)
+	AddShortcutKeystroke(shortcut shortcuts.Shortcut, ks *shortcuts.Keystroke)
+	DeleteShortcutKeystroke(shortcut shortcuts.Shortcut, ks *shortcuts.Keystroke)
+	ModifyShortcutKeystrokes(shortcut shortcuts.Shortcut, keystrokes []*shortcuts.Keystroke)
+	ModifyShortcutScope(shortcut *shortcuts.CustomShortcut, scope *shortcuts.ShortcutScope)
+}
+
+// shortcutImporter 把导入的快捷键方案应用到 shortcutManager 中，测试时可以替换 shortcutManager
+type shortcutImporter struct {
+	shortcutManager       importShortcutManager
+	customShortcutManager *shortcuts.CustomShortcutManager
+	emitShortcutSignal    func(signalName string, shortcut shortcuts.Shortcut)
+}
+
+type importItem struct {
+	exported *exportedShortcut
+	// 为 nil 表示添加新的自定义快捷键
+	shortcut   shortcuts.Shortcut
+	keystrokes []*shortcuts.Keystroke
+	result     *importResult
+	// 和现有的快捷键冲突的按键
+	overwrites []*shortcuts.Keystroke
+}
+
+func (item *importItem) uid() string {
+	if item.shortcut == nil {
+		return ""
+	}
+	return item.shortcut.GetUid()
+}
+
+func (item *importItem) fail(err error) {
+	item.result.Status = importStatusFailed
+	item.result.Error = err.Error()
+}
+
+func (item *importItem) isPending() bool {
+	return item.result.Status == ""
+}
+
+// releases 判断导入后 item 是否不再使用和 ks 冲突的按键
+func (item *importItem) releases(ks *shortcuts.Keystroke) bool {
+	for _, ks0 := range item.keystrokes {
+		if ks0.Conflicts(ks) {
+			return false
+		}
+	}
+	return true
+}
+
+func (im *shortcutImporter) findCustomShortcut(id, name string) shortcuts.Shortcut {
+	shortcut := im.shortcutManager.GetByIdType(id, shortcuts.ShortcutTypeCustom)
+	if shortcut != nil {
+		return shortcut
+	}
+	// 不同机器上自定义快捷键的 id 不同，用名称查找
+	for _, shortcut := range im.shortcutManager.ListByType(shortcuts.ShortcutTypeCustom) {
+		if shortcut.GetName() == name {
+			return shortcut
+		}
+	}
+	return nil
+}
+
+func (im *shortcutImporter) newImportItem(exported *exportedShortcut) *importItem {
+	item := &importItem{
+		exported: exported,
+		result: &importResult{
+			Id:   exported.Id,
+			Type: exported.Type,
+			Name: exported.Name,
+		},
+	}
+
+	for _, str := range exported.Keystrokes {
+		ks, err := shortcuts.ParseKeystroke(str)
+		if err != nil {
+			item.fail(err)
+			return item
+		}
+		if exported.Type == shortcuts.ShortcutTypeWM {
+			if ks.IsSequence() {
+				item.fail(errors.New("keystroke of shortcut which type is wm can not be a key sequence"))
+				return item
+			}
+			keyLower := strings.ToLower(ks.Keystr)
+			if ks.Mods == 0 && (keyLower == "super_l" || keyLower == "super_r") {
+				item.fail(errors.New("keystroke of shortcut which type is wm can not be set to the Super key"))
+				return item
+			}
+		}
+		item.keystrokes = append(item.keystrokes, ks)
+	}
+
+	switch exported.Type {
+	case shortcuts.ShortcutTypeCustom:
+		// 和读取 custom.ini 时一样处理空的设置，以便比较是否有修改
+		if exported.Scope != nil {
+			exported.Scope = shortcuts.NewShortcutScope(exported.Scope.Include, exported.Scope.Exclude)
+		}
+		if len(exported.Actions) == 0 {
+			exported.Actions = nil
+		}
+		err := shortcuts.CheckCustomActions(exported.Actions)
+		if err != nil {
+			item.fail(err)
+			return item
+		}
+		item.shortcut = im.findCustomShortcut(exported.Id, exported.Name)
+		if item.shortcut == nil && exported.Name == "" {
+			item.fail(errors.New("name of custom shortcut is empty"))
+			return item
+		}
+	case shortcuts.ShortcutTypeSystem, shortcuts.ShortcutTypeMedia, shortcuts.ShortcutTypeWM:
+		item.shortcut = im.shortcutManager.GetByIdType(exported.Id, exported.Type)
+		if item.shortcut == nil {
+			item.fail(ErrShortcutNotFound{exported.Id, exported.Type})
+			return item
+		}
+	default:
+		item.fail(ErrInvalidShortcutType{exported.Type})
+		return item
+	}
+
+	if item.shortcut != nil {
+		item.result.Id = item.shortcut.GetId()
+		if !item.shortcut.GetKeystrokesModifiable() {
+			item.fail(errShortcutKeystrokesUnmodifiable)
+		} else if item.isUnchanged() {
+			item.result.Status = importStatusUnchanged
+		}
+	}
+	return item
+}
+
+func (item *importItem) isUnchanged() bool {
+	keystrokes := item.shortcut.GetKeystrokes()
+	if len(keystrokes) != len(item.keystrokes) {
+		return false
+	}
+	for i, ks := range keystrokes {
+		if ks.String() != item.keystrokes[i].String() {
+			return false
+		}
+	}
+
+	customShortcut, ok := item.shortcut.(*shortcuts.CustomShortcut)
+	if !ok {
+		return true
+	}
+	exported := item.exported
+	return customShortcut.GetName() == exported.Name &&
+		customShortcut.Cmd == exported.Cmd &&
+		reflect.DeepEqual(customShortcut.GetScope(), exported.Scope) &&
+		reflect.DeepEqual(customShortcut.GetActions(), exported.Actions)
+}
+
+// checkConflicts 检查 item 的按键和现有的快捷键以及 claimed 中导入的按键是否冲突，
+// 导入后不再使用冲突按键的快捷键不算冲突。
+func (im *shortcutImporter) checkConflicts(item *importItem, pending map[string]*importItem,
+	claimed map[*shortcuts.Keystroke]*importItem) (conflicts []*importConflict, overwrites []*shortcuts.Keystroke) {
+
+	for _, ks := range item.keystrokes {
+		for ks0, owner := range claimed {
+			if owner != item && ks.Conflicts(ks0) {
+				conflicts = append(conflicts, &importConflict{
+					Keystroke: ks.String(),
+					Id:        owner.result.Id,
+					Type:      owner.result.Type,
+					Name:      owner.result.Name,
+					Imported:  true,
+				})
+			}
+		}
+
+		list, err := im.shortcutManager.FindConflictingKeystrokes(ks)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		for _, conflictKeystroke := range list {
+			owner := conflictKeystroke.Shortcut
+			if owner == nil || owner.GetUid() == item.uid() {
+				continue
+			}
+			if ownerItem, ok := pending[owner.GetUid()]; ok && ownerItem.releases(conflictKeystroke) {
+				continue
+			}
+			conflicts = append(conflicts, &importConflict{
+				Keystroke: ks.String(),
+				Id:        owner.GetId(),
+				Type:      owner.GetType(),
+				Name:      owner.GetName(),
+			})
+			if owner.GetKeystrokesModifiable() {
+				overwrites = append(overwrites, conflictKeystroke)
+			} else {
+				// 不能修改的快捷键的按键不能覆盖
+				overwrites = append(overwrites, nil)
+			}
+		}
+	}
+	return
+}
+
+func (im *shortcutImporter) importShortcuts(list []*exportedShortcut, strategy string) *importReport {
+	report := &importReport{
+		Strategy: strategy,
+		Results:  []*importResult{},
+	}
+	var items []*importItem
+	seen := make(map[string]bool)
+	for _, exported := range list {
+		item := im.newImportItem(exported)
+		if uid := item.uid(); uid != "" && item.result.Status != importStatusFailed {
+			if seen[uid] {
+				item.fail(errors.New("shortcut is duplicated"))
+			}
+			seen[uid] = true
+		}
+		items = append(items, item)
+		report.Results = append(report.Results, item.result)
+	}
+
+	// 有冲突而被跳过的快捷键保留原来的按键，可能使其他快捷键产生新的冲突，所以重复检查直到没有变化
+	for changed := true; changed; {
+		changed = false
+		pending := make(map[string]*importItem)
+		for _, item := range items {
+			if item.isPending() && item.shortcut != nil {
+				pending[item.uid()] = item
+			}
+		}
+		claimed := make(map[*shortcuts.Keystroke]*importItem)
+		for _, item := range items {
+			if !item.isPending() {
+				continue
+			}
+			conflicts, overwrites := im.checkConflicts(item, pending, claimed)
+			item.result.Conflicts = conflicts
+			item.overwrites = overwrites
+			if shouldSkipImport(conflicts, overwrites, strategy) {
+				if strategy == importStrategyDryRun {
+					item.result.Status = importStatusConflict
+				} else {
+					item.result.Status = importStatusSkipped
+				}
+				changed = true
+				break
+			}
+			for _, ks := range item.keystrokes {
+				claimed[ks] = item
+			}
+		}
+	}
+
+	for _, item := range items {
+		if !item.isPending() {
+			continue
+		}
+		if item.shortcut == nil {
+			item.result.Status = importStatusAdded
+		} else {
+			item.result.Status = importStatusModified
+		}
+	}
+
+	if strategy != importStrategyDryRun {
+		im.applyImport(items)
+	}
+	report.count()
+	return report
+}
+
+func shouldSkipImport(conflicts []*importConflict, overwrites []*shortcuts.Keystroke, strategy string) bool {
+	if len(conflicts) == 0 {
+		return false
+	}
+	if strategy != importStrategyOverwrite {
+		return true
+	}
+	for _, conflict := range conflicts {
+		if conflict.Imported {
+			return true
+		}
+	}
+	for _, ks := range overwrites {
+		if ks == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (im *shortcutImporter) applyImport(items []*importItem) {
+	var applied []*importItem
+	for _, item := range items {
+		if item.result.Status == importStatusAdded || item.result.Status == importStatusModified {
+			applied = append(applied, item)
+		}
+	}
+
+	// 记录原来的按键和被删除的按键的所有者，导入失败时恢复
+	oldKeystrokes := make(map[string][]*shortcuts.Keystroke)
+	owners := make(map[*shortcuts.Keystroke]shortcuts.Shortcut)
+
+	// 先删除冲突的按键和要修改的快捷键原来的按键，避免导入的快捷键之间交换按键时抓取失败
+	changes := make(map[string]shortcuts.Shortcut)
+	for _, item := range applied {
+		for _, ks := range item.overwrites {
+			owner := ks.Shortcut
+			if owner == nil {
+				continue
+			}
+			logger.Debugf("import %s: remove keystroke %s from %s", item.result.Id, ks, owner.GetId())
+			owners[ks] = owner
+			im.shortcutManager.DeleteShortcutKeystroke(owner, ks)
+			changes[owner.GetUid()] = owner
+		}
+		if item.shortcut != nil {
+			keystrokes := item.shortcut.GetKeystrokes()
+			oldKeystrokes[item.uid()] = append([]*shortcuts.Keystroke(nil), keystrokes...)
+			im.shortcutManager.ModifyShortcutKeystrokes(item.shortcut, nil)
+		}
+	}
+	for _, item := range applied {
+		delete(changes, item.uid())
+	}
+
+	for _, item := range applied {
+		var err error
+		if item.shortcut == nil {
+			err = im.addImportedCustomShortcut(item)
+		} else {
+			err = im.modifyImportedShortcut(item)
+		}
+		if err != nil {
+			logger.Warningf("failed to import shortcut %s: %v", item.result.Id, err)
+			item.fail(err)
+		}
+	}
+
+	// 所有快捷键都处理完后再恢复，这时才能知道哪些按键仍然空闲
+	for _, item := range applied {
+		if item.result.Status == importStatusFailed {
+			im.rollbackImport(item, oldKeystrokes, owners)
+		}
+	}
+
+	for _, shortcut := range changes {
+		err := shortcut.SaveKeystrokes()
+		if err != nil {
+			logger.Warning(err)
+		}
+		if shortcut.ShouldEmitSignalChanged() {
+			im.emitShortcutSignal(shortcutSignalChanged, shortcut)
+		}
+	}
+}
+
+// rollbackImport 在导入 item 失败后恢复它原来的按键和从其他快捷键删除的按键，
+// 已经被其他导入的快捷键使用的按键不会恢复。
+func (im *shortcutImporter) rollbackImport(item *importItem, oldKeystrokes map[string][]*shortcuts.Keystroke,
+	owners map[*shortcuts.Keystroke]shortcuts.Shortcut) {
+	isFree := func(ks *shortcuts.Keystroke) bool {
+		conflict, err := im.shortcutManager.FindConflictingKeystroke(ks)
+		return err == nil && conflict == nil
+	}
+
+	if item.shortcut != nil {
+		im.shortcutManager.ModifyShortcutKeystrokes(item.shortcut, nil)
+		var keystrokes []*shortcuts.Keystroke
+		for _, ks := range oldKeystrokes[item.uid()] {
+			if isFree(ks) {
+				keystrokes = append(keystrokes, ks)
+			} else {
+				logger.Warningf("rollback import %s: keystroke %s is used by others", item.result.Id, ks)
+			}
+		}
+		im.shortcutManager.ModifyShortcutKeystrokes(item.shortcut, keystrokes)
+		if item.shortcut.ShouldEmitSignalChanged() {
+			im.emitShortcutSignal(shortcutSignalChanged, item.shortcut)
+		}
+	}
+
+	for _, ks := range item.overwrites {
+		owner := owners[ks]
+		if owner == nil {
+			continue
+		}
+		if _, ok := oldKeystrokes[owner.GetUid()]; ok {
+			// owner 也是导入的快捷键，使用导入的按键
+			continue
+		}
+		if !isFree(ks) {
+			logger.Warningf("rollback import %s: keystroke %s is used by others", item.result.Id, ks)
+			continue
+		}
+		logger.Debugf("rollback import %s: restore keystroke %s to %s", item.result.Id, ks, owner.GetId())
+		im.shortcutManager.AddShortcutKeystroke(owner, ks)
+	}
+}
+
+func (im *shortcutImporter) addImportedCustomShortcut(item *importItem) error {
+	exported := item.exported
+	shortcut, err := im.customShortcutManager.Add(exported.Name, exported.Cmd, item.keystrokes, exported.Scope)
+	if err == nil && len(exported.Actions) > 0 {
+		customShortcut := shortcut.(*shortcuts.CustomShortcut)
+		customShortcut.SetActions(exported.Actions)
+		err = customShortcut.Save()
+	}
+	if err != nil {
+		if shortcut != nil {
+			// 没有添加到 shortcutManager，删除已经写入 custom.ini 的内容，以免重启后出现
+			deleteErr := im.customShortcutManager.Delete(shortcut.GetId())
+			if deleteErr != nil {
+				logger.Warningf("failed to delete custom shortcut %s: %v", shortcut.GetId(), deleteErr)
+			}
+		}
+		return err
+	}
+	item.result.Id = shortcut.GetId()
+	im.shortcutManager.Add(shortcut)
+	im.emitShortcutSignal(shortcutSignalAdded, shortcut)
+	return nil
+}
+
+func (im *shortcutImporter) modifyImportedShortcut(item *importItem) error {
+	shortcut := item.shortcut
+	customShortcut, ok := shortcut.(*shortcuts.CustomShortcut)
+	if !ok {
+		im.shortcutManager.ModifyShortcutKeystrokes(shortcut, item.keystrokes)
+		err := shortcut.SaveKeystrokes()
+		if err != nil {
+			return err
+		}
+		if shortcut.ShouldEmitSignalChanged() {
+			im.emitShortcutSignal(shortcutSignalChanged, shortcut)
+		}
+		return nil
+	}
+
+	exported := item.exported
+	customShortcut.SetName(exported.Name)
+	customShortcut.Cmd = exported.Cmd
+	customShortcut.SetActions(exported.Actions)
+	im.shortcutManager.ModifyShortcutScope(customShortcut, exported.Scope)
+	im.shortcutManager.ModifyShortcutKeystrokes(shortcut, item.keystrokes)
+	err := customShortcut.Save()
+	if err != nil {
+		return err
+	}
+	im.emitShortcutSignal(shortcutSignalChanged, shortcut)
+	return nil
+}
@@ -0,0 +1,342 @@
+package keybinding
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"pkg.deepin.io/dde/daemon/keybinding/shortcuts"
+)
+
+func TestParseExportedShortcuts(t *testing.T) {
+	Convey("parseExportedShortcuts", t, func(c C) {
+		data := `{"Version":1,"Shortcuts":[
+{"Id":"terminal","Type":0,"Name":"Terminal","Keystrokes":["<Control><Alt>T"]},
+{"Id":"abc","Type":1,"Name":"Editor","Keystrokes":["<Super>e"],"Cmd":"gedit","Scope":{"Include":["gedit"]}}]}`
+		exported, err := parseExportedShortcuts(data, importStrategyDryRun)
+		c.So(err, ShouldBeNil)
+		c.So(exported.Shortcuts, ShouldHaveLength, 2)
+		c.So(exported.Shortcuts[0].Keystrokes, ShouldResemble, []string{"<Control><Alt>T"})
+		c.So(exported.Shortcuts[1].Cmd, ShouldEqual, "gedit")
+		c.So(exported.Shortcuts[1].Scope.Include, ShouldResemble, []string{"gedit"})
+
+		_, err = parseExportedShortcuts(data, "merge")
+		c.So(err, ShouldNotBeNil)
+		_, err = parseExportedShortcuts(`{"Version":2,"Shortcuts":[]}`, importStrategySkip)
+		c.So(err, ShouldNotBeNil)
+		_, err = parseExportedShortcuts(`{"Shortcuts":[]}`, importStrategySkip)
+		c.So(err, ShouldNotBeNil)
+		_, err = parseExportedShortcuts(`[]`, importStrategyOverwrite)
+		c.So(err, ShouldNotBeNil)
+	})
+
+	Convey("importReport.count", t, func(c C) {
+		report := &importReport{
+			Results: []*importResult{
+				{Status: importStatusAdded},
+				{Status: importStatusModified},
+				{Status: importStatusModified},
+				{Status: importStatusSkipped},
+				{Status: importStatusFailed},
+			},
+		}
+		report.count()
+		c.So(report.Added, ShouldEqual, 1)
+		c.So(report.Modified, ShouldEqual, 2)
+		c.So(report.Skipped, ShouldEqual, 1)
+		c.So(report.Failed, ShouldEqual, 1)
+		c.So(report.Conflict, ShouldEqual, 0)
+	})
+
+	Convey("shouldSkipImport", t, func(c C) {
+		existing := []*importConflict{{Keystroke: "<Super>e", Id: "launcher"}}
+		imported := []*importConflict{{Keystroke: "<Super>e", Id: "abc", Imported: true}}
+		c.So(shouldSkipImport(nil, nil, importStrategySkip), ShouldBeFalse)
+		c.So(shouldSkipImport(existing, nil, importStrategySkip), ShouldBeTrue)
+		c.So(shouldSkipImport(existing, nil, importStrategyDryRun), ShouldBeTrue)
+		c.So(shouldSkipImport(imported, nil, importStrategyOverwrite), ShouldBeTrue)
+		c.So(shouldSkipImport(existing, []*shortcuts.Keystroke{{}}, importStrategyOverwrite), ShouldBeFalse)
+		// 不能修改的快捷键的按键
+		c.So(shouldSkipImport(existing, []*shortcuts.Keystroke{nil}, importStrategyOverwrite), ShouldBeTrue)
+	})
+}
+
+// testShortcut 是不需要 gsettings 的快捷键，SaveKeystrokes 返回 saveErr
+type testShortcut struct {
+	shortcuts.BaseShortcut
+	saveErr error
+	saved   int
+}
+
+func newTestShortcut(id string, keystrokes ...string) *testShortcut {
+	s := &testShortcut{
+		BaseShortcut: shortcuts.BaseShortcut{
+			Id:   id,
+			Type: shortcuts.ShortcutTypeSystem,
+			Name: id,
+		},
+	}
+	for _, str := range keystrokes {
+		ks, err := shortcuts.ParseKeystroke(str)
+		if err != nil {
+			panic(err)
+		}
+		s.Keystrokes = append(s.Keystrokes, ks)
+	}
+	return s
+}
+
+func (s *testShortcut) SaveKeystrokes() error {
+	s.saved++
+	return s.saveErr
+}
+
+func (s *testShortcut) ReloadKeystrokes() bool {
+	return false
+}
+
+func getKeystrokeStrings(s shortcuts.Shortcut) []string {
+	result := []string{}
+	for _, ks := range s.GetKeystrokes() {
+		result = append(result, ks.String())
+	}
+	return result
+}
+
+// testShortcutManager 只在内存中记录快捷键的按键，不抓取按键
+type testShortcutManager struct {
+	list []shortcuts.Shortcut
+}
+
+func newTestShortcutManager(list ...*testShortcut) *testShortcutManager {
+	sm := &testShortcutManager{}
+	for _, s := range list {
+		sm.Add(s)
+	}
+	return sm
+}
+
+func (sm *testShortcutManager) Add(shortcut shortcuts.Shortcut) {
+	sm.list = append(sm.list, shortcut)
+	for _, ks := range shortcut.GetKeystrokes() {
+		ks.Shortcut = shortcut
+	}
+}
+
+func (sm *testShortcutManager) GetByIdType(id string, type0 int32) shortcuts.Shortcut {
+	for _, s := range sm.list {
+		if s.GetId() == id && s.GetType() == type0 {
+			return s
+		}
+	}
+	return nil
+}
+
+func (sm *testShortcutManager) ListByType(type0 int32) []shortcuts.Shortcut {
+	var result []shortcuts.Shortcut
+	for _, s := range sm.list {
+		if s.GetType() == type0 {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (sm *testShortcutManager) FindConflictingKeystroke(ks *shortcuts.Keystroke) (*shortcuts.Keystroke, error) {
+	list, err := sm.FindConflictingKeystrokes(ks)
+	if len(list) == 0 {
+		return nil, err
+	}
+	return list[0], err
+}
+
+func (sm *testShortcutManager) FindConflictingKeystrokes(ks *shortcuts.Keystroke) ([]*shortcuts.Keystroke, error) {
+	var result []*shortcuts.Keystroke
+	for _, s := range sm.list {
+		for _, ks0 := range s.GetKeystrokes() {
+			if ks0.Conflicts(ks) {
+				result = append(result, ks0)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (sm *testShortcutManager) AddShortcutKeystroke(shortcut shortcuts.Shortcut, ks *shortcuts.Keystroke) {
+	keystrokes := append([]*shortcuts.Keystroke(nil), shortcut.GetKeystrokes()...)
+	sm.ModifyShortcutKeystrokes(shortcut, append(keystrokes, ks))
+}
+
+func (sm *testShortcutManager) DeleteShortcutKeystroke(shortcut shortcuts.Shortcut, ks *shortcuts.Keystroke) {
+	var keystrokes []*shortcuts.Keystroke
+	for _, ks0 := range shortcut.GetKeystrokes() {
+		if ks0.String() != ks.String() {
+			keystrokes = append(keystrokes, ks0)
+		}
+	}
+	sm.ModifyShortcutKeystrokes(shortcut, keystrokes)
+	ks.Shortcut = nil
+}
+
+func (sm *testShortcutManager) ModifyShortcutKeystrokes(shortcut shortcuts.Shortcut, keystrokes []*shortcuts.Keystroke) {
+	for _, ks := range shortcut.GetKeystrokes() {
+		ks.Shortcut = nil
+	}
+	shortcut.(*testShortcut).Keystrokes = keystrokes
+	for _, ks := range keystrokes {
+		ks.Shortcut = shortcut
+	}
+}
+
+func (sm *testShortcutManager) ModifyShortcutScope(shortcut *shortcuts.CustomShortcut, scope *shortcuts.ShortcutScope) {
+	shortcut.SetScope(scope)
+}
+
+func newTestImporter(list ...*testShortcut) *shortcutImporter {
+	return &shortcutImporter{
+		shortcutManager:    newTestShortcutManager(list...),
+		emitShortcutSignal: func(string, shortcuts.Shortcut) {},
+	}
+}
+
+func newExportedSystemShortcut(id string, keystrokes ...string) *exportedShortcut {
+	return &exportedShortcut{
+		Id:         id,
+		Type:       shortcuts.ShortcutTypeSystem,
+		Name:       id,
+		Keystrokes: keystrokes,
+	}
+}
+
+func TestShortcutImporter(t *testing.T) {
+	Convey("checkConflicts", t, func(c C) {
+		tests := []struct {
+			name string
+			// a 导入后的按键
+			keystroke string
+			// b 导入后的按键，为空时不导入 b
+			pendingB string
+			// 已经被导入的快捷键 x 使用的按键
+			claimed    string
+			conflicts  []string
+			imported   bool
+			overwrites int
+		}{
+			{"unchanged keystroke", "<Super>a", "", "", nil, false, 0},
+			{"conflicts with existing", "<Super>b", "", "", []string{"b"}, false, 1},
+			{"swap with pending", "<Super>b", "<Super>a", "", nil, false, 0},
+			{"pending keeps keystroke", "<Super>b", "<Super>b", "", []string{"b"}, false, 1},
+			{"conflicts with imported", "<Super>c", "", "<Super>c", []string{"x"}, true, 0},
+			{"key sequence prefix", "<Super>b, t", "", "", []string{"b"}, false, 1},
+		}
+		for _, test := range tests {
+			im := newTestImporter(newTestShortcut("a", "<Super>a"), newTestShortcut("b", "<Super>b"))
+			item := im.newImportItem(newExportedSystemShortcut("a", test.keystroke))
+			c.So(item.result.Status, ShouldNotEqual, importStatusFailed)
+			pending := map[string]*importItem{item.uid(): item}
+			if test.pendingB != "" {
+				itemB := im.newImportItem(newExportedSystemShortcut("b", test.pendingB))
+				pending[itemB.uid()] = itemB
+			}
+			claimed := make(map[*shortcuts.Keystroke]*importItem)
+			if test.claimed != "" {
+				itemX := &importItem{result: &importResult{Id: "x"}}
+				ks, err := shortcuts.ParseKeystroke(test.claimed)
+				c.So(err, ShouldBeNil)
+				claimed[ks] = itemX
+			}
+
+			conflicts, overwrites := im.checkConflicts(item, pending, claimed)
+			var ids []string
+			for _, conflict := range conflicts {
+				ids = append(ids, conflict.Id)
+				c.So(conflict.Imported, ShouldEqual, test.imported)
+			}
+			c.So(ids, ShouldResemble, test.conflicts)
+			c.So(overwrites, ShouldHaveLength, test.overwrites)
+		}
+	})
+
+	Convey("applyImport and rollbackImport", t, func(c C) {
+		tests := []struct {
+			name    string
+			imports []*exportedShortcut
+			// 保存按键失败的快捷键
+			failed   string
+			statuses []string
+			result   map[string][]string
+			// 保存了按键的没有导入的快捷键
+			saved string
+		}{
+			{
+				name: "swap",
+				imports: []*exportedShortcut{
+					newExportedSystemShortcut("a", "<Super>b"),
+					newExportedSystemShortcut("b", "<Super>a"),
+				},
+				statuses: []string{importStatusModified, importStatusModified},
+				result: map[string][]string{
+					"a": {"<Super>b"}, "b": {"<Super>a"}, "c": {"<Super>c"},
+				},
+			},
+			{
+				name:     "overwrite",
+				imports:  []*exportedShortcut{newExportedSystemShortcut("a", "<Super>b")},
+				statuses: []string{importStatusModified},
+				result: map[string][]string{
+					"a": {"<Super>b"}, "b": {}, "c": {"<Super>c"},
+				},
+				saved: "b",
+			},
+			{
+				name:     "rollback",
+				imports:  []*exportedShortcut{newExportedSystemShortcut("a", "<Super>b")},
+				failed:   "a",
+				statuses: []string{importStatusFailed},
+				result: map[string][]string{
+					"a": {"<Super>a"}, "b": {"<Super>b"}, "c": {"<Super>c"},
+				},
+				saved: "b",
+			},
+			{
+				name: "rollback after keystroke is taken",
+				imports: []*exportedShortcut{
+					newExportedSystemShortcut("a", "<Super>b"),
+					newExportedSystemShortcut("c", "<Super>a"),
+				},
+				failed:   "a",
+				statuses: []string{importStatusFailed, importStatusModified},
+				result: map[string][]string{
+					"a": {}, "b": {"<Super>b"}, "c": {"<Super>a"},
+				},
+				saved: "b",
+			},
+		}
+		for _, test := range tests {
+			list := []*testShortcut{
+				newTestShortcut("a", "<Super>a"),
+				newTestShortcut("b", "<Super>b"),
+				newTestShortcut("c", "<Super>c"),
+			}
+			for _, s := range list {
+				if s.Id == test.failed {
+					s.saveErr = errors.New("save failed")
+				}
+			}
+			im := newTestImporter(list...)
+
+			report := im.importShortcuts(test.imports, importStrategyOverwrite)
+			var statuses []string
+			for _, result := range report.Results {
+				statuses = append(statuses, result.Status)
+			}
+			c.So(statuses, ShouldResemble, test.statuses)
+			for _, s := range list {
+				c.So(getKeystrokeStrings(s), ShouldResemble, test.result[s.Id])
+				if s.Id == test.saved {
+					c.So(s.saved, ShouldEqual, 1)
+				}
+			}
+		}
+	})
+}
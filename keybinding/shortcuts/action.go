@@ -19,6 +19,10 @@
 
 package shortcuts
 
+import (
+	"pkg.deepin.io/lib/dbus1"
+)
+
 type ActionType uint
 
 const (
@@ -43,6 +47,11 @@ const (
 	ActionTypeToggleWireless
 	ActionTypeShowControlCenter
 
+	// custom
+	ActionTypeDBusCall
+	ActionTypeKeyMacro
+	ActionTypeChain // Arg is []*Action
+
 	// end
 	actionTypeMax
 )
@@ -72,6 +81,20 @@ func NewExecCmdAction(cmd string, execOnRelease bool) *Action {
 	}
 }
 
+// call D-Bus method
+type ActionDBusCallArg struct {
+	Bus    string // session or system
+	Dest   string
+	Path   dbus.ObjectPath
+	Method string // interface.method
+	Args   []interface{}
+}
+
+// replay keystrokes
+type ActionKeyMacroArg struct {
+	Keystrokes []*Keystroke
+}
+
 // run the program which default handle mimeType
 func NewOpenMimeTypeAction(mimeType string) *Action {
 	return &Action{
@@ -0,0 +1,229 @@
+package shortcuts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"pkg.deepin.io/lib/dbus1"
+)
+
+// 自定义快捷键除了执行命令，还可以调用 D-Bus 方法，按顺序触发其他快捷键（按键宏），
+// 多个动作按顺序组合执行。
+
+const (
+	CustomActionExec  = "exec"
+	CustomActionDBus  = "dbus"
+	CustomActionMacro = "macro"
+)
+
+// CustomAction 是自定义快捷键的一个动作，以 JSON 数组的形式保存在 custom.ini 的 Actions 中
+type CustomAction struct {
+	Type string
+
+	// exec
+	Cmd string `json:",omitempty"`
+
+	// dbus
+	Bus    string    `json:",omitempty"`
+	Dest   string    `json:",omitempty"`
+	Path   string    `json:",omitempty"`
+	Method string    `json:",omitempty"`
+	Args   []DBusArg `json:",omitempty"`
+
+	// macro
+	Keystrokes []string `json:",omitempty"`
+}
+
+// DBusArg 是 D-Bus 方法的参数，Type 是参数的类型签名
+type DBusArg struct {
+	Type  string
+	Value json.RawMessage
+}
+
+var dbusArgTypes = map[string]reflect.Type{
+	"y":  reflect.TypeOf(byte(0)),
+	"b":  reflect.TypeOf(false),
+	"n":  reflect.TypeOf(int16(0)),
+	"q":  reflect.TypeOf(uint16(0)),
+	"i":  reflect.TypeOf(int32(0)),
+	"u":  reflect.TypeOf(uint32(0)),
+	"x":  reflect.TypeOf(int64(0)),
+	"t":  reflect.TypeOf(uint64(0)),
+	"d":  reflect.TypeOf(float64(0)),
+	"s":  reflect.TypeOf(""),
+	"o":  reflect.TypeOf(dbus.ObjectPath("")),
+	"as": reflect.TypeOf([]string{}),
+	"ai": reflect.TypeOf([]int32{}),
+	"au": reflect.TypeOf([]uint32{}),
+}
+
+func (arg DBusArg) toValue() (interface{}, error) {
+	type0, ok := dbusArgTypes[arg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported D-Bus argument type %q", arg.Type)
+	}
+	ptr := reflect.New(type0)
+	err := json.Unmarshal(arg.Value, ptr.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("invalid D-Bus argument value %s for type %q: %v", arg.Value, arg.Type, err)
+	}
+	value := ptr.Elem().Interface()
+	if path, ok := value.(dbus.ObjectPath); ok && !path.IsValid() {
+		return nil, fmt.Errorf("invalid object path %q", path)
+	}
+	return value, nil
+}
+
+func (a *CustomAction) toAction() (*Action, error) {
+	switch a.Type {
+	case CustomActionExec:
+		if a.Cmd == "" {
+			return nil, errors.New("cmd is empty")
+		}
+		return NewExecCmdAction(a.Cmd, false), nil
+
+	case CustomActionDBus:
+		return a.toDBusCallAction()
+
+	case CustomActionMacro:
+		if len(a.Keystrokes) == 0 {
+			return nil, errors.New("keystrokes is empty")
+		}
+		arg := &ActionKeyMacroArg{}
+		for _, str := range a.Keystrokes {
+			ks, err := ParseKeystroke(str)
+			if err != nil {
+				return nil, err
+			}
+			arg.Keystrokes = append(arg.Keystrokes, ks)
+		}
+		return &Action{Type: ActionTypeKeyMacro, Arg: arg}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown action type %q", a.Type)
+	}
+}
+
+func (a *CustomAction) toDBusCallAction() (*Action, error) {
+	bus := a.Bus
+	if bus == "" {
+		bus = "session"
+	}
+	if bus != "session" && bus != "system" {
+		return nil, fmt.Errorf("invalid bus %q", a.Bus)
+	}
+	if a.Dest == "" {
+		return nil, errors.New("dest is empty")
+	}
+	path := dbus.ObjectPath(a.Path)
+	if !path.IsValid() {
+		return nil, fmt.Errorf("invalid object path %q", a.Path)
+	}
+	if strings.LastIndexByte(a.Method, '.') <= 0 {
+		return nil, fmt.Errorf("method %q should be interface.method", a.Method)
+	}
+
+	arg := &ActionDBusCallArg{
+		Bus:    bus,
+		Dest:   a.Dest,
+		Path:   path,
+		Method: a.Method,
+	}
+	for _, dbusArg := range a.Args {
+		value, err := dbusArg.toValue()
+		if err != nil {
+			return nil, err
+		}
+		arg.Args = append(arg.Args, value)
+	}
+	return &Action{Type: ActionTypeDBusCall, Arg: arg}, nil
+}
+
+// ParseCustomActions 解析 JSON 格式的动作列表，空字符串表示没有。
+func ParseCustomActions(str string) ([]*CustomAction, error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+	var actions []*CustomAction
+	err := json.Unmarshal([]byte(str), &actions)
+	if err != nil {
+		return nil, err
+	}
+	_, err = customActionsToAction(actions)
+	if err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// CheckCustomActions 检查动作列表是否有效，为空时有效。
+func CheckCustomActions(actions []*CustomAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	_, err := customActionsToAction(actions)
+	return err
+}
+
+// customActionsToAction 把多个动作转换为 ActionTypeChain 类型的 Action
+func customActionsToAction(actions []*CustomAction) (*Action, error) {
+	if len(actions) == 0 {
+		return nil, errors.New("actions is empty")
+	}
+	var list []*Action
+	for i, a := range actions {
+		action, err := a.toAction()
+		if err != nil {
+			return nil, fmt.Errorf("action %d: %v", i, err)
+		}
+		list = append(list, action)
+	}
+	if len(list) == 1 {
+		return list[0], nil
+	}
+	return &Action{Type: ActionTypeChain, Arg: list}, nil
+}
+
+// EmitKeystroke 和按下 ks 一样触发对应的快捷键，用于按键宏，depth 见 KeyEvent.Depth
+func (sm *ShortcutManager) EmitKeystroke(ks *Keystroke, depth int) error {
+	key, err := ks.ToKey(sm.keySymbols)
+	if err != nil {
+		return err
+	}
+	if !ks.IsSequence() {
+		sm.emitKeyEvent(ks.Mods, key, depth)
+		return nil
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	var matched *Keystroke
+	for _, seq := range sm.keySequenceMap[key] {
+		if len(seq.follows) == len(ks.follows) && isPrefixConflict(seq, ks) {
+			matched = seq
+			break
+		}
+	}
+	sm.keyKeystrokeMapMu.Unlock()
+	if matched == nil || matched.Shortcut == nil || sm.isScopeInactive(matched.Shortcut) {
+		logger.Debug("key sequence not found:", ks)
+		return nil
+	}
+	sm.callEventCallback(&KeyEvent{
+		Mods:     key.Mods,
+		Code:     key.Code,
+		Shortcut: matched.Shortcut,
+		Depth:    depth,
+	})
+	return nil
+}
+
+// EmitAction 执行 action，用于组合动作，depth 见 KeyEvent.Depth
+func (sm *ShortcutManager) EmitAction(action *Action, depth int) {
+	sm.callEventCallback(&KeyEvent{
+		Shortcut: NewFakeShortcut(action),
+		Depth:    depth,
+	})
+}
@@ -0,0 +1,87 @@
+package shortcuts
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"pkg.deepin.io/lib/dbus1"
+)
+
+func TestParseCustomActions(t *testing.T) {
+	Convey("ParseCustomActions", t, func(c C) {
+		actions, err := ParseCustomActions("")
+		c.So(err, ShouldBeNil)
+		c.So(actions, ShouldBeNil)
+
+		actions, err = ParseCustomActions(`[{"Type":"dbus","Dest":"com.deepin.dde.osd","Path":"/",
+"Method":"com.deepin.dde.osd.ShowOSD","Args":[{"Type":"s","Value":"CapsLockOn"}]}]`)
+		c.So(err, ShouldBeNil)
+		c.So(actions, ShouldHaveLength, 1)
+
+		action, err := customActionsToAction(actions)
+		c.So(err, ShouldBeNil)
+		c.So(action.Type, ShouldEqual, ActionTypeDBusCall)
+		arg := action.Arg.(*ActionDBusCallArg)
+		c.So(arg.Bus, ShouldEqual, "session")
+		c.So(arg.Path, ShouldEqual, dbus.ObjectPath("/"))
+		c.So(arg.Args, ShouldResemble, []interface{}{"CapsLockOn"})
+
+		actions, err = ParseCustomActions(`[{"Type":"exec","Cmd":"deepin-terminal"},
+{"Type":"macro","Keystrokes":["<Control>c","<Super>x, t"]}]`)
+		c.So(err, ShouldBeNil)
+		action, err = customActionsToAction(actions)
+		c.So(err, ShouldBeNil)
+		c.So(action.Type, ShouldEqual, ActionTypeChain)
+		list := action.Arg.([]*Action)
+		c.So(list, ShouldHaveLength, 2)
+		c.So(list[0].Type, ShouldEqual, ActionTypeExecCmd)
+		c.So(list[1].Type, ShouldEqual, ActionTypeKeyMacro)
+		macro := list[1].Arg.(*ActionKeyMacroArg)
+		c.So(macro.Keystrokes, ShouldHaveLength, 2)
+		c.So(macro.Keystrokes[1].IsSequence(), ShouldBeTrue)
+
+		invalids := []string{
+			`{}`,
+			`[]`,
+			`[{"Type":"unknown"}]`,
+			`[{"Type":"exec"}]`,
+			`[{"Type":"macro","Keystrokes":["<Control>"]}]`,
+			`[{"Type":"dbus","Bus":"other","Dest":"a.b","Path":"/","Method":"a.b.C"}]`,
+			`[{"Type":"dbus","Dest":"a.b","Path":"a/b","Method":"a.b.C"}]`,
+			`[{"Type":"dbus","Dest":"a.b","Path":"/","Method":"Call"}]`,
+		}
+		for _, str := range invalids {
+			_, err = ParseCustomActions(str)
+			c.So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("DBusArg.toValue", t, func(c C) {
+		tests := []struct {
+			arg   DBusArg
+			value interface{}
+		}{
+			{DBusArg{"b", []byte(`true`)}, true},
+			{DBusArg{"i", []byte(`-1`)}, int32(-1)},
+			{DBusArg{"u", []byte(`1`)}, uint32(1)},
+			{DBusArg{"d", []byte(`0.5`)}, 0.5},
+			{DBusArg{"o", []byte(`"/com/deepin"`)}, dbus.ObjectPath("/com/deepin")},
+			{DBusArg{"as", []byte(`["a","b"]`)}, []string{"a", "b"}},
+		}
+		for _, test := range tests {
+			value, err := test.arg.toValue()
+			c.So(err, ShouldBeNil)
+			c.So(value, ShouldResemble, test.value)
+		}
+
+		for _, arg := range []DBusArg{
+			{"v", []byte(`1`)},
+			{"u", []byte(`-1`)},
+			{"s", []byte(`1`)},
+			{"o", []byte(`"com/deepin"`)},
+		} {
+			_, err := arg.toValue()
+			c.So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -20,6 +20,7 @@
 package shortcuts
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -33,12 +34,18 @@ const (
 	kfKeyName       = "Name"
 	kfKeyKeystrokes = "Accels"
 	kfKeyAction     = "Action"
+
+	kfKeyScopeInclude = "ScopeInclude"
+	kfKeyScopeExclude = "ScopeExclude"
+	kfKeyActions      = "Actions"
 )
 
 type CustomShortcut struct {
 	BaseShortcut
 	manager *CustomShortcutManager
-	Cmd     string `json:"Exec"`
+	Cmd     string          `json:"Exec"`
+	Scope   *ShortcutScope  `json:",omitempty"`
+	Actions []*CustomAction `json:",omitempty"`
 }
 
 func (cs *CustomShortcut) Marshal() (string, error) {
@@ -71,10 +78,45 @@ func (cs *CustomShortcut) Save() error {
 	kfile.SetString(section, kfKeyName, cs.Name)
 	kfile.SetString(section, kfKeyAction, cs.Cmd)
 	kfile.SetStringList(section, kfKeyKeystrokes, cs.getKeystrokesStrv())
+	cs.manager.setScope(section, cs.GetScope())
+	cs.manager.setActions(section, cs.GetActions())
 	return cs.manager.Save()
 }
 
+func (cs *CustomShortcut) GetActions() []*CustomAction {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.Actions
+}
+
+// SetActions 设置动作，为空时执行 Cmd
+func (cs *CustomShortcut) SetActions(actions []*CustomAction) {
+	cs.mu.Lock()
+	cs.Actions = actions
+	cs.mu.Unlock()
+}
+
+func (cs *CustomShortcut) GetScope() *ShortcutScope {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.Scope
+}
+
+func (cs *CustomShortcut) SetScope(scope *ShortcutScope) {
+	cs.mu.Lock()
+	cs.Scope = scope
+	cs.mu.Unlock()
+}
+
 func (cs *CustomShortcut) GetAction() *Action {
+	if actions := cs.GetActions(); len(actions) > 0 {
+		action, err := customActionsToAction(actions)
+		if err == nil {
+			return action
+		}
+		logger.Warningf("invalid actions of custom shortcut %s: %v", cs.Id, err)
+	}
+
 	_, err := os.Stat(cs.Cmd)
 	if !os.IsNotExist(err) {
 		if strings.HasSuffix(cs.Cmd, ".desktop") {
@@ -131,6 +173,13 @@ func (csm *CustomShortcutManager) List() []Shortcut {
 		name, _ := kfile.GetString(section, kfKeyName)
 		cmd, _ := kfile.GetString(section, kfKeyAction)
 		keystrokes, _ := kfile.GetStringList(section, kfKeyKeystrokes)
+		include, _ := kfile.GetStringList(section, kfKeyScopeInclude)
+		exclude, _ := kfile.GetStringList(section, kfKeyScopeExclude)
+		actionsJSON, _ := kfile.GetString(section, kfKeyActions)
+		actions, err := ParseCustomActions(actionsJSON)
+		if err != nil {
+			logger.Warningf("failed to parse actions of custom shortcut %s: %v", id, err)
+		}
 
 		shortcut := &CustomShortcut{
 			BaseShortcut: BaseShortcut{
@@ -141,6 +190,8 @@ func (csm *CustomShortcutManager) List() []Shortcut {
 			},
 			manager: csm,
 			Cmd:     cmd,
+			Scope:   NewShortcutScope(include, exclude),
+			Actions: actions,
 		}
 
 		ret = append(ret, shortcut)
@@ -156,10 +207,35 @@ func (csm *CustomShortcutManager) Save() error {
 	return csm.kfile.SaveToFile(csm.file)
 }
 
-func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystroke) (Shortcut, error) {
+func (csm *CustomShortcutManager) setScope(section string, scope *ShortcutScope) {
+	var include, exclude []string
+	if scope != nil {
+		include = scope.Include
+		exclude = scope.Exclude
+	}
+	csm.kfile.SetStringList(section, kfKeyScopeInclude, include)
+	csm.kfile.SetStringList(section, kfKeyScopeExclude, exclude)
+}
+
+func (csm *CustomShortcutManager) setActions(section string, actions []*CustomAction) {
+	var str string
+	if len(actions) > 0 {
+		data, err := json.Marshal(actions)
+		if err != nil {
+			logger.Warning(err)
+			return
+		}
+		str = string(data)
+	}
+	csm.kfile.SetString(section, kfKeyActions, str)
+}
+
+func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystroke,
+	scope *ShortcutScope) (Shortcut, error) {
 	id := dutils.GenUuid()
 	csm.kfile.SetString(id, kfKeyName, name)
 	csm.kfile.SetString(id, kfKeyAction, action)
+	csm.setScope(id, scope)
 
 	keystrokesStrv := make([]string, 0, len(keystrokes))
 	for _, ks := range keystrokes {
@@ -176,6 +252,7 @@ func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystro
 		},
 		manager: csm,
 		Cmd:     action,
+		Scope:   scope,
 	}
 	return shortcut, csm.Save()
 }
@@ -0,0 +1,228 @@
+package shortcuts
+
+import (
+	"strings"
+	"time"
+
+	"github.com/linuxdeepin/go-x11-client/util/keybind"
+	"github.com/linuxdeepin/go-x11-client/util/keysyms"
+)
+
+// 按键序列只抓取第一个组合键，按下后抓取键盘，在超时前依次匹配之后的组合键。
+
+const keySequenceTimeout = 2 * time.Second
+
+type KeySequenceHintFunc func(prefix string, keys, names []string)
+
+type keySequenceState struct {
+	// 还可能匹配的按键序列
+	candidates []*Keystroke
+	// 下一个要匹配的组合键在 follows 中的位置
+	step   int
+	prefix string
+	timer  *time.Timer
+}
+
+// matchKeySequences 用组合键 ks 匹配 candidates 中第 step 个 follows，
+// 返回完全匹配的按键序列和还没有结束的按键序列。
+func matchKeySequences(candidates []*Keystroke, step int, ks *Keystroke) (matched *Keystroke, rest []*Keystroke) {
+	for _, candidate := range candidates {
+		if step >= len(candidate.follows) || !candidate.follows[step].matchStep(ks) {
+			continue
+		}
+		if step == len(candidate.follows)-1 {
+			if matched == nil {
+				matched = candidate
+			}
+		} else {
+			rest = append(rest, candidate)
+		}
+	}
+	return
+}
+
+// getKeySequenceHint 返回 candidates 中从第 step 个 follows 开始的剩余按键和快捷键名称
+func getKeySequenceHint(candidates []*Keystroke, step int) (keys, names []string) {
+	for _, candidate := range candidates {
+		var rest []string
+		for _, follow := range candidate.follows[step:] {
+			rest = append(rest, follow.String())
+		}
+		keys = append(keys, strings.Join(rest, keySequenceSep+" "))
+
+		var name string
+		if candidate.Shortcut != nil {
+			name = candidate.Shortcut.GetName()
+		}
+		names = append(names, name)
+	}
+	return
+}
+
+func (sm *ShortcutManager) SetKeySequenceHintCallback(cb KeySequenceHintFunc) {
+	sm.keySequenceHintCb = cb
+}
+
+func (sm *ShortcutManager) emitKeySequenceHint(prefix string, keys, names []string) {
+	if sm.keySequenceHintCb != nil {
+		sm.keySequenceHintCb(prefix, keys, names)
+	}
+}
+
+// grabKeySequence 抓取按键序列的第一个组合键，多个按键序列可以使用相同的第一个组合键，
+// 相同的按键重复抓取不会出错。
+func (sm *ShortcutManager) grabKeySequence(shortcut Shortcut, ks *Keystroke, keyList []Key, dummy bool) {
+	var conflictCount int
+	for _, key := range keyList {
+		sm.keyKeystrokeMapMu.Lock()
+		conflictKeystroke, ok := sm.keyKeystrokeMap[key]
+		if ok {
+			sm.keyKeystrokeMapMu.Unlock()
+			conflictCount++
+			if conflictKeystroke.Shortcut != nil {
+				logger.Debugf("key %v is grabed by %v", key, conflictKeystroke.Shortcut.GetId())
+			}
+			continue
+		}
+
+		sequences := sm.keySequenceMap[key]
+		if !dummy {
+			err := key.Grab(sm.conn)
+			if err != nil {
+				sm.keyKeystrokeMapMu.Unlock()
+				logger.Debug(err)
+				continue
+			}
+		}
+		sm.keySequenceMap[key] = append(sequences, ks)
+		sm.keyKeystrokeMapMu.Unlock()
+	}
+
+	if conflictCount == len(keyList) && !sm.EliminateConflictDone {
+		sm.storeConflictingKeystroke(ks)
+	}
+}
+
+func (sm *ShortcutManager) ungrabKeySequence(ks *Keystroke, keyList []Key, dummy bool) {
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	for _, key := range keyList {
+		sequences := sm.keySequenceMap[key]
+		var newSequences []*Keystroke
+		for _, seq := range sequences {
+			if len(seq.follows) == len(ks.follows) && isPrefixConflict(seq, ks) {
+				continue
+			}
+			newSequences = append(newSequences, seq)
+		}
+
+		if len(newSequences) > 0 {
+			sm.keySequenceMap[key] = newSequences
+			sm.syncKeyGrab(key)
+			continue
+		}
+		delete(sm.keySequenceMap, key)
+		if len(sequences) > 0 && !dummy {
+			key.Ungrab(sm.conn)
+		}
+	}
+}
+
+// findConflictByKey 查找和 ks 冲突的按键，单个组合键和以它开始的按键序列冲突，
+// 第一个组合键相同的按键序列中一个是另一个的前缀时冲突。
+// 调用者需要持有 keyKeystrokeMapMu
+func (sm *ShortcutManager) findConflictByKey(ks *Keystroke, key Key) (*Keystroke, bool) {
+	if conflictKeystroke, ok := sm.keyKeystrokeMap[key]; ok {
+		return conflictKeystroke, true
+	}
+	for _, seq := range sm.keySequenceMap[key] {
+		if !ks.IsSequence() || isPrefixConflict(ks, seq) {
+			return seq, true
+		}
+	}
+	return nil, false
+}
+
+func (sm *ShortcutManager) startKeySequence(sequences []*Keystroke) {
+	sm.keySequenceMu.Lock()
+	defer sm.keySequenceMu.Unlock()
+	if sm.keySequence != nil {
+		return
+	}
+
+	rootWin := sm.conn.GetDefaultScreen().Root
+	err := keybind.GrabKeyboard(sm.conn, rootWin)
+	if err != nil {
+		logger.Warning("failed to grab keyboard for key sequence:", err)
+		return
+	}
+
+	state := &keySequenceState{
+		candidates: sequences,
+		prefix:     sequences[0].Steps()[0].String(),
+	}
+	state.timer = time.AfterFunc(keySequenceTimeout, func() {
+		sm.keySequenceMu.Lock()
+		if sm.keySequence == state {
+			logger.Debug("key sequence timeout:", state.prefix)
+			sm.endKeySequence()
+		}
+		sm.keySequenceMu.Unlock()
+	})
+	sm.keySequence = state
+	logger.Debug("start key sequence:", state.prefix)
+	keys, names := getKeySequenceHint(state.candidates, 0)
+	sm.emitKeySequenceHint(state.prefix, keys, names)
+}
+
+// endKeySequence 结束按键序列，调用者需要持有 keySequenceMu
+func (sm *ShortcutManager) endKeySequence() {
+	state := sm.keySequence
+	if state == nil {
+		return
+	}
+	state.timer.Stop()
+	sm.keySequence = nil
+	keybind.UngrabKeyboard(sm.conn)
+	sm.emitKeySequenceHint("", nil, nil)
+}
+
+// handleKeySequenceKey 在按键序列进行中时匹配按下的组合键，返回 true 表示按键已被按键序列处理。
+func (sm *ShortcutManager) handleKeySequenceKey(key Key) bool {
+	sm.keySequenceMu.Lock()
+	state := sm.keySequence
+	if state == nil {
+		sm.keySequenceMu.Unlock()
+		return false
+	}
+
+	ks := key.ToKeystroke(sm.keySymbols)
+	if ks == nil || keysyms.IsModifierKey(ks.Keysym) {
+		// 等待按下非修饰键
+		sm.keySequenceMu.Unlock()
+		return true
+	}
+
+	matched, rest := matchKeySequences(state.candidates, state.step, ks)
+	if matched != nil || len(rest) == 0 {
+		logger.Debugf("end key sequence %s, %s matched: %v", state.prefix, ks, matched != nil)
+		sm.endKeySequence()
+	} else {
+		state.candidates = rest
+		state.step++
+		state.prefix += keySequenceSep + " " + ks.String()
+		state.timer.Reset(keySequenceTimeout)
+		keys, names := getKeySequenceHint(state.candidates, state.step)
+		sm.emitKeySequenceHint(state.prefix, keys, names)
+	}
+	sm.keySequenceMu.Unlock()
+
+	if matched != nil && matched.Shortcut != nil {
+		sm.callEventCallback(&KeyEvent{
+			Mods:     key.Mods,
+			Code:     key.Code,
+			Shortcut: matched.Shortcut,
+		})
+	}
+	return true
+}
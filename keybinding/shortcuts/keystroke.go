@@ -31,6 +31,7 @@ import (
 
 // Keystroke
 // field Mods ignore mod2(Num_Lock) and lock(Caps_Lock)
+// 按键序列（如 <Super>w, t）的第一个组合键保存在 Mods，Keystr，Keysym 中，之后的保存在 follows 中
 type Keystroke struct {
 	Mods     Modifiers
 	Keystr   string
@@ -38,6 +39,22 @@ type Keystroke struct {
 	Shortcut Shortcut
 
 	isKeystrAboveTab bool
+	follows          []*Keystroke
+}
+
+// 按键序列中组合键之间的分隔符
+const keySequenceSep = ","
+
+// IsSequence 判断 ks 是否是由多个组合键组成的按键序列
+func (ks *Keystroke) IsSequence() bool {
+	return len(ks.follows) > 0
+}
+
+// Steps 返回按键序列中的所有组合键，第一个是不包含 follows 的 ks
+func (ks *Keystroke) Steps() []*Keystroke {
+	leader := *ks
+	leader.follows = nil
+	return append([]*Keystroke{&leader}, ks.follows...)
 }
 
 func (ks *Keystroke) DebugString() string {
@@ -51,6 +68,15 @@ func (ks *Keystroke) DebugString() string {
 
 func (a *Keystroke) Equal(keySymbols *keysyms.KeySymbols, b *Keystroke) bool {
 	logger.Debug(a, " equal? ", b)
+	if len(a.follows) != len(b.follows) {
+		logger.Debug("sequence length no equal, return false")
+		return false
+	}
+	for i, follow := range a.follows {
+		if !follow.matchStep(b.follows[i]) {
+			return false
+		}
+	}
 	if a.Mods != b.Mods {
 		logger.Debug("Mods no equal, return false")
 		return false
@@ -209,6 +235,51 @@ func splitKeystroke(str string) ([]string, error) {
 	return keys, nil
 }
 
+// matchStep 判断按键序列中的一个组合键 a 和 b 是否相同，不区分大小写。
+func (a *Keystroke) matchStep(b *Keystroke) bool {
+	return a.Mods == b.Mods && strings.EqualFold(a.Keystr, b.Keystr)
+}
+
+// isPrefixConflict 判断按键序列 a 和 b 第一个组合键之后的部分是否一个是另一个的前缀（包括相同），
+// 这时较短的序列会使较长的无法触发。调用者需要保证 a 和 b 的第一个组合键相同。
+func isPrefixConflict(a, b *Keystroke) bool {
+	n := len(a.follows)
+	if len(b.follows) < n {
+		n = len(b.follows)
+	}
+	for i := 0; i < n; i++ {
+		if !a.follows[i].matchStep(b.follows[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Conflicts 判断 ks 和 other 是否冲突，即第一个组合键相同，并且一个是另一个的前缀。
+func (ks *Keystroke) Conflicts(other *Keystroke) bool {
+	return ks.matchStep(other) && isPrefixConflict(ks, other)
+}
+
+// ParseKeystroke 解析组合键或者用逗号分隔的按键序列，如 <Super>w, t
+func ParseKeystroke(keystroke string) (*Keystroke, error) {
+	parts := strings.Split(keystroke, keySequenceSep)
+	ks, err := parseChord(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts[1:] {
+		follow, err := parseChord(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if keysyms.IsModifierKey(follow.Keysym) || follow.isKeystrAboveTab {
+			return nil, errors.New("bad key in sequence " + follow.Keystr)
+		}
+		ks.follows = append(ks.follows, follow)
+	}
+	return ks, nil
+}
+
 // <Super>L mods (mod4) key L
 // <Super>% mods (mod4, shift) key %
 // <Control><Alt>T mods (control,mod1) key T
@@ -220,7 +291,7 @@ func splitKeystroke(str string) ([]string, error) {
 // Print mods() key Print
 // <Control>Print mods(Control) key Print
 // check Keystroke.Keystr valid later
-func ParseKeystroke(keystroke string) (*Keystroke, error) {
+func parseChord(keystroke string) (*Keystroke, error) {
 	parts, err := splitKeystroke(keystroke)
 	if err != nil {
 		return nil, err
@@ -296,7 +367,11 @@ func (ks *Keystroke) String() string {
 	}
 
 	keys = append(keys, ks.Keystr)
-	return strings.Join(keys, "")
+	str := strings.Join(keys, "")
+	for _, follow := range ks.follows {
+		str += keySequenceSep + " " + follow.String()
+	}
+	return str
 }
 
 func (ks *Keystroke) searchString() string {
@@ -322,6 +397,9 @@ func (ks *Keystroke) searchString() string {
 		strs = append(strs, strings.ToLower(ks.Keystr))
 	}
 
+	for _, follow := range ks.follows {
+		strs = append(strs, follow.searchString())
+	}
 	return strings.Join(strs, "")
 }
 
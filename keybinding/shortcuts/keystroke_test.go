@@ -128,3 +128,99 @@ func TestKeystrokeMethodString(t *testing.T) {
 		c.So(ks.String(), ShouldEqual, "<Shift><Control><Alt><Super>T")
 	})
 }
+
+func TestParseKeySequence(t *testing.T) {
+	Convey("ParseKeystroke key sequence", t, func(c C) {
+		ks, err := ParseKeystroke("<Super>w, t")
+		c.So(err, ShouldBeNil)
+		c.So(ks.IsSequence(), ShouldBeTrue)
+		c.So(ks.Mods, ShouldEqual, keysyms.ModMaskSuper)
+		c.So(ks.Keystr, ShouldEqual, "w")
+		c.So(ks.follows, ShouldResemble, []*Keystroke{
+			{Keystr: "t", Keysym: keysyms.XK_t},
+		})
+		c.So(ks.String(), ShouldEqual, "<Super>w, t")
+
+		steps := ks.Steps()
+		c.So(steps, ShouldHaveLength, 2)
+		c.So(steps[0].IsSequence(), ShouldBeFalse)
+		c.So(ks.IsSequence(), ShouldBeTrue)
+
+		ks, err = ParseKeystroke("<Control>x,<Control>s")
+		c.So(err, ShouldBeNil)
+		c.So(ks.String(), ShouldEqual, "<Control>x, <Control>s")
+
+		ks, err = ParseKeystroke("<Super>w")
+		c.So(err, ShouldBeNil)
+		c.So(ks.IsSequence(), ShouldBeFalse)
+
+		// abnormal situation:
+		_, err = ParseKeystroke("<Super>w,")
+		c.So(err, ShouldNotBeNil)
+
+		_, err = ParseKeystroke("<Super>w, Shift_L")
+		c.So(err, ShouldNotBeNil)
+	})
+}
+
+func TestKeySequenceConflict(t *testing.T) {
+	Convey("isPrefixConflict", t, func(c C) {
+		parse := func(str string) *Keystroke {
+			ks, err := ParseKeystroke(str)
+			c.So(err, ShouldBeNil)
+			return ks
+		}
+		c.So(isPrefixConflict(parse("<Super>w, t"), parse("<Super>w, T")), ShouldBeTrue)
+		c.So(isPrefixConflict(parse("<Super>w, t"), parse("<Super>w, t, a")), ShouldBeTrue)
+		c.So(isPrefixConflict(parse("<Super>w, t"), parse("<Super>w, b")), ShouldBeFalse)
+		c.So(isPrefixConflict(parse("<Super>w, t"), parse("<Super>w, <Shift>t")), ShouldBeFalse)
+	})
+
+	Convey("Keystroke.Conflicts", t, func(c C) {
+		parse := func(str string) *Keystroke {
+			ks, err := ParseKeystroke(str)
+			c.So(err, ShouldBeNil)
+			return ks
+		}
+		c.So(parse("<Super>t").Conflicts(parse("<Super>T")), ShouldBeTrue)
+		c.So(parse("<Super>w").Conflicts(parse("<Super>w, t")), ShouldBeTrue)
+		c.So(parse("<Super>w, t").Conflicts(parse("<Super>w, t, a")), ShouldBeTrue)
+		c.So(parse("<Super>w, t").Conflicts(parse("<Super>w, b")), ShouldBeFalse)
+		c.So(parse("<Super>w").Conflicts(parse("<Control>w")), ShouldBeFalse)
+		c.So(parse("<Super>w, t").Conflicts(parse("<Super>t, w")), ShouldBeFalse)
+	})
+}
+
+func TestMatchKeySequences(t *testing.T) {
+	Convey("matchKeySequences", t, func(c C) {
+		parse := func(str string) *Keystroke {
+			ks, err := ParseKeystroke(str)
+			c.So(err, ShouldBeNil)
+			return ks
+		}
+		wt := parse("<Super>w, t")
+		wba := parse("<Super>w, b, a")
+		wbc := parse("<Super>w, b, c")
+		candidates := []*Keystroke{wt, wba, wbc}
+
+		keys, names := getKeySequenceHint(candidates, 0)
+		c.So(keys, ShouldResemble, []string{"t", "b, a", "b, c"})
+		c.So(names, ShouldResemble, []string{"", "", ""})
+
+		matched, rest := matchKeySequences(candidates, 0, parse("T"))
+		c.So(matched, ShouldEqual, wt)
+		c.So(rest, ShouldBeEmpty)
+
+		matched, rest = matchKeySequences(candidates, 0, parse("b"))
+		c.So(matched, ShouldBeNil)
+		c.So(rest, ShouldResemble, []*Keystroke{wba, wbc})
+
+		matched, rest = matchKeySequences(rest, 1, parse("c"))
+		c.So(matched, ShouldEqual, wbc)
+		c.So(rest, ShouldBeEmpty)
+
+		matched, rest = matchKeySequences(candidates, 0, parse("Escape"))
+		c.So(matched, ShouldBeNil)
+		c.So(rest, ShouldBeEmpty)
+	})
+}
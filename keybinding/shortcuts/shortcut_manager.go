@@ -64,6 +64,18 @@ type ShortcutManager struct {
 	keyKeystrokeMapMu sync.Mutex
 	keySymbols        *keysyms.KeySymbols
 
+	// 按键序列的第一个组合键 => 按键序列，和 keyKeystrokeMap 一起由 keyKeystrokeMapMu 保护
+	keySequenceMap    map[Key][]*Keystroke
+	keySequence       *keySequenceState
+	keySequenceMu     sync.Mutex
+	keySequenceHintCb KeySequenceHintFunc
+
+	// 活动窗口和因此不生效的快捷键的 uid
+	activeWindow        *WindowContext
+	inactiveShortcuts   map[string]bool
+	scopeMu             sync.Mutex
+	atomNetActiveWindow x.Atom
+
 	recordEnable        bool
 	recordEnableMu      sync.Mutex
 	recordContext       record.Context
@@ -81,6 +93,8 @@ type KeyEvent struct {
 	Mods     Modifiers
 	Code     Keycode
 	Shortcut Shortcut
+	// 由按键宏或者组合动作产生时大于 0，是嵌套的层数
+	Depth int
 }
 
 func NewShortcutManager(conn *x.Conn, keySymbols *keysyms.KeySymbols, eventCb KeyEventFunc) *ShortcutManager {
@@ -91,8 +105,11 @@ func NewShortcutManager(conn *x.Conn, keySymbols *keysyms.KeySymbols, eventCb Ke
 		keySymbols:      keySymbols,
 		recordEnable:    true,
 		keyKeystrokeMap: make(map[Key]*Keystroke),
+		keySequenceMap:  make(map[Key][]*Keystroke),
 		layoutChanged:   make(chan struct{}),
 		pinyinEnabled:   isZH(),
+
+		inactiveShortcuts: make(map[string]bool),
 	}
 
 	ss.xRecordEventHandler = NewXRecordEventHandler(keySymbols)
@@ -104,11 +121,11 @@ func NewShortcutManager(conn *x.Conn, keySymbols *keysyms.KeySymbols, eventCb Ke
 			if isGrabbed {
 				return
 			}
-			ss.emitKeyEvent(0, Key{Code: Keycode(code)})
+			ss.emitKeyEvent(0, Key{Code: Keycode(code)}, 0)
 
 		case keysyms.ModMaskNumLock:
 			// num_lock
-			ss.emitKeyEvent(0, Key{Code: Keycode(code)})
+			ss.emitKeyEvent(0, Key{Code: Keycode(code)}, 0)
 
 		case keysyms.ModMaskControl | keysyms.ModMaskShift:
 			// ctrl-shift
@@ -328,12 +345,16 @@ func (sm *ShortcutManager) grabKeystroke(shortcut Shortcut, ks *Keystroke, dummy
 		return
 	}
 	//logger.Debugf("grabKeystroke shortcut: %s, ks: %s, key: %s, dummy: %v", shortcut.GetId(), ks, key, dummy)
+	if ks.IsSequence() {
+		sm.grabKeySequence(shortcut, ks, keyList, dummy)
+		return
+	}
 
 	var conflictCount int
 	var idx = -1
 	for i, key := range keyList {
 		sm.keyKeystrokeMapMu.Lock()
-		conflictKeystroke, ok := sm.keyKeystrokeMap[key]
+		conflictKeystroke, ok := sm.findConflictByKey(ks, key)
 		sm.keyKeystrokeMapMu.Unlock()
 
 		if ok {
@@ -384,6 +405,10 @@ func (sm *ShortcutManager) ungrabKeystroke(ks *Keystroke, dummy bool) {
 	if len(keyList) == 0 {
 		return
 	}
+	if ks.IsSequence() {
+		sm.ungrabKeySequence(ks, keyList, dummy)
+		return
+	}
 
 	sm.keyKeystrokeMapMu.Lock()
 	defer sm.keyKeystrokeMapMu.Unlock()
@@ -397,8 +422,9 @@ func (sm *ShortcutManager) ungrabKeystroke(ks *Keystroke, dummy bool) {
 
 func (sm *ShortcutManager) grabShortcut(shortcut Shortcut) {
 	//logger.Debug("grabShortcut shortcut id:", shortcut.GetId())
+	sm.updateScopeInactive(shortcut)
 	for _, ks := range shortcut.GetKeystrokes() {
-		dummy := dummyGrab(shortcut, ks)
+		dummy := sm.isDummyGrab(shortcut, ks)
 		sm.grabKeystroke(shortcut, ks, dummy)
 		ks.Shortcut = shortcut
 	}
@@ -407,7 +433,7 @@ func (sm *ShortcutManager) grabShortcut(shortcut Shortcut) {
 func (sm *ShortcutManager) ungrabShortcut(shortcut Shortcut) {
 
 	for _, ks := range shortcut.GetKeystrokes() {
-		dummy := dummyGrab(shortcut, ks)
+		dummy := sm.isDummyGrab(shortcut, ks)
 		sm.ungrabKeystroke(ks, dummy)
 		ks.Shortcut = nil
 	}
@@ -435,7 +461,7 @@ func (sm *ShortcutManager) AddShortcutKeystroke(shortcut Shortcut, ks *Keystroke
 		logger.Debug("shortcut.Keystrokes append", ks.DebugString())
 
 		// grab keystroke
-		dummy := dummyGrab(shortcut, ks)
+		dummy := sm.isDummyGrab(shortcut, ks)
 		sm.grabKeystroke(shortcut, ks, dummy)
 	}
 	ks.Shortcut = shortcut
@@ -455,7 +481,7 @@ func (sm *ShortcutManager) DeleteShortcutKeystroke(shortcut Shortcut, ks *Keystr
 	logger.Debugf("shortcut.Keystrokes  %v -> %v", oldVal, newVal)
 
 	// ungrab keystroke
-	dummy := dummyGrab(shortcut, ks)
+	dummy := sm.isDummyGrab(shortcut, ks)
 	sm.ungrabKeystroke(ks, dummy)
 	ks.Shortcut = nil
 }
@@ -476,7 +502,13 @@ func (sm *ShortcutManager) UngrabAll() {
 	sm.keyKeystrokeMapMu.Lock()
 	// ungrab all grabed keys
 	for key, keystroke := range sm.keyKeystrokeMap {
-		dummy := dummyGrab(keystroke.Shortcut, keystroke)
+		dummy := sm.isDummyGrab(keystroke.Shortcut, keystroke)
+		if !dummy {
+			key.Ungrab(sm.conn)
+		}
+	}
+	for key, sequences := range sm.keySequenceMap {
+		dummy := sm.isDummyGrab(sequences[0].Shortcut, sequences[0])
 		if !dummy {
 			key.Ungrab(sm.conn)
 		}
@@ -484,6 +516,7 @@ func (sm *ShortcutManager) UngrabAll() {
 	// new map
 	count := len(sm.keyKeystrokeMap)
 	sm.keyKeystrokeMap = make(map[Key]*Keystroke, count)
+	sm.keySequenceMap = make(map[Key][]*Keystroke, len(sm.keySequenceMap))
 	sm.keyKeystrokeMapMu.Unlock()
 }
 
@@ -560,7 +593,10 @@ func (sm *ShortcutManager) handleKeyEvent(pressed bool, detail x.Keycode, state
 
 	if pressed {
 		// key press
-		sm.emitKeyEvent(Modifiers(state), key)
+		if sm.handleKeySequenceKey(key) {
+			return
+		}
+		sm.emitKeyEvent(Modifiers(state), key, 0)
 	}
 }
 
@@ -571,16 +607,36 @@ func (sm *ShortcutManager) emitFakeKeyEvent(action *Action) {
 	sm.callEventCallback(keyEvent)
 }
 
-func (sm *ShortcutManager) emitKeyEvent(mods Modifiers, key Key) {
+// emitKeyEvent 触发按键 key 对应的快捷键，depth 见 KeyEvent.Depth
+func (sm *ShortcutManager) emitKeyEvent(mods Modifiers, key Key, depth int) {
 	sm.keyKeystrokeMapMu.Lock()
 	keystroke, ok := sm.keyKeystrokeMap[key]
+	var sequences []*Keystroke
+	for _, seq := range sm.keySequenceMap[key] {
+		if !sm.isScopeInactive(seq.Shortcut) {
+			sequences = append(sequences, seq)
+		}
+	}
 	sm.keyKeystrokeMapMu.Unlock()
+	if !ok && len(sequences) > 0 {
+		if depth > 0 {
+			logger.Debug("can not replay the first keystroke of key sequences")
+			return
+		}
+		sm.startKeySequence(sequences)
+		return
+	}
+	if ok && sm.isScopeInactive(keystroke.Shortcut) {
+		logger.Debug("keystroke is not active in current window")
+		return
+	}
 	if ok {
 		logger.Debugf("emitKeyEvent keystroke: %#v", keystroke)
 		keyEvent := &KeyEvent{
 			Mods:     mods,
 			Code:     key.Code,
 			Shortcut: keystroke.Shortcut,
+			Depth:    depth,
 		}
 
 		sm.callEventCallback(keyEvent)
@@ -660,8 +716,14 @@ func (sm *ShortcutManager) handleXRecordButtonEvent(pressed bool) {
 func (sm *ShortcutManager) EventLoop() {
 	eventChan := make(chan x.GenericEvent, 500)
 	sm.conn.AddEventChan(eventChan)
+	sm.listenActiveWindow()
 	for ev := range eventChan {
 		switch ev.GetEventCode() {
+		case x.PropertyNotifyEventCode:
+			event, _ := x.NewPropertyNotifyEvent(ev)
+			if event.Atom == sm.atomNetActiveWindow {
+				sm.handleActiveWindowChanged()
+			}
 		case x.KeyPressEventCode:
 			event, _ := x.NewKeyPressEvent(ev)
 			logger.Debug(event)
@@ -725,6 +787,10 @@ func (sm *ShortcutManager) Delete(shortcut Shortcut) {
 	sm.idShortcutMapMu.Unlock()
 
 	sm.ungrabShortcut(shortcut)
+
+	sm.scopeMu.Lock()
+	delete(sm.inactiveShortcuts, uid)
+	sm.scopeMu.Unlock()
 }
 
 func (sm *ShortcutManager) GetByIdType(id string, type0 int32) Shortcut {
@@ -746,6 +812,7 @@ func (sm *ShortcutManager) GetByUid(uid string) Shortcut {
 
 // ret0: Conflicting keystroke
 // ret1: error
+// 按键序列和以它的第一个组合键为前缀的快捷键冲突
 func (sm *ShortcutManager) FindConflictingKeystroke(ks *Keystroke) (*Keystroke, error) {
 	keyList, err := ks.ToKeyList(sm.keySymbols)
 	if err != nil {
@@ -763,7 +830,7 @@ func (sm *ShortcutManager) FindConflictingKeystroke(ks *Keystroke) (*Keystroke,
 	var count = 0
 	var ks1 *Keystroke
 	for _, key := range keyList {
-		tmp, ok := sm.keyKeystrokeMap[key]
+		tmp, ok := sm.findConflictByKey(ks, key)
 		if !ok {
 			continue
 		}
@@ -777,6 +844,44 @@ func (sm *ShortcutManager) FindConflictingKeystroke(ks *Keystroke) (*Keystroke,
 	return nil, nil
 }
 
+// FindConflictingKeystrokes 和 FindConflictingKeystroke 相同，但是返回所有冲突的组合键，
+// 一个组合键可能和多个以它开始的按键序列冲突。
+func (sm *ShortcutManager) FindConflictingKeystrokes(ks *Keystroke) ([]*Keystroke, error) {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	var result []*Keystroke
+	for _, key := range keyList {
+		var conflicts []*Keystroke
+		if conflictKeystroke, ok := sm.keyKeystrokeMap[key]; ok {
+			conflicts = append(conflicts, conflictKeystroke)
+		}
+		for _, seq := range sm.keySequenceMap[key] {
+			if !ks.IsSequence() || isPrefixConflict(ks, seq) {
+				conflicts = append(conflicts, seq)
+			}
+		}
+		if len(conflicts) == 0 {
+			// 和 FindConflictingKeystroke 一样，所有的按键都冲突时才算冲突
+			return nil, nil
+		}
+	loop:
+		for _, conflictKeystroke := range conflicts {
+			for _, ks0 := range result {
+				if ks0 == conflictKeystroke {
+					continue loop
+				}
+			}
+			result = append(result, conflictKeystroke)
+		}
+	}
+	return result, nil
+}
+
 func (sm *ShortcutManager) AddSystem(gsettings *gio.Settings) {
 	logger.Debug("AddSystem")
 	idNameMap := getSystemIdNameMap()
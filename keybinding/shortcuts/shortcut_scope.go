@@ -0,0 +1,251 @@
+package shortcuts
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/util/wm/ewmh"
+	"github.com/linuxdeepin/go-x11-client/util/wm/icccm"
+	"pkg.deepin.io/lib/procfs"
+)
+
+// 快捷键可以只在部分应用的窗口是活动窗口时生效，不生效时不抓取按键，按键会传给应用。
+
+// ShortcutScope 是快捷键生效的应用，Include 不为空时只在其中的应用中生效，在 Exclude 中的应用中不生效。
+// 应用可以是窗口 WM_CLASS 的 instance 或 class，或者 desktop id，不区分大小写。
+type ShortcutScope struct {
+	Include []string `json:",omitempty"`
+	Exclude []string `json:",omitempty"`
+}
+
+// WindowContext 是活动窗口所属应用的信息
+type WindowContext struct {
+	WMInstance string
+	WMClass    string
+	DesktopId  string
+}
+
+type scopedShortcut interface {
+	GetScope() *ShortcutScope
+}
+
+func (ctx *WindowContext) matchApp(app string) bool {
+	app = strings.TrimSuffix(app, ".desktop")
+	for _, value := range []string{ctx.WMInstance, ctx.WMClass, ctx.DesktopId} {
+		if value != "" && strings.EqualFold(value, app) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctx *WindowContext) matchAny(apps []string) bool {
+	for _, app := range apps {
+		if ctx.matchApp(app) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 判断快捷键在 ctx 是活动窗口时是否生效，ctx 为 nil 表示没有活动窗口。
+func (s *ShortcutScope) Match(ctx *WindowContext) bool {
+	if s == nil {
+		return true
+	}
+	if ctx == nil {
+		ctx = &WindowContext{}
+	}
+	if len(s.Include) > 0 && !ctx.matchAny(s.Include) {
+		return false
+	}
+	return !ctx.matchAny(s.Exclude)
+}
+
+func cleanApps(apps []string) []string {
+	var result []string
+	for _, app := range apps {
+		app = strings.TrimSpace(app)
+		if app != "" {
+			result = append(result, app)
+		}
+	}
+	return result
+}
+
+// NewShortcutScope 返回 nil 表示在所有应用中生效
+func NewShortcutScope(include, exclude []string) *ShortcutScope {
+	s := &ShortcutScope{
+		Include: cleanApps(include),
+		Exclude: cleanApps(exclude),
+	}
+	if len(s.Include) == 0 && len(s.Exclude) == 0 {
+		return nil
+	}
+	return s
+}
+
+// ParseShortcutScope 解析 JSON 格式的 ShortcutScope，空字符串表示在所有应用中生效。
+func ParseShortcutScope(str string) (*ShortcutScope, error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+	var s ShortcutScope
+	err := json.Unmarshal([]byte(str), &s)
+	if err != nil {
+		return nil, err
+	}
+	return NewShortcutScope(s.Include, s.Exclude), nil
+}
+
+func getWindowContext(conn *x.Conn, win x.Window) *WindowContext {
+	ctx := &WindowContext{}
+	wmClass, err := icccm.GetWMClass(conn, win).Reply(conn)
+	if err == nil {
+		ctx.WMInstance = wmClass.Instance
+		ctx.WMClass = wmClass.Class
+	}
+
+	pid, err := ewmh.GetWMPid(conn, win).Reply(conn)
+	if err == nil && pid != 0 {
+		environ, err := procfs.Process(pid).Environ()
+		if err == nil {
+			desktopFile := environ.Get("GIO_LAUNCHED_DESKTOP_FILE")
+			if desktopFile != "" {
+				ctx.DesktopId = strings.TrimSuffix(filepath.Base(desktopFile), ".desktop")
+			}
+		}
+	}
+	return ctx
+}
+
+func (sm *ShortcutManager) getActiveWindowContext() *WindowContext {
+	win, err := ewmh.GetActiveWindow(sm.conn).Reply(sm.conn)
+	if err != nil || win == 0 {
+		return nil
+	}
+	return getWindowContext(sm.conn, win)
+}
+
+func (sm *ShortcutManager) listenActiveWindow() {
+	rootWin := sm.conn.GetDefaultScreen().Root
+	err := x.ChangeWindowAttributesChecked(sm.conn, rootWin, x.CWEventMask,
+		[]uint32{x.EventMaskPropertyChange}).Check(sm.conn)
+	if err != nil {
+		logger.Warning(err)
+	}
+	sm.atomNetActiveWindow, err = sm.conn.GetAtom("_NET_ACTIVE_WINDOW")
+	if err != nil {
+		logger.Warning(err)
+	}
+	sm.handleActiveWindowChanged()
+}
+
+func (sm *ShortcutManager) isScopeInactive(shortcut Shortcut) bool {
+	if shortcut == nil {
+		return false
+	}
+	sm.scopeMu.Lock()
+	inactive := sm.inactiveShortcuts[shortcut.GetUid()]
+	sm.scopeMu.Unlock()
+	return inactive
+}
+
+// isDummyGrab 判断是否只记录 ks 而不抓取按键，不生效的快捷键也不抓取。
+func (sm *ShortcutManager) isDummyGrab(shortcut Shortcut, ks *Keystroke) bool {
+	return dummyGrab(shortcut, ks) || sm.isScopeInactive(shortcut)
+}
+
+// updateScopeInactive 根据活动窗口更新快捷键是否生效，返回是否有变化。
+func (sm *ShortcutManager) updateScopeInactive(shortcut Shortcut) bool {
+	s, ok := shortcut.(scopedShortcut)
+	uid := shortcut.GetUid()
+
+	sm.scopeMu.Lock()
+	defer sm.scopeMu.Unlock()
+	inactive := ok && !s.GetScope().Match(sm.activeWindow)
+	changed := sm.inactiveShortcuts[uid] != inactive
+	if inactive {
+		sm.inactiveShortcuts[uid] = true
+	} else {
+		delete(sm.inactiveShortcuts, uid)
+	}
+	return changed
+}
+
+// shouldGrabKey 判断按键 key 当前是否需要抓取，调用者需要持有 keyKeystrokeMapMu
+func (sm *ShortcutManager) shouldGrabKey(key Key) bool {
+	if ks, ok := sm.keyKeystrokeMap[key]; ok {
+		return ks.Shortcut != nil && !sm.isDummyGrab(ks.Shortcut, ks)
+	}
+	for _, seq := range sm.keySequenceMap[key] {
+		if seq.Shortcut != nil && !sm.isDummyGrab(seq.Shortcut, seq) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncKeyGrab 根据 shouldGrabKey 抓取或者取消抓取按键，调用者需要持有 keyKeystrokeMapMu
+func (sm *ShortcutManager) syncKeyGrab(key Key) {
+	if sm.shouldGrabKey(key) {
+		err := key.Grab(sm.conn)
+		if err != nil {
+			logger.Debug(err)
+		}
+	} else {
+		key.Ungrab(sm.conn)
+	}
+}
+
+// handleActiveWindowChanged 在活动窗口变化后抓取生效的快捷键，取消抓取不生效的快捷键。
+func (sm *ShortcutManager) handleActiveWindowChanged() {
+	ctx := sm.getActiveWindowContext()
+	sm.scopeMu.Lock()
+	sm.activeWindow = ctx
+	sm.scopeMu.Unlock()
+
+	var changes []Shortcut
+	sm.idShortcutMapMu.Lock()
+	for _, shortcut := range sm.idShortcutMap {
+		if _, ok := shortcut.(scopedShortcut); !ok {
+			continue
+		}
+		if sm.updateScopeInactive(shortcut) {
+			changes = append(changes, shortcut)
+		}
+	}
+	sm.idShortcutMapMu.Unlock()
+	if len(changes) == 0 {
+		return
+	}
+	logger.Debugf("active window changed %+v, scope changes: %v", ctx, changes)
+
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	for _, shortcut := range changes {
+		for _, ks := range shortcut.GetKeystrokes() {
+			if dummyGrab(shortcut, ks) {
+				continue
+			}
+			keyList, err := ks.ToKeyList(sm.keySymbols)
+			if err != nil {
+				logger.Debug(err)
+				continue
+			}
+			for _, key := range keyList {
+				sm.syncKeyGrab(key)
+			}
+		}
+	}
+}
+
+// ModifyShortcutScope 修改快捷键生效的应用
+func (sm *ShortcutManager) ModifyShortcutScope(shortcut *CustomShortcut, scope *ShortcutScope) {
+	logger.Debug("ShortcutManager.ModifyShortcutScope", shortcut, scope)
+	sm.ungrabShortcut(shortcut)
+	shortcut.SetScope(scope)
+	sm.grabShortcut(shortcut)
+}
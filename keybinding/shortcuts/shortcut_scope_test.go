@@ -0,0 +1,53 @@
+package shortcuts
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestShortcutScope(t *testing.T) {
+	Convey("ShortcutScope.Match", t, func(c C) {
+		terminal := &WindowContext{
+			WMInstance: "deepin-terminal",
+			WMClass:    "Deepin-terminal",
+			DesktopId:  "deepin-terminal",
+		}
+		chrome := &WindowContext{
+			WMInstance: "google-chrome",
+			WMClass:    "Google-chrome",
+			DesktopId:  "google-chrome",
+		}
+
+		var scope *ShortcutScope
+		c.So(scope.Match(terminal), ShouldBeTrue)
+		c.So(scope.Match(nil), ShouldBeTrue)
+
+		scope = NewShortcutScope([]string{"DEEPIN-TERMINAL"}, nil)
+		c.So(scope.Match(terminal), ShouldBeTrue)
+		c.So(scope.Match(chrome), ShouldBeFalse)
+		c.So(scope.Match(nil), ShouldBeFalse)
+
+		scope = NewShortcutScope(nil, []string{"google-chrome.desktop"})
+		c.So(scope.Match(terminal), ShouldBeTrue)
+		c.So(scope.Match(chrome), ShouldBeFalse)
+		c.So(scope.Match(nil), ShouldBeTrue)
+	})
+
+	Convey("ParseShortcutScope", t, func(c C) {
+		scope, err := ParseShortcutScope("")
+		c.So(err, ShouldBeNil)
+		c.So(scope, ShouldBeNil)
+
+		scope, err = ParseShortcutScope(`{"Include": [" code ", ""]}`)
+		c.So(err, ShouldBeNil)
+		c.So(scope, ShouldResemble, &ShortcutScope{Include: []string{"code"}})
+
+		scope, err = ParseShortcutScope(`{"Include": [], "Exclude": [""]}`)
+		c.So(err, ShouldBeNil)
+		c.So(scope, ShouldBeNil)
+
+		_, err = ParseShortcutScope(`{"Include": "code"}`)
+		c.So(err, ShouldNotBeNil)
+	})
+}
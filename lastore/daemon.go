@@ -24,6 +24,7 @@ type Daemon struct {
 func newDaemon() *Daemon {
 	daemon := new(Daemon)
 	daemon.ModuleBase = loader.NewModuleBase("lastore", daemon, logger)
+	daemon.SetStartConcurrently(true)
 	return daemon
 }
 
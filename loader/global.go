@@ -71,6 +71,11 @@ func EnableModules(enablingModules []string, disableModules []string, flag Enabl
 	return getLoader().EnableModules(enablingModules, disableModules, flag)
 }
 
+// GetStartupProfile 返回启动过的模块的启动信息，按启动耗时从大到小排序。
+func GetStartupProfile() []*ModuleStartInfo {
+	return getLoader().GetStartupProfile()
+}
+
 func ToggleLogDebug(enabled bool) {
 	var priority log.Priority = log.LevelInfo
 	if enabled {
@@ -20,6 +20,7 @@
 package loader
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -76,6 +77,12 @@ type Loader struct {
 	log     *log.Logger
 	lock    sync.Mutex
 	service *dbusutil.Service
+	// 运行时启动或停止模块失败的错误
+	moduleErrors map[string]string
+
+	profileMu      sync.Mutex
+	startupProfile []*ModuleStartInfo
+	manager        *Manager
 }
 
 func (l *Loader) SetLogLevel(pri log.Priority) {
@@ -142,22 +149,240 @@ func (l *Loader) EnableModules(enablingModules []string, disableModules []string
 		return &EnableError{Code: ErrorCircleDependencies}
 	}
 
+	var names []string
 	for _, name := range enablingModules {
-		node := nodes.Get(name)
-		if node == nil {
+		if nodes.Get(name) == nil || isStrInList(name, names) {
 			continue
 		}
+		names = append(names, name)
+	}
+
+	infos := l.startModules(names)
+	for _, info := range infos {
+		if info.Error != "" {
+			l.setModuleError(info.Name, errors.New(info.Error))
+		}
+	}
+	l.addStartupProfile(infos)
+
+	return nil
+}
+
+// startModules 启动 names 中的模块，每个模块在 names 中它依赖的模块都启动后才启动，依赖启动失败的模块不会启动。
+// 每个模块在自己的 goroutine 中等待依赖，没有依赖关系的分支互不等待。
+// 模块的 X、GLib 和 gsettings 代码需要和 glib 主循环在同一个线程中执行，所以只有 CanStartConcurrently
+// 的模块在 goroutine 中启动，其他模块的 Enable 交给调用者所在的线程（启动时为主线程）依次执行。
+// 返回的结果和 names 的顺序一致。
+func (l *Loader) startModules(names []string) []*ModuleStartInfo {
+	done := make(map[string]chan struct{}, len(names))
+	infos := make([]*ModuleStartInfo, len(names))
+	infoMap := make(map[string]*ModuleStartInfo, len(names))
+	for i, name := range names {
+		done[name] = make(chan struct{})
+		infos[i] = &ModuleStartInfo{Name: name}
+		infoMap[name] = infos[i]
+	}
+
+	// 需要在调用者的线程中执行的启动函数
+	callerCh := make(chan func())
+	var wg sync.WaitGroup
+	wg.Add(len(names))
+	for _, info := range infos {
+		module := l.modules.Get(info.Name)
+		go func(module Module, info *ModuleStartInfo) {
+			defer wg.Done()
+			defer close(done[info.Name])
+
+			for _, dependency := range module.GetDependencies() {
+				ch, ok := done[dependency]
+				if !ok {
+					continue
+				}
+				<-ch
+				if infoMap[dependency].Error != "" {
+					info.Error = fmt.Sprintf("dependency %s failed", dependency)
+					l.log.Warningf("skip module %s: %s", info.Name, info.Error)
+					return
+				}
+			}
+
+			if module.CanStartConcurrently() {
+				l.enableModule(module, info)
+				return
+			}
+			finished := make(chan struct{})
+			callerCh <- func() {
+				l.enableModule(module, info)
+				close(finished)
+			}
+			<-finished
+		}(module, info)
+	}
+
+	allDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allDone)
+	}()
+	for {
+		select {
+		case fn := <-callerCh:
+			fn()
+		case <-allDone:
+			return infos
+		}
+	}
+}
+
+// enableModule 启动模块并把耗时和错误记录到 info 中
+func (l *Loader) enableModule(module Module, info *ModuleStartInfo) {
+	l.log.Info("enable module", info.Name)
+	info.StartTime = time.Now()
+	err := module.Enable(true)
+	info.Duration = time.Since(info.StartTime)
+	if err != nil {
+		info.Error = err.Error()
+		l.log.Warningf("enable module %s failed: %s, cost %s", info.Name, err, info.Duration)
+		return
+	}
+	l.log.Info("enable module", info.Name, "done, cost", info.Duration)
+}
+
+// StartModule 启动模块 name，没有启动的依赖会先被启动，返回启动了的模块。
+func (l *Loader) StartModule(name string) ([]string, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.startModule(name)
+}
+
+func (l *Loader) startModule(name string) ([]string, error) {
+	builder := NewDAGBuilder(l, []string{name}, nil, EnableFlagNone)
+	dag, err := builder.Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, ok := dag.TopologicalDag()
+	if !ok {
+		return nil, &EnableError{Code: ErrorCircleDependencies}
+	}
+
+	var started []string
+	for _, node := range nodes {
 		module := l.modules.Get(node.ID)
-		l.log.Info("enable module", node.ID)
-		startTime := time.Now()
+		if module.IsEnable() {
+			continue
+		}
+		l.log.Info("start module", node.ID)
 		err := module.Enable(true)
-		endTime := time.Now()
-		duration := endTime.Sub(startTime)
+		l.setModuleError(node.ID, err)
 		if err != nil {
-			l.log.Fatalf("enable module %s failed: %s, cost %s", node.ID, err, duration)
+			return started, &EnableError{ModuleName: node.ID, Code: ErrorInternalError, detail: err.Error()}
 		}
-		l.log.Info("enable module", node.ID, "done, cost", duration)
+		started = append(started, node.ID)
 	}
+	return started, nil
+}
 
+// StopModule 停止模块 name，依赖它的已启动的模块会先被停止，返回停止了的模块。
+func (l *Loader) StopModule(name string) ([]string, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.stopModule(name)
+}
+
+func (l *Loader) stopModule(name string) ([]string, error) {
+	if l.modules.Get(name) == nil {
+		return nil, &EnableError{ModuleName: name, Code: ErrorMissingModule}
+	}
+
+	var stopped []string
+	for _, moduleName := range l.getEnabledDependents(name) {
+		module := l.modules.Get(moduleName)
+		l.log.Info("stop module", moduleName)
+		err := module.Enable(false)
+		l.setModuleError(moduleName, err)
+		if err != nil {
+			return stopped, fmt.Errorf("failed to stop module %s: %v", moduleName, err)
+		}
+		stopped = append(stopped, moduleName)
+	}
+	return stopped, nil
+}
+
+// RestartModule 停止模块 name 和依赖它的模块，再把它们重新启动。
+func (l *Loader) RestartModule(name string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	stopped, err := l.stopModule(name)
+	if err != nil {
+		return err
+	}
+	if len(stopped) == 0 {
+		// 模块本来就没有启动
+		stopped = []string{name}
+	}
+
+	// stopped 中依赖者在前，反过来启动
+	for i := len(stopped) - 1; i >= 0; i-- {
+		_, err = l.startModule(stopped[i])
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// getEnabledDependents 返回 name 和直接或间接依赖它的模块中已启动的，依赖者排在被依赖者前面。
+func (l *Loader) getEnabledDependents(name string) []string {
+	var result []string
+	visited := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, module := range l.modules {
+			if module.IsEnable() && isStrInList(name, module.GetDependencies()) {
+				visit(module.Name())
+			}
+		}
+		if l.modules.Get(name).IsEnable() {
+			result = append(result, name)
+		}
+	}
+	visit(name)
+	return result
+}
+
+func (l *Loader) getDependents(name string) []string {
+	var result []string
+	for _, module := range l.modules {
+		if isStrInList(name, module.GetDependencies()) {
+			result = append(result, module.Name())
+		}
+	}
+	return result
+}
+
+func (l *Loader) setModuleError(name string, err error) {
+	if err == nil {
+		delete(l.moduleErrors, name)
+		return
+	}
+	if l.moduleErrors == nil {
+		l.moduleErrors = make(map[string]string)
+	}
+	l.moduleErrors[name] = err.Error()
+}
+
+func isStrInList(item string, list []string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
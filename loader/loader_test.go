@@ -0,0 +1,197 @@
+package loader
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"pkg.deepin.io/lib/log"
+)
+
+type testModule struct {
+	*ModuleBase
+	deps     []string
+	startErr error
+	// 记录启动和停止的顺序
+	record *[]string
+	mu     *sync.Mutex
+	// 执行 Start 的线程
+	startTid int
+}
+
+func (m *testModule) GetDependencies() []string {
+	return m.deps
+}
+
+func (m *testModule) Start() error {
+	if m.startErr != nil {
+		return m.startErr
+	}
+	m.startTid = syscall.Gettid()
+	m.mu.Lock()
+	*m.record = append(*m.record, "+"+m.Name())
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *testModule) Stop() error {
+	m.mu.Lock()
+	*m.record = append(*m.record, "-"+m.Name())
+	m.mu.Unlock()
+	return nil
+}
+
+func newTestLoader(record *[]string) *Loader {
+	l := &Loader{
+		log: log.NewLogger("loader-test"),
+	}
+	var mu sync.Mutex
+	add := func(name string, deps ...string) {
+		m := &testModule{deps: deps, record: record, mu: &mu}
+		m.ModuleBase = NewModuleBase(name, m, l.log)
+		l.AddModule(m)
+	}
+	// a <- b <- c, a <- d
+	add("a")
+	add("b", "a")
+	add("c", "b")
+	add("d", "a")
+	add("e")
+	return l
+}
+
+func TestLoaderStartStopModule(t *testing.T) {
+	var record []string
+	l := newTestLoader(&record)
+
+	started, err := l.StartModule("c")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, started)
+	assert.Equal(t, []string{"+a", "+b", "+c"}, record)
+
+	started, err = l.StartModule("d")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d"}, started)
+
+	record = nil
+	stopped, err := l.StopModule("b")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "b"}, stopped)
+	assert.True(t, l.GetModule("a").IsEnable())
+	assert.True(t, l.GetModule("d").IsEnable())
+
+	stopped, err = l.StopModule("e")
+	assert.Nil(t, err)
+	assert.Empty(t, stopped)
+
+	_, err = l.StartModule("x")
+	assert.NotNil(t, err)
+	_, err = l.StopModule("x")
+	assert.NotNil(t, err)
+}
+
+func TestLoaderRestartModule(t *testing.T) {
+	var record []string
+	l := newTestLoader(&record)
+
+	_, err := l.StartModule("c")
+	assert.Nil(t, err)
+	_, err = l.StartModule("d")
+	assert.Nil(t, err)
+
+	record = nil
+	err = l.RestartModule("a")
+	assert.Nil(t, err)
+	assert.Equal(t, "-a", record[3])
+	assert.Equal(t, "+a", record[4])
+	assert.Len(t, record, 8)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		assert.True(t, l.GetModule(name).IsEnable())
+	}
+	assert.False(t, l.GetModule("e").IsEnable())
+
+	record = nil
+	err = l.RestartModule("e")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"+e"}, record)
+}
+
+func TestLoaderGetDependents(t *testing.T) {
+	l := newTestLoader(new([]string))
+	assert.Equal(t, []string{"b", "d"}, l.getDependents("a"))
+	assert.Nil(t, l.getDependents("c"))
+}
+
+func indexOf(list []string, item string) int {
+	for i, v := range list {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestLoaderEnableModules(t *testing.T) {
+	var record []string
+	l := newTestLoader(&record)
+
+	err := l.EnableModules([]string{"e", "d", "c", "b", "a"}, nil, EnableFlagNone)
+	assert.Nil(t, err)
+	assert.Len(t, record, 5)
+	// 依赖总是先启动
+	assert.True(t, indexOf(record, "+a") < indexOf(record, "+b"))
+	assert.True(t, indexOf(record, "+b") < indexOf(record, "+c"))
+	assert.True(t, indexOf(record, "+a") < indexOf(record, "+d"))
+
+	profile := l.GetStartupProfile()
+	assert.Len(t, profile, 5)
+	for _, info := range profile {
+		assert.Empty(t, info.Error)
+		assert.False(t, info.StartTime.IsZero())
+	}
+}
+
+func TestLoaderEnableModulesFailed(t *testing.T) {
+	var record []string
+	l := newTestLoader(&record)
+	l.GetModule("b").(*testModule).startErr = errors.New("wedged")
+
+	err := l.EnableModules([]string{"a", "b", "c", "d", "e"}, nil, EnableFlagNone)
+	assert.Nil(t, err)
+	assert.False(t, l.GetModule("b").IsEnable())
+	assert.False(t, l.GetModule("c").IsEnable())
+	assert.True(t, l.GetModule("d").IsEnable())
+	assert.Equal(t, "wedged", l.moduleErrors["b"])
+	assert.Equal(t, "dependency b failed", l.moduleErrors["c"])
+
+	var failed []string
+	for _, info := range l.GetStartupProfile() {
+		if info.Error != "" {
+			failed = append(failed, info.Name)
+		}
+	}
+	assert.ElementsMatch(t, []string{"b", "c"}, failed)
+}
+
+func TestLoaderEnableModulesThread(t *testing.T) {
+	var record []string
+	l := newTestLoader(&record)
+	l.GetModule("d").(*testModule).SetStartConcurrently(true)
+	l.GetModule("e").(*testModule).SetStartConcurrently(true)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	tid := syscall.Gettid()
+
+	err := l.EnableModules([]string{"a", "b", "c", "d", "e"}, nil, EnableFlagNone)
+	assert.Nil(t, err)
+	assert.Len(t, record, 5)
+	assert.True(t, indexOf(record, "+a") < indexOf(record, "+d"))
+	// 不能并发启动的模块都在调用者的线程中启动
+	for _, name := range []string{"a", "b", "c"} {
+		assert.Equal(t, tid, l.GetModule(name).(*testModule).startTid, name)
+	}
+}
@@ -0,0 +1,146 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+const (
+	managerDBusPath      = "/com/deepin/daemon/Modules"
+	managerDBusInterface = "com.deepin.daemon.Modules"
+)
+
+// ModuleStatus 是 Status 方法返回的模块状态
+type ModuleStatus struct {
+	Name         string
+	Enabled      bool
+	Dependencies []string
+	// 依赖此模块的模块
+	Dependents []string
+	// 最近一次在运行时启动或停止失败的错误
+	Error string
+}
+
+//go:generate dbusutil-gen -type Manager manager.go
+
+// Manager 在 D-Bus 上提供运行时查看、启动和停止模块的接口
+type Manager struct {
+	loader  *Loader
+	service *dbusutil.Service
+
+	PropsMu sync.RWMutex
+	// 模块启动信息的 JSON，见 ModuleStartInfo
+	StartupProfile string
+
+	methods *struct {
+		List    func() `out:"names"`
+		Status  func() `in:"name" out:"status"`
+		Start   func() `in:"name"`
+		Stop    func() `in:"name"`
+		Restart func() `in:"name"`
+	}
+}
+
+// ExportManager 把 Manager 导出到 service 上
+func ExportManager(service *dbusutil.Service) error {
+	l := getLoader()
+	m := &Manager{
+		loader:  l,
+		service: service,
+	}
+	m.StartupProfile = l.getStartupProfileJSON()
+	err := service.Export(managerDBusPath, m)
+	if err != nil {
+		return err
+	}
+
+	l.profileMu.Lock()
+	l.manager = m
+	l.profileMu.Unlock()
+	// 导出前可能已经有模块启动了
+	m.updateStartupProfile()
+	return nil
+}
+
+func (m *Manager) updateStartupProfile() {
+	profile := m.loader.getStartupProfileJSON()
+	m.PropsMu.Lock()
+	m.setPropStartupProfile(profile)
+	m.PropsMu.Unlock()
+}
+
+func (*Manager) GetInterfaceName() string {
+	return managerDBusInterface
+}
+
+// checkCaller 只允许和 daemon 相同的用户控制模块
+func (m *Manager) checkCaller(sender dbus.Sender) error {
+	uid, err := m.service.GetConnUID(string(sender))
+	if err != nil {
+		return err
+	}
+	if int(uid) != os.Getuid() {
+		return fmt.Errorf("uid %d is not allowed to control modules", uid)
+	}
+	return nil
+}
+
+func (m *Manager) List() ([]string, *dbus.Error) {
+	return Modules(m.loader.List()).List(), nil
+}
+
+func (m *Manager) Status(name string) (string, *dbus.Error) {
+	l := m.loader
+	l.lock.Lock()
+	module := l.modules.Get(name)
+	if module == nil {
+		l.lock.Unlock()
+		return "", dbusutil.ToError(&EnableError{ModuleName: name, Code: ErrorMissingModule})
+	}
+	status := &ModuleStatus{
+		Name:         name,
+		Enabled:      module.IsEnable(),
+		Dependencies: module.GetDependencies(),
+		Dependents:   l.getDependents(name),
+		Error:        l.moduleErrors[name],
+	}
+	l.lock.Unlock()
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) Start(sender dbus.Sender, name string) *dbus.Error {
+	err := m.checkCaller(sender)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	_, err = m.loader.StartModule(name)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) Stop(sender dbus.Sender, name string) *dbus.Error {
+	err := m.checkCaller(sender)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	_, err = m.loader.StopModule(name)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) Restart(sender dbus.Sender, name string) *dbus.Error {
+	err := m.checkCaller(sender)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = m.loader.RestartModule(name)
+	return dbusutil.ToError(err)
+}
@@ -31,6 +31,7 @@ type Module interface {
 	GetDependencies() []string
 	SetLogLevel(log.Priority)
 	LogLevel() log.Priority
+	CanStartConcurrently() bool
 	ModuleImpl
 }
 type Modules []Module
@@ -45,6 +46,8 @@ type ModuleBase struct {
 	enabled bool
 	name    string
 	log     *log.Logger
+	// 是否可以不在主线程中启动
+	concurrent bool
 }
 
 func NewModuleBase(name string, impl ModuleImpl, logger *log.Logger) *ModuleBase {
@@ -85,6 +88,16 @@ func (d *ModuleBase) Name() string {
 	return d.name
 }
 
+// SetStartConcurrently 设置模块是否可以在其他 goroutine 中启动，
+// 只有 Start 不调用 X、GLib 和 gsettings 代码的模块才能设置为 true
+func (d *ModuleBase) SetStartConcurrently(value bool) {
+	d.concurrent = value
+}
+
+func (d *ModuleBase) CanStartConcurrently() bool {
+	return d.concurrent
+}
+
 func (d *ModuleBase) SetLogLevel(pri log.Priority) {
 	d.log.SetLogLevel(pri)
 }
@@ -0,0 +1,49 @@
+package loader
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// ModuleStartInfo 记录启动时一个模块的启动耗时和错误
+type ModuleStartInfo struct {
+	Name      string
+	StartTime time.Time
+	// 启动耗时，单位纳秒
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+}
+
+func (l *Loader) addStartupProfile(infos []*ModuleStartInfo) {
+	l.profileMu.Lock()
+	l.startupProfile = append(l.startupProfile, infos...)
+	manager := l.manager
+	l.profileMu.Unlock()
+
+	if manager != nil {
+		manager.updateStartupProfile()
+	}
+}
+
+// GetStartupProfile 返回所有启动过的模块的启动信息，按启动耗时从大到小排序。
+func (l *Loader) GetStartupProfile() []*ModuleStartInfo {
+	l.profileMu.Lock()
+	result := make([]*ModuleStartInfo, len(l.startupProfile))
+	copy(result, l.startupProfile)
+	l.profileMu.Unlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Duration > result[j].Duration
+	})
+	return result
+}
+
+func (l *Loader) getStartupProfileJSON() string {
+	data, err := json.Marshal(l.GetStartupProfile())
+	if err != nil {
+		l.log.Warning(err)
+		return ""
+	}
+	return string(data)
+}
@@ -35,6 +35,7 @@ type Daemon struct {
 func NewDaemon(logger *log.Logger) *Daemon {
 	daemon := new(Daemon)
 	daemon.ModuleBase = loader.NewModuleBase("miracast", daemon, logger)
+	daemon.SetStartConcurrently(true)
 	return daemon
 }
 
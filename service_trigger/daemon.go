@@ -25,15 +25,32 @@ func NewDaemon() *Daemon {
 }
 
 func (d *Daemon) Start() error {
-	m := newManager()
+	service := loader.GetService()
+	m := newManager(service)
 	m.start()
 	d.manager = m
-	return nil
+
+	err := service.Export(dbusPath, m)
+	if err != nil {
+		return err
+	}
+
+	return service.RequestName(dbusServiceName)
 }
 
 func (d *Daemon) Stop() error {
 	if d.manager != nil {
-		err := d.manager.stop()
+		service := loader.GetService()
+		err := service.ReleaseName(dbusServiceName)
+		if err != nil {
+			logger.Warning(err)
+		}
+		err = service.StopExport(d.manager)
+		if err != nil {
+			logger.Warning(err)
+		}
+
+		err = d.manager.stop()
 		if err != nil {
 			return err
 		}
@@ -198,8 +198,8 @@ func (sigMonitor *DBusSignalMonitor) signalLoop(m *Manager) {
 
 		services := sigMonitor.findMatchedServices(signal)
 		for _, service := range services {
-			logger.Debug("exec service", service)
-			go m.execService(service, signal)
+			logger.Debug("trigger service", service)
+			m.triggerService(service, newReplacer(signal))
 		}
 	}
 	logger.Debug("signalLoop return", sigMonitor.Type)
@@ -0,0 +1,206 @@
+package service_trigger
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"pkg.deepin.io/lib/log"
+)
+
+const (
+	// 每个服务保留的执行记录数量
+	executionsMaxNum = 20
+	// 每次执行保留的输出的最大字节数
+	outputMaxSize = 4096
+)
+
+// Execution 是一次执行的记录
+type Execution struct {
+	Args      []string
+	StartTime time.Time
+	// 执行耗时，单位纳秒
+	Duration time.Duration
+	// 命令没有正常退出或者没能启动时为 -1
+	ExitCode int
+	Output   string
+	// 输出超过 outputMaxSize 被截断
+	OutputTruncated bool   `json:",omitempty"`
+	Error           string `json:",omitempty"`
+}
+
+type serviceRuntime struct {
+	mu       sync.Mutex
+	running  int
+	dropped  uint64
+	triggers []time.Time // RateLimit.Interval 内执行的时间
+
+	debounceTimer    *time.Timer
+	debounceGen      uint64
+	debounceReplacer *strings.Replacer
+
+	executions []*Execution // 新的在前
+}
+
+// triggerService 在服务被触发时调用，按照 Debounce，RateLimit 和 MaxConcurrent 决定是否执行。
+func (m *Manager) triggerService(service *Service, replacer *strings.Replacer) {
+	if service.debounce <= 0 {
+		m.runService(service, replacer)
+		return
+	}
+
+	rt := service.runtime
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.debounceReplacer = replacer
+	if rt.debounceTimer != nil && rt.debounceTimer.Stop() {
+		rt.debounceTimer.Reset(service.debounce)
+		return
+	}
+
+	// 已经触发的定时器的回调会因为 gen 不同而忽略
+	rt.debounceGen++
+	gen := rt.debounceGen
+	rt.debounceTimer = time.AfterFunc(service.debounce, func() {
+		rt.mu.Lock()
+		if gen != rt.debounceGen {
+			rt.mu.Unlock()
+			return
+		}
+		replacer := rt.debounceReplacer
+		rt.debounceTimer = nil
+		rt.debounceReplacer = nil
+		rt.mu.Unlock()
+		m.runService(service, replacer)
+	})
+}
+
+func (m *Manager) runService(service *Service, replacer *strings.Replacer) {
+	if !service.runtime.acquire(service, time.Now()) {
+		logger.Debug("drop trigger of service", service)
+		return
+	}
+
+	go func() {
+		execution := m.execService(service, replacer)
+		service.runtime.release(execution)
+	}()
+}
+
+// acquire 检查 RateLimit 和 MaxConcurrent，可以执行时返回 true。
+func (rt *serviceRuntime) acquire(service *Service, now time.Time) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if service.RateLimit != nil {
+		deadline := now.Add(-service.rateInterval)
+		idx := 0
+		for idx < len(rt.triggers) && !rt.triggers[idx].After(deadline) {
+			idx++
+		}
+		rt.triggers = rt.triggers[idx:]
+		if len(rt.triggers) >= service.RateLimit.Burst {
+			rt.dropped++
+			return false
+		}
+	}
+
+	if service.MaxConcurrent > 0 && rt.running >= service.MaxConcurrent {
+		rt.dropped++
+		return false
+	}
+
+	if service.RateLimit != nil {
+		rt.triggers = append(rt.triggers, now)
+	}
+	rt.running++
+	return true
+}
+
+func (rt *serviceRuntime) release(execution *Execution) {
+	rt.mu.Lock()
+	rt.running--
+	rt.executions = append([]*Execution{execution}, rt.executions...)
+	if len(rt.executions) > executionsMaxNum {
+		rt.executions = rt.executions[:executionsMaxNum]
+	}
+	rt.mu.Unlock()
+}
+
+func (rt *serviceRuntime) getExecutions() []*Execution {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	result := make([]*Execution, len(rt.executions))
+	copy(result, rt.executions)
+	return result
+}
+
+// limitedBuffer 只保存前 limit 字节
+type limitedBuffer struct {
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := b.limit - len(b.data)
+	if n > len(p) {
+		n = len(p)
+	}
+	if n < len(p) {
+		b.truncated = true
+	}
+	b.data = append(b.data, p[:n]...)
+	return len(p), nil
+}
+
+func getExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Exited() {
+			return status.ExitStatus()
+		}
+	}
+	return -1
+}
+
+func (m *Manager) execService(service *Service, replacer *strings.Replacer) *Execution {
+	var args []string
+	execArgs := service.Exec[1:]
+	if logger.GetLogLevel() == log.LevelDebug {
+		if service.Exec[0] == "sh" {
+			// add -x option for debug shell
+			execArgs = append([]string{"-x"}, execArgs...)
+		}
+	}
+
+	for _, arg := range execArgs {
+		args = append(args, replacer.Replace(arg))
+	}
+
+	logger.Debugf("run cmd %q %#v", service.Exec[0], args)
+	cmd := exec.Command(service.Exec[0], args...)
+	output := &limitedBuffer{limit: outputMaxSize}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	execution := &Execution{
+		Args:      append([]string{service.Exec[0]}, args...),
+		StartTime: time.Now(),
+	}
+	err := cmd.Run()
+	execution.Duration = time.Since(execution.StartTime)
+	execution.ExitCode = getExitCode(err)
+	execution.Output = string(output.data)
+	execution.OutputTruncated = output.truncated
+	logger.Debugf("cmd combined output: %s", output.data)
+	if err != nil {
+		execution.Error = err.Error()
+		logger.Warning(err)
+	}
+	return execution
+}
@@ -0,0 +1,95 @@
+package service_trigger
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceRuntimeAcquire(t *testing.T) {
+	service := newTestService(t, `{"Name":"a","Exec":["true"],"Monitor":{"Type":"Timer","Timer":{"Interval":"1h"}},
+"RateLimit":{"Burst":2,"Interval":"10s"},"MaxConcurrent":1}`)
+	assert.Nil(t, service.check())
+	rt := &serviceRuntime{}
+	now := time.Now()
+
+	assert.True(t, rt.acquire(service, now))
+	// MaxConcurrent
+	assert.False(t, rt.acquire(service, now))
+	rt.release(&Execution{})
+	assert.True(t, rt.acquire(service, now.Add(time.Second)))
+	rt.release(&Execution{})
+	// RateLimit
+	assert.False(t, rt.acquire(service, now.Add(2*time.Second)))
+	assert.True(t, rt.acquire(service, now.Add(11*time.Second)))
+	assert.Equal(t, uint64(2), rt.dropped)
+	assert.Len(t, rt.getExecutions(), 2)
+}
+
+func TestServiceRuntimeExecutionsLimit(t *testing.T) {
+	rt := &serviceRuntime{}
+	for i := 0; i < executionsMaxNum+5; i++ {
+		rt.running++
+		rt.release(&Execution{ExitCode: i})
+	}
+	executions := rt.getExecutions()
+	assert.Len(t, executions, executionsMaxNum)
+	assert.Equal(t, executionsMaxNum+4, executions[0].ExitCode)
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 5}
+	n, err := b.Write([]byte("abc"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.False(t, b.truncated)
+
+	n, err = b.Write([]byte("defg"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.True(t, b.truncated)
+	assert.Equal(t, "abcde", string(b.data))
+}
+
+func TestExecService(t *testing.T) {
+	service := newTestService(t, `{"Exec":["sh","-c","echo $0; exit 3","%{name}"]}`)
+	m := &Manager{}
+	execution := m.execService(service, newFileReplacer("/tmp/a.txt", "create"))
+	assert.Equal(t, 3, execution.ExitCode)
+	assert.Equal(t, "a.txt\n", execution.Output)
+	assert.Equal(t, []string{"sh", "-c", "echo $0; exit 3", "a.txt"}, execution.Args)
+	assert.NotEmpty(t, execution.Error)
+
+	service = newTestService(t, `{"Exec":["/nonexistent/cmd"]}`)
+	execution = m.execService(service, strings.NewReplacer())
+	assert.Equal(t, -1, execution.ExitCode)
+	assert.NotEmpty(t, execution.Error)
+}
+
+func TestTriggerServiceDebounce(t *testing.T) {
+	service := newTestService(t, `{"Name":"a","Exec":["echo","%{name}"],"Monitor":{"Type":"Timer","Timer":{"Interval":"1h"}},
+"Debounce":"50ms"}`)
+	assert.Nil(t, service.check())
+	service.runtime = &serviceRuntime{}
+	m := &Manager{}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			m.triggerService(service, newFileReplacer("/tmp/"+string(rune('a'+i)), "create"))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+
+	time.Sleep(300 * time.Millisecond)
+	executions := service.runtime.getExecutions()
+	if assert.Len(t, executions, 1) {
+		assert.Equal(t, "e\n", executions[0].Output)
+	}
+}
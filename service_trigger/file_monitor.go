@@ -0,0 +1,141 @@
+package service_trigger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pkg.deepin.io/lib/fsnotify"
+	"pkg.deepin.io/lib/strv"
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+type FileMonitor struct {
+	watcher  *fsnotify.Watcher
+	services []*Service
+}
+
+func newFileMonitor() *FileMonitor {
+	return &FileMonitor{}
+}
+
+func (fileMonitor *FileMonitor) appendService(service *Service) {
+	fileMonitor.services = append(fileMonitor.services, service)
+}
+
+// expandPath 展开路径开头的 ~ 和其中的环境变量
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = basedir.GetUserHomeDir() + path[1:]
+	}
+	return os.ExpandEnv(path)
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[\\")
+}
+
+func (fileMonitor *FileMonitor) init() error {
+	if len(fileMonitor.services) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	fileMonitor.watcher = watcher
+
+	watchedDirs := make(map[string]bool)
+	for _, service := range fileMonitor.services {
+		for _, pattern := range service.Monitor.File.Paths {
+			dir := filepath.Dir(expandPath(pattern))
+			if watchedDirs[dir] {
+				continue
+			}
+			watchedDirs[dir] = true
+
+			logger.Debug("watch dir", dir)
+			err = watcher.Watch(dir)
+			if err != nil {
+				logger.Warningf("failed to watch dir %q: %v", dir, err)
+			}
+		}
+	}
+	return nil
+}
+
+func getFileEventName(ev *fsnotify.FileEvent) string {
+	switch {
+	case ev.IsCreate():
+		return "create"
+	case ev.IsModify():
+		return "modify"
+	case ev.IsDelete():
+		return "delete"
+	case ev.IsRename():
+		return "rename"
+	case ev.IsAttrib():
+		return "attrib"
+	}
+	return ""
+}
+
+func (fileMonitor *FileMonitor) findMatchedServices(path, event string) []*Service {
+	var matched []*Service
+	for _, service := range fileMonitor.services {
+		fileField := service.Monitor.File
+		if len(fileField.Events) > 0 && !strv.Strv(fileField.Events).Contains(event) {
+			continue
+		}
+
+		for _, pattern := range fileField.Paths {
+			ok, _ := filepath.Match(expandPath(pattern), path)
+			if ok {
+				matched = append(matched, service)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func (fileMonitor *FileMonitor) eventLoop(m *Manager) {
+	watcher := fileMonitor.watcher
+	if watcher == nil {
+		return
+	}
+
+	for {
+		select {
+		case ev, ok := <-watcher.Event:
+			if !ok {
+				logger.Debug("file monitor eventLoop return")
+				return
+			}
+			event := getFileEventName(ev)
+			if event == "" {
+				continue
+			}
+
+			services := fileMonitor.findMatchedServices(ev.Name, event)
+			for _, service := range services {
+				logger.Debug("trigger service", service)
+				m.triggerService(service, newFileReplacer(ev.Name, event))
+			}
+
+		case err, ok := <-watcher.Error:
+			if !ok {
+				return
+			}
+			logger.Warning("file watcher error:", err)
+		}
+	}
+}
+
+func (fileMonitor *FileMonitor) stop() error {
+	if fileMonitor.watcher != nil {
+		return fileMonitor.watcher.Close()
+	}
+	return nil
+}
@@ -3,25 +3,45 @@ package service_trigger
 import (
 	"fmt"
 	"io/ioutil"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"pkg.deepin.io/lib/dbus1"
-	"pkg.deepin.io/lib/log"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+const (
+	dbusServiceName = "com.deepin.daemon.ServiceTrigger"
+	dbusPath        = "/com/deepin/daemon/ServiceTrigger"
+	dbusInterface   = dbusServiceName
 )
 
 type Manager struct {
+	service    *dbusutil.Service
 	serviceMap map[string]*Service
 
 	systemSigMonitor  *DBusSignalMonitor
 	sessionSigMonitor *DBusSignalMonitor
+	fileMonitor       *FileMonitor
+	udevMonitor       *UdevMonitor
+	timerMonitor      *TimerMonitor
+
+	methods *struct {
+		ListServices  func() `out:"services"`
+		GetExecutions func() `in:"name" out:"executions"`
+	}
 }
 
-func newManager() *Manager {
+func newManager(service *dbusutil.Service) *Manager {
 	m := &Manager{
+		service:           service,
 		systemSigMonitor:  newDBusSignalMonitor(busTypeSystem),
 		sessionSigMonitor: newDBusSignalMonitor(busTypeSession),
+		fileMonitor:       newFileMonitor(),
+		udevMonitor:       newUdevMonitor(),
+		timerMonitor:      newTimerMonitor(),
 	}
 	return m
 }
@@ -34,10 +54,27 @@ func (m *Manager) start() {
 
 	m.systemSigMonitor.init()
 	go m.systemSigMonitor.signalLoop(m)
+
+	err := m.fileMonitor.init()
+	if err != nil {
+		logger.Warning("failed to init file monitor:", err)
+	} else {
+		go m.fileMonitor.eventLoop(m)
+	}
+
+	m.udevMonitor.init(m)
+	m.timerMonitor.start(m)
 }
 
 func (m *Manager) stop() error {
-	err := m.sessionSigMonitor.stop()
+	m.timerMonitor.stop()
+	m.udevMonitor.stop()
+	err := m.fileMonitor.stop()
+	if err != nil {
+		return err
+	}
+
+	err = m.sessionSigMonitor.stop()
 	if err != nil {
 		return err
 	}
@@ -51,13 +88,20 @@ func (m *Manager) loadServices() {
 	m.loadServicesFromDir("/etc/deepin-daemon/" + moduleName)
 
 	for _, service := range m.serviceMap {
-		if service.Monitor.Type == "DBus" {
+		switch service.Monitor.Type {
+		case monitorTypeDBus:
 			dbusField := service.Monitor.DBus
 			if dbusField.BusType == "System" {
 				m.systemSigMonitor.appendService(service)
 			} else if dbusField.BusType == "Session" {
 				m.sessionSigMonitor.appendService(service)
 			}
+		case monitorTypeFile:
+			m.fileMonitor.appendService(service)
+		case monitorTypeUdev:
+			m.udevMonitor.appendService(service)
+		case monitorTypeTimer:
+			m.timerMonitor.appendService(service)
 		}
 	}
 }
@@ -103,6 +147,7 @@ func getNameOwner(conn *dbus.Conn, name string) (string, error) {
 	return owner, err
 }
 
+// newReplacer 替换 DBus 信号的参数 %{argN}，N 从 0 开始
 func newReplacer(signal *dbus.Signal) *strings.Replacer {
 	var oldNewSlice []string
 	for idx, item := range signal.Body {
@@ -114,31 +159,32 @@ func newReplacer(signal *dbus.Signal) *strings.Replacer {
 	return strings.NewReplacer(oldNewSlice...)
 }
 
-func (m *Manager) execService(service *Service, signal *dbus.Signal) {
-	if len(service.Exec) == 0 {
-		logger.Warning("service Exec empty")
-		return
-	}
-
-	var args []string
-	execArgs := service.Exec[1:]
-	if logger.GetLogLevel() == log.LevelDebug {
-		if service.Exec[0] == "sh" {
-			// add -x option for debug shell
-			execArgs = append([]string{"-x"}, execArgs...)
-		}
-	}
+// newFileReplacer 替换文件事件的 %{path}，%{dir}，%{name} 和 %{event}
+func newFileReplacer(path, event string) *strings.Replacer {
+	return strings.NewReplacer(
+		"%{path}", path,
+		"%{dir}", filepath.Dir(path),
+		"%{name}", filepath.Base(path),
+		"%{event}", event,
+	)
+}
 
-	replacer := newReplacer(signal)
-	for _, arg := range execArgs {
-		args = append(args, replacer.Replace(arg))
-	}
+// newUdevReplacer 替换 uevent 的 %{action}，%{subsystem}，%{devtype}，%{name}，%{sysfs_path} 和 %{device_file}
+func newUdevReplacer(ev *udevEvent) *strings.Replacer {
+	return strings.NewReplacer(
+		"%{action}", ev.action,
+		"%{subsystem}", ev.subsystem,
+		"%{devtype}", ev.devType,
+		"%{name}", ev.name,
+		"%{sysfs_path}", ev.sysfsPath,
+		"%{device_file}", ev.deviceFile,
+	)
+}
 
-	logger.Debugf("run cmd %q %#v", service.Exec[0], args)
-	cmd := exec.Command(service.Exec[0], args...)
-	out, err := cmd.CombinedOutput()
-	logger.Debugf("cmd combined output: %s", out)
-	if err != nil {
-		logger.Warning(err)
-	}
+// newTimerReplacer 替换定时器触发的时间 %{time} 和 %{timestamp}
+func newTimerReplacer(t time.Time) *strings.Replacer {
+	return strings.NewReplacer(
+		"%{time}", t.Format(time.RFC3339),
+		"%{timestamp}", strconv.FormatInt(t.Unix(), 10),
+	)
 }
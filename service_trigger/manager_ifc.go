@@ -0,0 +1,75 @@
+package service_trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+func (*Manager) GetInterfaceName() string {
+	return dbusInterface
+}
+
+// ServiceInfo 是 ListServices 返回的服务信息
+type ServiceInfo struct {
+	Name        string // 配置文件名去掉 .service.json
+	DisplayName string // 配置文件中的 Name
+	Description string
+	Filename    string
+	MonitorType string
+	Running     int
+	// 因为 RateLimit 或 MaxConcurrent 被丢弃的触发次数
+	Dropped uint64
+}
+
+func (m *Manager) getService(name string) *Service {
+	for _, service := range m.serviceMap {
+		if service.basename == name {
+			return service
+		}
+	}
+	return nil
+}
+
+func (m *Manager) ListServices() (string, *dbus.Error) {
+	infos := make([]*ServiceInfo, 0, len(m.serviceMap))
+	for _, service := range m.serviceMap {
+		rt := service.runtime
+		rt.mu.Lock()
+		infos = append(infos, &ServiceInfo{
+			Name:        service.basename,
+			DisplayName: service.Name,
+			Description: service.Description,
+			Filename:    service.filename,
+			MonitorType: service.Monitor.Type,
+			Running:     rt.running,
+			Dropped:     rt.dropped,
+		})
+		rt.mu.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	data, err := json.Marshal(infos)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) GetExecutions(name string) (string, *dbus.Error) {
+	service := m.getService(name)
+	if service == nil {
+		return "", dbusutil.ToError(fmt.Errorf("service %q not found", name))
+	}
+
+	data, err := json.Marshal(service.runtime.getExecutions())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
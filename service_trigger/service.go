@@ -7,8 +7,17 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"pkg.deepin.io/lib/dbus1"
+	"pkg.deepin.io/lib/strv"
+)
+
+const (
+	monitorTypeDBus  = "DBus"
+	monitorTypeFile  = "File"
+	monitorTypeUdev  = "Udev"
+	monitorTypeTimer = "Timer"
 )
 
 type Service struct {
@@ -23,11 +32,38 @@ type Service struct {
 			Signal    string
 			Path      string // optional
 		}
+		File *struct { // inotify file monitor
+			Paths  []string // path patterns, only the base name can contain wildcards
+			Events []string // optional, create, modify, delete, rename or attrib
+		}
+		Udev *struct { // udev uevent monitor
+			Subsystem string
+			DevType   string // optional
+			Action    string // optional, add, remove, change ...
+		}
+		Timer *struct { // timer monitor, one of Interval and OnCalendar
+			Interval   string // such as 30m, 1h
+			OnCalendar string // such as 09:00, Mon,Fri 18:30
+		}
 	}
 
 	Name        string
 	Description string
 	Exec        []string
+
+	// 在 Interval 内最多执行 Burst 次，多余的触发会被丢弃
+	RateLimit *struct {
+		Burst    int
+		Interval string // such as 1m
+	}
+	// 触发后等待 Debounce 时间没有再次触发才执行，执行时使用最后一次触发的参数
+	Debounce string
+	// 同时执行的最大数量，0 表示不限制，达到时新的触发会被丢弃
+	MaxConcurrent int
+
+	rateInterval time.Duration
+	debounce     time.Duration
+	runtime      *serviceRuntime
 }
 
 func (service *Service) getDBusMatchRule() string {
@@ -45,15 +81,21 @@ func (service *Service) getDBusMatchRule() string {
 }
 
 func (service *Service) check() error {
-	if service.Monitor.Type != "DBus" {
-		return fmt.Errorf("unknown Monitor.Type %q" + service.Monitor.Type)
+	var err error
+	switch service.Monitor.Type {
+	case monitorTypeDBus:
+		err = service.checkDBus()
+	case monitorTypeFile:
+		err = service.checkFile()
+	case monitorTypeUdev:
+		err = service.checkUdev()
+	case monitorTypeTimer:
+		err = service.checkTimer()
+	default:
+		return fmt.Errorf("unknown Monitor.Type %q", service.Monitor.Type)
 	}
-
-	if service.Monitor.Type == "DBus" {
-		err := service.checkDBus()
-		if err != nil {
-			return err
-		}
+	if err != nil {
+		return err
 	}
 
 	if service.Name == "" {
@@ -64,6 +106,38 @@ func (service *Service) check() error {
 		return errors.New("field Exec is empty")
 	}
 
+	return service.checkLimits()
+}
+
+func (service *Service) checkLimits() error {
+	if rateLimit := service.RateLimit; rateLimit != nil {
+		if rateLimit.Burst <= 0 {
+			return errors.New("field RateLimit.Burst should be greater than 0")
+		}
+		interval, err := time.ParseDuration(rateLimit.Interval)
+		if err != nil {
+			return fmt.Errorf("field RateLimit.Interval is invalid: %v", err)
+		}
+		if interval <= 0 {
+			return errors.New("field RateLimit.Interval should be greater than 0")
+		}
+		service.rateInterval = interval
+	}
+
+	if service.Debounce != "" {
+		debounce, err := time.ParseDuration(service.Debounce)
+		if err != nil {
+			return fmt.Errorf("field Debounce is invalid: %v", err)
+		}
+		if debounce < 0 {
+			return errors.New("field Debounce is negative")
+		}
+		service.debounce = debounce
+	}
+
+	if service.MaxConcurrent < 0 {
+		return errors.New("field MaxConcurrent is negative")
+	}
 	return nil
 }
 
@@ -97,6 +171,79 @@ func (service *Service) checkDBus() error {
 	return nil
 }
 
+var fileEventNames = []string{"create", "modify", "delete", "rename", "attrib"}
+
+func (service *Service) checkFile() error {
+	fileField := service.Monitor.File
+	if fileField == nil {
+		return errors.New("field Monitor.File is nil")
+	}
+
+	if len(fileField.Paths) == 0 {
+		return errors.New("field Monitor.File.Paths is empty")
+	}
+	for _, pattern := range fileField.Paths {
+		pattern = expandPath(pattern)
+		if !filepath.IsAbs(pattern) {
+			return fmt.Errorf("path %q in field Monitor.File.Paths is not absolute", pattern)
+		}
+		if hasGlobMeta(filepath.Dir(pattern)) {
+			return fmt.Errorf("dir of path %q in field Monitor.File.Paths contains wildcards", pattern)
+		}
+		_, err := filepath.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("path %q in field Monitor.File.Paths is invalid: %v", pattern, err)
+		}
+	}
+
+	for _, event := range fileField.Events {
+		if !strv.Strv(fileEventNames).Contains(event) {
+			return fmt.Errorf("event %q in field Monitor.File.Events is invalid", event)
+		}
+	}
+	return nil
+}
+
+func (service *Service) checkUdev() error {
+	udevField := service.Monitor.Udev
+	if udevField == nil {
+		return errors.New("field Monitor.Udev is nil")
+	}
+
+	if udevField.Subsystem == "" {
+		return errors.New("field Monitor.Udev.Subsystem is empty")
+	}
+	return nil
+}
+
+func (service *Service) checkTimer() error {
+	timerField := service.Monitor.Timer
+	if timerField == nil {
+		return errors.New("field Monitor.Timer is nil")
+	}
+
+	if (timerField.Interval == "") == (timerField.OnCalendar == "") {
+		return errors.New("one and only one of field Monitor.Timer.Interval and Monitor.Timer.OnCalendar should be set")
+	}
+
+	if timerField.Interval != "" {
+		interval, err := time.ParseDuration(timerField.Interval)
+		if err != nil {
+			return fmt.Errorf("field Monitor.Timer.Interval is invalid: %v", err)
+		}
+		if interval < minTimerInterval {
+			return fmt.Errorf("field Monitor.Timer.Interval is less than %v", minTimerInterval)
+		}
+		return nil
+	}
+
+	_, err := parseCalendar(timerField.OnCalendar)
+	if err != nil {
+		return fmt.Errorf("field Monitor.Timer.OnCalendar is invalid: %v", err)
+	}
+	return nil
+}
+
 func loadService(filename string) (*Service, error) {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -116,6 +263,7 @@ func loadService(filename string) (*Service, error) {
 
 	service.filename = filename
 	service.basename = strings.TrimSuffix(filepath.Base(filename), serviceFileExt)
+	service.runtime = &serviceRuntime{}
 	return &service, nil
 }
 
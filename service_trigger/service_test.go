@@ -0,0 +1,126 @@
+package service_trigger
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestService(t *testing.T, data string) *Service {
+	var service Service
+	err := json.Unmarshal([]byte(data), &service)
+	assert.Nil(t, err)
+	return &service
+}
+
+func TestServiceCheck(t *testing.T) {
+	tests := []struct {
+		data  string
+		valid bool
+	}{
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"Unknown"}}`, false},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"File"}}`, false},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"File","File":{"Paths":["/tmp/*.pdf"]}}}`, true},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"File","File":{"Paths":["~/Downloads/*.pdf"],"Events":["create"]}}}`, true},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"File","File":{"Paths":["tmp/a"]}}}`, false},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"File","File":{"Paths":["/tmp/*/a"]}}}`, false},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"File","File":{"Paths":["/tmp/a"],"Events":["open"]}}}`, false},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"Udev","Udev":{"Subsystem":"usb","Action":"add"}}}`, true},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"Udev","Udev":{"Action":"add"}}}`, false},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"Timer","Timer":{"Interval":"30m"}}}`, true},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"Timer","Timer":{"Interval":"1s"}}}`, false},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"Timer","Timer":{"OnCalendar":"Mon..Fri 09:00"}}}`, true},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"Timer","Timer":{"Interval":"1h","OnCalendar":"09:00"}}}`, false},
+		{`{"Name":"a","Exec":["true"],"Monitor":{"Type":"Timer","Timer":{}}}`, false},
+		{`{"Exec":["true"],"Monitor":{"Type":"Timer","Timer":{"Interval":"1h"}}}`, false},
+	}
+
+	for _, test := range tests {
+		err := newTestService(t, test.data).check()
+		if test.valid {
+			assert.Nil(t, err, test.data)
+		} else {
+			assert.NotNil(t, err, test.data)
+		}
+	}
+}
+
+func TestParseCalendar(t *testing.T) {
+	spec, err := parseCalendar("09:30")
+	assert.Nil(t, err)
+	assert.Equal(t, &calendarSpec{hour: 9, minute: 30}, spec)
+
+	spec, err = parseCalendar("Mon,Wed..Fri 18:00:05")
+	assert.Nil(t, err)
+	assert.Equal(t, &calendarSpec{weekdays: 0x3a, hour: 18, second: 5}, spec)
+
+	spec, err = parseCalendar("Sat..Mon 00:00")
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0x43), spec.weekdays)
+
+	for _, str := range []string{"", "9", "24:00", "12:60", "Foo 12:00", "Mon 12:00 x"} {
+		_, err = parseCalendar(str)
+		assert.NotNil(t, err, str)
+	}
+}
+
+func TestCalendarNext(t *testing.T) {
+	// 2019-07-03 是周三
+	now := time.Date(2019, 7, 3, 10, 0, 0, 0, time.UTC)
+
+	spec, _ := parseCalendar("09:00")
+	assert.Equal(t, time.Date(2019, 7, 4, 9, 0, 0, 0, time.UTC), spec.next(now))
+
+	spec, _ = parseCalendar("11:00")
+	assert.Equal(t, time.Date(2019, 7, 3, 11, 0, 0, 0, time.UTC), spec.next(now))
+
+	spec, _ = parseCalendar("10:00")
+	assert.Equal(t, time.Date(2019, 7, 4, 10, 0, 0, 0, time.UTC), spec.next(now))
+
+	spec, _ = parseCalendar("Mon 08:00")
+	assert.Equal(t, time.Date(2019, 7, 8, 8, 0, 0, 0, time.UTC), spec.next(now))
+
+	spec, _ = parseCalendar("Wed 09:00")
+	assert.Equal(t, time.Date(2019, 7, 10, 9, 0, 0, 0, time.UTC), spec.next(now))
+}
+
+func TestFileMonitorFindMatchedServices(t *testing.T) {
+	pdf := newTestService(t, `{"Monitor":{"Type":"File","File":{"Paths":["/tmp/a/*.pdf"],"Events":["create"]}}}`)
+	all := newTestService(t, `{"Monitor":{"Type":"File","File":{"Paths":["/tmp/a/*", "/tmp/b/c"]}}}`)
+	fileMonitor := newFileMonitor()
+	fileMonitor.appendService(pdf)
+	fileMonitor.appendService(all)
+
+	assert.Equal(t, []*Service{pdf, all}, fileMonitor.findMatchedServices("/tmp/a/x.pdf", "create"))
+	assert.Equal(t, []*Service{all}, fileMonitor.findMatchedServices("/tmp/a/x.pdf", "delete"))
+	assert.Equal(t, []*Service{all}, fileMonitor.findMatchedServices("/tmp/b/c", "modify"))
+	assert.Nil(t, fileMonitor.findMatchedServices("/tmp/b/d", "modify"))
+}
+
+func TestUdevMonitorFindMatchedServices(t *testing.T) {
+	usbAdd := newTestService(t, `{"Monitor":{"Type":"Udev","Udev":{"Subsystem":"usb","DevType":"usb_device","Action":"add"}}}`)
+	usb := newTestService(t, `{"Monitor":{"Type":"Udev","Udev":{"Subsystem":"usb"}}}`)
+	udevMonitor := newUdevMonitor()
+	udevMonitor.appendService(usbAdd)
+	udevMonitor.appendService(usb)
+
+	ev := &udevEvent{action: "add", subsystem: "usb", devType: "usb_device"}
+	assert.Equal(t, []*Service{usbAdd, usb}, udevMonitor.findMatchedServices(ev))
+	ev.devType = "usb_interface"
+	assert.Equal(t, []*Service{usb}, udevMonitor.findMatchedServices(ev))
+	ev.subsystem = "block"
+	assert.Nil(t, udevMonitor.findMatchedServices(ev))
+}
+
+func TestReplacers(t *testing.T) {
+	r := newFileReplacer("/tmp/a/x.pdf", "create")
+	assert.Equal(t, "/tmp/a x.pdf create /tmp/a/x.pdf", r.Replace("%{dir} %{name} %{event} %{path}"))
+
+	r = newUdevReplacer(&udevEvent{action: "add", subsystem: "usb", deviceFile: "/dev/bus/usb/001/002"})
+	assert.Equal(t, "add usb /dev/bus/usb/001/002", r.Replace("%{action} %{subsystem} %{device_file}"))
+
+	r = newTimerReplacer(time.Unix(1562140800, 0).UTC())
+	assert.Equal(t, "2019-07-03T08:00:00Z 1562140800", r.Replace("%{time} %{timestamp}"))
+}
@@ -0,0 +1,164 @@
+package service_trigger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const minTimerInterval = time.Minute
+
+type TimerMonitor struct {
+	services []*Service
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newTimerMonitor() *TimerMonitor {
+	return &TimerMonitor{
+		quit: make(chan struct{}),
+	}
+}
+
+func (timerMonitor *TimerMonitor) appendService(service *Service) {
+	timerMonitor.services = append(timerMonitor.services, service)
+}
+
+func (timerMonitor *TimerMonitor) start(m *Manager) {
+	for _, service := range timerMonitor.services {
+		timerField := service.Monitor.Timer
+		var next func(time.Time) time.Time
+		if timerField.Interval != "" {
+			interval, _ := time.ParseDuration(timerField.Interval)
+			next = func(t time.Time) time.Time {
+				return t.Add(interval)
+			}
+		} else {
+			calendar, _ := parseCalendar(timerField.OnCalendar)
+			next = calendar.next
+		}
+
+		timerMonitor.wg.Add(1)
+		go timerMonitor.loop(m, service, next)
+	}
+}
+
+func (timerMonitor *TimerMonitor) loop(m *Manager, service *Service, next func(time.Time) time.Time) {
+	defer timerMonitor.wg.Done()
+	for {
+		now := time.Now()
+		timer := time.NewTimer(next(now).Sub(now))
+		select {
+		case <-timerMonitor.quit:
+			timer.Stop()
+			return
+		case t := <-timer.C:
+			logger.Debug("trigger service", service)
+			m.triggerService(service, newTimerReplacer(t))
+		}
+	}
+}
+
+func (timerMonitor *TimerMonitor) stop() {
+	close(timerMonitor.quit)
+	timerMonitor.wg.Wait()
+}
+
+// calendarSpec 是 OnCalendar 解析后的结果，格式为 [星期 ]时:分[:秒]，
+// 星期可以是逗号分隔的 Mon 到 Sun，也可以是 Mon..Fri 这样的范围，省略表示每天。
+type calendarSpec struct {
+	weekdays uint8 // 各个位表示 time.Weekday，为 0 表示每天
+	hour     int
+	minute   int
+	second   int
+}
+
+var weekdayNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for i, weekdayName := range weekdayNames {
+		if strings.EqualFold(name, weekdayName) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", name)
+}
+
+func parseWeekdays(str string) (uint8, error) {
+	var weekdays uint8
+	for _, item := range strings.Split(str, ",") {
+		parts := strings.SplitN(item, "..", 2)
+		begin, err := parseWeekday(parts[0])
+		if err != nil {
+			return 0, err
+		}
+		end := begin
+		if len(parts) == 2 {
+			end, err = parseWeekday(parts[1])
+			if err != nil {
+				return 0, err
+			}
+		}
+		// Sat..Mon 这样的范围会跨过周日
+		for day := begin; ; day = (day + 1) % 7 {
+			weekdays |= 1 << uint(day)
+			if day == end {
+				break
+			}
+		}
+	}
+	return weekdays, nil
+}
+
+func parseCalendar(str string) (*calendarSpec, error) {
+	fields := strings.Fields(str)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, errors.New("invalid format")
+	}
+
+	var spec calendarSpec
+	if len(fields) == 2 {
+		weekdays, err := parseWeekdays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		spec.weekdays = weekdays
+	}
+
+	timeStr := fields[len(fields)-1]
+	var n int
+	var err error
+	if strings.Count(timeStr, ":") == 2 {
+		n, err = fmt.Sscanf(timeStr, "%d:%d:%d", &spec.hour, &spec.minute, &spec.second)
+	} else {
+		n, err = fmt.Sscanf(timeStr, "%d:%d", &spec.hour, &spec.minute)
+	}
+	if err != nil || n < 2 {
+		return nil, fmt.Errorf("invalid time %q", timeStr)
+	}
+	if spec.hour < 0 || spec.hour > 23 ||
+		spec.minute < 0 || spec.minute > 59 ||
+		spec.second < 0 || spec.second > 59 {
+		return nil, fmt.Errorf("invalid time %q", timeStr)
+	}
+	return &spec, nil
+}
+
+// next 返回 t 之后下一个符合 spec 的时间
+func (spec *calendarSpec) next(t time.Time) time.Time {
+	year, month, day := t.Date()
+	for i := 0; i <= 7; i++ {
+		result := time.Date(year, month, day+i, spec.hour, spec.minute, spec.second, 0, t.Location())
+		if !result.After(t) {
+			continue
+		}
+		if spec.weekdays != 0 && spec.weekdays&(1<<uint(result.Weekday())) == 0 {
+			continue
+		}
+		return result
+	}
+	// 不会到达这里
+	return t.Add(24 * time.Hour)
+}
@@ -0,0 +1,104 @@
+package service_trigger
+
+import (
+	"pkg.deepin.io/gir/gudev-1.0"
+)
+
+type UdevMonitor struct {
+	client   *gudev.Client
+	services []*Service
+}
+
+func newUdevMonitor() *UdevMonitor {
+	return &UdevMonitor{}
+}
+
+func (udevMonitor *UdevMonitor) appendService(service *Service) {
+	udevMonitor.services = append(udevMonitor.services, service)
+}
+
+func (udevMonitor *UdevMonitor) init(m *Manager) {
+	if len(udevMonitor.services) == 0 {
+		return
+	}
+
+	var subsystems []string
+	for _, service := range udevMonitor.services {
+		subsystem := service.Monitor.Udev.Subsystem
+		if !isStrInList(subsystem, subsystems) {
+			subsystems = append(subsystems, subsystem)
+		}
+	}
+
+	client := gudev.NewClient(subsystems)
+	if client == nil {
+		logger.Warning("failed to new gudev client")
+		return
+	}
+	udevMonitor.client = client
+	client.Connect("uevent", func(client *gudev.Client, action string, device *gudev.Device) {
+		defer device.Unref()
+		udevMonitor.handleUEvent(m, action, device)
+	})
+}
+
+func isStrInList(item string, list []string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+type udevEvent struct {
+	action     string
+	subsystem  string
+	devType    string
+	name       string
+	sysfsPath  string
+	deviceFile string
+}
+
+func (udevMonitor *UdevMonitor) findMatchedServices(ev *udevEvent) []*Service {
+	var matched []*Service
+	for _, service := range udevMonitor.services {
+		udevField := service.Monitor.Udev
+		if udevField.Subsystem != ev.subsystem {
+			continue
+		}
+		if udevField.DevType != "" && udevField.DevType != ev.devType {
+			continue
+		}
+		if udevField.Action != "" && udevField.Action != ev.action {
+			continue
+		}
+		matched = append(matched, service)
+	}
+	return matched
+}
+
+func (udevMonitor *UdevMonitor) handleUEvent(m *Manager, action string, device *gudev.Device) {
+	ev := &udevEvent{
+		action:     action,
+		subsystem:  device.GetSubsystem(),
+		devType:    device.GetDevtype(),
+		name:       device.GetName(),
+		sysfsPath:  device.GetSysfsPath(),
+		deviceFile: device.GetDeviceFile(),
+	}
+	logger.Debugf("uevent %s %s", ev.action, ev.sysfsPath)
+
+	services := udevMonitor.findMatchedServices(ev)
+	for _, service := range services {
+		logger.Debug("trigger service", service)
+		m.triggerService(service, newUdevReplacer(ev))
+	}
+}
+
+func (udevMonitor *UdevMonitor) stop() {
+	if udevMonitor.client != nil {
+		udevMonitor.client.Unref()
+		udevMonitor.client = nil
+	}
+}
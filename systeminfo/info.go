@@ -61,6 +61,7 @@ var logger = log.NewLogger("daemon/systeminfo")
 func NewDaemon(logger *log.Logger) *Daemon {
 	daemon := new(Daemon)
 	daemon.ModuleBase = loader.NewModuleBase("systeminfo", daemon, logger)
+	daemon.SetStartConcurrently(true)
 	return daemon
 }
 